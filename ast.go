@@ -2,12 +2,28 @@ package main
 
 import (
 	"fmt"
+	"slices"
 	"strconv"
+	"strings"
 )
 
 type LocalScope struct {
 	states     map[string]TokenStream
 	conditions []TokenStream
+	origins    []ProvenanceStep // The cond or in each condition came from, where known
+}
+
+func NewLocalScope() LocalScope {
+	return LocalScope{
+		states:     make(map[string]TokenStream, 0),
+		conditions: make([]TokenStream, 0),
+		origins:    make([]ProvenanceStep, 0),
+	}
+}
+
+func (scope *LocalScope) addCondition(cmd *Command) {
+	scope.conditions = append(scope.conditions, cmd.verbatimArg(0))
+	scope.origins = append(scope.origins, ProvenanceStep{kind: "cond", detail: cmd.arg(0).toSource(), source: cmd.source()})
 }
 
 type VerbatimOrState struct {
@@ -25,6 +41,17 @@ func (vos *VerbatimOrState) getStream(scope *Scope) TokenStream {
 	}
 }
 
+// A short human readable name
+func (vos *VerbatimOrState) describe() string {
+	if vos.label != "" {
+		return vos.label
+	} else if !vos.verbatim {
+		return vos.state
+	} else {
+		return "(" + streamToString(trimWhitespace(vos.stream)) + ")"
+	}
+}
+
 type ProofCommand interface {
 	GenProperty
 }
@@ -40,27 +67,35 @@ type InStatesSubProofCommand struct {
 	label  string
 	states []VerbatimOrState
 	seq    SequencedProofSteps
+	source Source
 }
 
 type LemmaProofCommand struct {
-	label string
-	name  string
+	label  string
+	name   string
+	using  []string
+	source Source
 }
 
 type BlockProofCommand struct {
-	label string
-	seq   SequencedProofSteps
+	label  string
+	seq    SequencedProofSteps
+	using  []string
+	source Source
 }
 
 type HaveProofCommand struct {
 	label     string
 	condition TokenStream
 	helper    ProofHelper
+	using     []string // The earlier properties this is proved from, nil for all of them
+	source    Source
 }
 
 type UseProofCommand struct {
 	name   string
 	helper ProofHelper
+	source Source
 }
 
 type ProofHelper interface {
@@ -73,22 +108,26 @@ type SplitProofCase struct {
 	label     string
 	condition VerbatimOrState
 	helper    ProofHelper
+	source    Source
 }
 
 type SplitProofHelper struct {
-	check bool
-	cases []SplitProofCase
+	check  bool
+	cases  []SplitProofCase
+	source Source
 }
 
 type SplitBoolProofHelper struct {
 	pivots []VerbatimOrState
 	helper ProofHelper
+	source Source
 }
 
 type KInductionProofHelper struct {
 	label    string
 	k        int
 	wireSets []string
+	source   Source
 }
 
 type SequenceProofHelper struct {
@@ -106,6 +145,7 @@ type GraphInductionNodeDefinition struct {
 	stepTransitions []string
 	epsTransitions  []string
 	helper          ProofHelper
+	source          Source
 }
 
 type GraphInductionProofHelper struct {
@@ -113,12 +153,15 @@ type GraphInductionProofHelper struct {
 	backward       bool
 	complete       bool
 	onehot         bool
+	cover          bool
 	invariants     map[string]TokenStream
 	entryCondition TokenStream
 	entryNodes     []string
 	entryHelper    HelpProperty
 	nodes          map[string]GraphInductionNodeDefinition
 	scope          LocalScope
+	source         Source
+	entrySource    Source
 }
 
 type GraphInductionProofCommand struct {
@@ -128,6 +171,72 @@ type GraphInductionProofCommand struct {
 type SequencedProofSteps struct {
 	scope    LocalScope
 	sequence [][]ProofCommand
+	names    []string // The name of each step, or empty
+	after    [][]int  // The earlier steps each step is proved after, nil for just the previous step
+}
+
+func NewSequencedProofSteps() SequencedProofSteps {
+	seq := SequencedProofSteps{
+		scope:    NewLocalScope(),
+		sequence: make([][]ProofCommand, 1),
+		names:    []string{""},
+		after:    [][]int{nil},
+	}
+	seq.sequence[0] = make([]ProofCommand, 0)
+	return seq
+}
+
+// Starts a new step, unless the last step is still empty
+func (seq *SequencedProofSteps) nextStep() {
+	if len(seq.sequence[len(seq.sequence)-1]) != 0 {
+		seq.sequence = append(seq.sequence, make([]ProofCommand, 0))
+		seq.names = append(seq.names, "")
+		seq.after = append(seq.after, nil)
+	}
+}
+
+// Names the last step, and makes it come after the given earlier steps rather than the previous step unless after is nil
+func (seq *SequencedProofSteps) setStep(name string, after []string) {
+	last := len(seq.sequence) - 1
+	if name != "" {
+		if seq.names[last] != "" {
+			panic(fmt.Errorf("step %s is empty", seq.names[last]))
+		}
+		if slices.Contains(seq.names, name) {
+			panic(fmt.Errorf("step %s already exists", name))
+		}
+		seq.names[last] = name
+	}
+	if after != nil {
+		seq.after[last] = []int{}
+		for _, dep := range after {
+			i := slices.Index(seq.names[:last], dep)
+			if i == -1 {
+				panic(fmt.Errorf("unknown step %s, steps can only come after earlier steps", dep))
+			}
+			seq.after[last] = append(seq.after[last], i)
+		}
+	}
+}
+
+// The steps a step is proved after
+func (seq *SequencedProofSteps) stepAfter(i int) []int {
+	if seq.after[i] != nil {
+		return seq.after[i]
+	} else if i == 0 {
+		return []int{}
+	}
+	return []int{i - 1}
+}
+
+// Whether every step is proved after the one before it
+func (seq *SequencedProofSteps) linear() bool {
+	for _, after := range seq.after {
+		if after != nil {
+			return false
+		}
+	}
+	return true
 }
 
 type Lemma struct {
@@ -137,69 +246,42 @@ type Lemma struct {
 }
 
 type ProofDocument struct {
-	defs   map[string]SequencedProofSteps
-	lemmas map[string]Lemma
+	defs     map[string]SequencedProofSteps
+	lemmas   map[string]Lemma
+	states   map[string]GlobalState
+	imports  []Import
+	warnings []string
+}
+
+func NewProofDocument() ProofDocument {
+	return ProofDocument{
+		defs:     map[string]SequencedProofSteps{},
+		lemmas:   map[string]Lemma{},
+		states:   map[string]GlobalState{},
+		imports:  []Import{},
+		warnings: []string{},
+	}
+}
+
+// A state declared outside of any lemma or def, visible to all of them unless shadowed
+type GlobalState struct {
+	value  TokenStream
+	source Source
+}
+
+// Declares a global state, warning if it redefines an earlier one, which it then replaces
+func (doc *ProofDocument) addState(name string, state GlobalState) {
+	if prev, ok := doc.states[name]; ok {
+		doc.warnings = append(doc.warnings, fmt.Sprintf("warning: %s: state %s redefined, previously defined at %s", state.source, name, prev.source))
+	}
+	doc.states[name] = state
 }
 
 func blocksToProofHelper(blocks []Block) ProofHelper {
 	helpers := []ProofHelper{}
 
 	for _, block := range blocks {
-		switch block.first.operator {
-		case "split_bool":
-			pivots := make([]VerbatimOrState, 0)
-			for _, arg := range block.first.inlineArgs {
-				pivots = append(pivots, arg.toVerbatimOrState())
-			}
-			helpers = append(helpers, &SplitBoolProofHelper{
-				pivots: pivots,
-				helper: blocksToProofHelper(block.body),
-			})
-		case "split":
-			cases := make([]SplitProofCase, 0)
-
-			for _, arg := range block.first.inlineArgs {
-				cases = append(cases, SplitProofCase{
-					label:     "",
-					condition: arg.toVerbatimOrState(),
-					helper:    NopProofHelper(),
-				})
-			}
-
-			for _, block := range block.body {
-				if block.first.operator != "case" {
-					panic("non case command in split")
-				}
-				block.first.fixArgs(1)
-				cases = append(cases, SplitProofCase{
-					label:     block.first.label,
-					condition: block.first.verbatimOrStateArg(0),
-					helper:    blocksToProofHelper(block.body),
-				})
-			}
-
-			helpers = append(helpers, &SplitProofHelper{
-				check: !block.first.hasFlag("nocheck"),
-				cases: cases,
-			})
-		case "k_induction":
-			block.first.fixArgs(1)
-			k, err := strconv.Atoi(block.first.wordArg(0))
-			if err != nil {
-				panic(fmt.Errorf("expected an integer for k"))
-			}
-			helpers = append(helpers, &KInductionProofHelper{
-				label:    block.first.label,
-				k:        k,
-				wireSets: []string{},
-			})
-		case "graph_induction":
-			block.first.fixArgs(0)
-			x := blocksToGraphInduction(block)
-			helpers = append(helpers, &x)
-		default:
-			panic("unknown proof helper " + block.first.operator)
-		}
+		helpers = append(helpers, blockToProofHelper(block))
 	}
 
 	if len(helpers) == 1 {
@@ -209,80 +291,150 @@ func blocksToProofHelper(blocks []Block) ProofHelper {
 	}
 }
 
+func blockToProofHelper(block Block) ProofHelper {
+	defer block.first.locate()
+
+	switch block.first.operator {
+	case "split_bool":
+		pivots := make([]VerbatimOrState, 0)
+		for _, arg := range block.first.inlineArgs {
+			pivots = append(pivots, arg.toVerbatimOrState())
+		}
+		return &SplitBoolProofHelper{
+			pivots: pivots,
+			helper: blocksToProofHelper(block.body),
+			source: block.first.source(),
+		}
+	case "split":
+		cases := make([]SplitProofCase, 0)
+
+		for _, arg := range block.first.inlineArgs {
+			cases = append(cases, SplitProofCase{
+				label:     "",
+				condition: arg.toVerbatimOrState(),
+				helper:    NopProofHelper(),
+				source:    block.first.source(),
+			})
+		}
+
+		for _, block := range block.body {
+			cases = append(cases, blockToSplitCase(block))
+		}
+
+		return &SplitProofHelper{
+			check:  !block.first.hasFlag("nocheck"),
+			cases:  cases,
+			source: block.first.source(),
+		}
+	case "k_induction":
+		block.first.fixArgs(1)
+		k, err := strconv.Atoi(block.first.wordArg(0))
+		if err != nil {
+			panic(fmt.Errorf("expected an integer for k"))
+		}
+		return &KInductionProofHelper{
+			label:    block.first.label,
+			k:        k,
+			wireSets: []string{},
+			source:   block.first.source(),
+		}
+	case "graph_induction":
+		block.first.fixArgs(0)
+		x := blocksToGraphInduction(block)
+		return &x
+	default:
+		panic("unknown proof helper " + block.first.operator)
+	}
+}
+
+func blockToSplitCase(block Block) SplitProofCase {
+	defer block.first.locate()
+
+	if block.first.operator != "case" {
+		panic("non case command in split")
+	}
+	block.first.fixArgs(1)
+	return SplitProofCase{
+		label:     block.first.label,
+		condition: block.first.verbatimOrStateArg(0),
+		helper:    blocksToProofHelper(block.body),
+		source:    block.first.source(),
+	}
+}
+
 func blocksToGraphInduction(root Block) GraphInductionProofHelper {
 	cmd := GraphInductionProofHelper{
 		label:          root.first.label,
 		backward:       root.first.hasFlag("rev"),
 		complete:       root.first.hasFlag("complete"),
 		onehot:         root.first.hasFlag("onehot"),
+		cover:          root.first.hasFlag("cover"),
 		invariants:     make(map[string]TokenStream, 0),
 		entryCondition: nil,
 		entryNodes:     make([]string, 0),
 		entryHelper:    NopProofHelper(),
 		nodes:          map[string]GraphInductionNodeDefinition{},
-		scope: LocalScope{
-			states:     make(map[string]TokenStream, 0),
-			conditions: make([]TokenStream, 0),
-		},
+		scope:          NewLocalScope(),
+		source:         root.first.source(),
 	}
 	for _, block := range root.body {
-		switch block.first.operator {
-		case "inv":
-			block.first.fixArgs(2)
-			cmd.invariants[block.first.wordArg(0)] = block.first.verbatimArg(1)
-		case "entry":
-			block.first.fixArgs(1)
-			cmd.entryCondition = block.first.verbatimArg(0)
-			cmd.entryNodes = append(cmd.entryNodes, block.first.nowWordArray()...)
-			cmd.entryHelper = blocksToProofHelper(block.body)
-		case "node":
-			block.first.fixArgs(3)
-			node := GraphInductionNodeDefinition{
-				exit:            block.first.hasFlag("exit"),
-				invariant:       block.first.verbatimOrStateArg(1),
-				condition:       block.first.verbatimOrStateArg(2),
-				stepTransitions: []string{},
-				helper:          blocksToProofHelper(block.body),
-			}
-			if block.first.trailingMode == TRAILING_NOW {
-				node.epsTransitions = append(node.epsTransitions, block.first.nowWordArray()...)
-			} else if block.first.trailingMode == TRAILING_STEP {
-				node.stepTransitions = append(node.stepTransitions, block.first.stepWordArray()...)
-			}
-			cmd.nodes[block.first.wordArg(0)] = node
-		case "edge":
-			block.first.fixArgs(1)
-			name := block.first.wordArg(0)
-			node := cmd.nodes[name]
-			if block.first.trailingMode == TRAILING_NOW {
-				node.epsTransitions = append(node.epsTransitions, block.first.nowWordArray()...)
-			} else if block.first.trailingMode == TRAILING_STEP {
-				node.stepTransitions = append(node.stepTransitions, block.first.stepWordArray()...)
-			}
-			cmd.nodes[name] = node
-		case "cond":
-			block.first.fixArgs(1)
-			cmd.scope.conditions = append(cmd.scope.conditions, block.first.verbatimArg(0))
-		}
+		cmd.addBlock(block)
 	}
 	return cmd
 }
 
-func blocksToSequenceProof(blocks []Block) SequencedProofSteps {
-	seq := SequencedProofSteps{
-		scope: LocalScope{
-			states:     make(map[string]TokenStream, 0),
-			conditions: make([]TokenStream, 0),
-		},
-		sequence: make([][]ProofCommand, 1),
+func (cmd *GraphInductionProofHelper) addBlock(block Block) {
+	defer block.first.locate()
+
+	switch block.first.operator {
+	case "inv":
+		block.first.fixArgs(2)
+		cmd.invariants[block.first.wordArg(0)] = block.first.verbatimArg(1)
+	case "entry":
+		block.first.fixArgs(1)
+		cmd.entryCondition = block.first.verbatimArg(0)
+		cmd.entryNodes = append(cmd.entryNodes, block.first.nowWordArray()...)
+		cmd.entryHelper = blocksToProofHelper(block.body)
+		cmd.entrySource = block.first.source()
+	case "node":
+		block.first.fixArgs(3)
+		node := GraphInductionNodeDefinition{
+			exit:            block.first.hasFlag("exit"),
+			invariant:       block.first.verbatimOrStateArg(1),
+			condition:       block.first.verbatimOrStateArg(2),
+			stepTransitions: []string{},
+			helper:          blocksToProofHelper(block.body),
+			source:          block.first.source(),
+		}
+		if block.first.trailingMode == TRAILING_NOW {
+			node.epsTransitions = append(node.epsTransitions, block.first.nowWordArray()...)
+		} else if block.first.trailingMode == TRAILING_STEP {
+			node.stepTransitions = append(node.stepTransitions, block.first.stepWordArray()...)
+		}
+		cmd.nodes[block.first.wordArg(0)] = node
+	case "edge":
+		block.first.fixArgs(1)
+		name := block.first.wordArg(0)
+		node := cmd.nodes[name]
+		if block.first.trailingMode == TRAILING_NOW {
+			node.epsTransitions = append(node.epsTransitions, block.first.nowWordArray()...)
+		} else if block.first.trailingMode == TRAILING_STEP {
+			node.stepTransitions = append(node.stepTransitions, block.first.stepWordArray()...)
+		}
+		cmd.nodes[name] = node
+	case "cond":
+		block.first.fixArgs(1)
+		cmd.scope.addCondition(&block.first)
 	}
-	seq.sequence[0] = make([]ProofCommand, 0)
+}
+
+func blocksToSequenceProof(blocks []Block) SequencedProofSteps {
+	seq := NewSequencedProofSteps()
 
 	for _, block := range blocks {
 		if block.first.operator == "/" {
-			if len(seq.sequence[len(seq.sequence)-1]) != 0 {
-				seq.sequence = append(seq.sequence, make([]ProofCommand, 0))
-			}
+			seq.nextStep()
+			blockToStep(block, &seq)
 			continue
 		}
 
@@ -294,12 +446,39 @@ func blocksToSequenceProof(blocks []Block) SequencedProofSteps {
 	return seq
 }
 
+// Handles `/ name after a b`, where both the name and after are optional
+func blockToStep(block Block, seq *SequencedProofSteps) {
+	defer block.first.locate()
+
+	words := []string{}
+	for i := range block.first.inlineArgs {
+		words = append(words, block.first.wordArg(i))
+	}
+	name := ""
+	if len(words) > 0 && words[0] != "after" {
+		name = words[0]
+		words = words[1:]
+	}
+	var after []string
+	if len(words) > 0 {
+		if words[0] != "after" {
+			panic(fmt.Errorf("expecting after, found %s", words[0]))
+		}
+		after = words[1:]
+	}
+	seq.setStep(name, after)
+}
+
 func blockToProofCommand(block Block, scope *LocalScope) ProofCommand {
+	defer block.first.locate()
+
 	switch block.first.operator {
 	case "block":
 		return &BlockProofCommand{
-			label: block.first.label,
-			seq:   blocksToSequenceProof(block.body),
+			label:  block.first.label,
+			using:  block.first.usingArgs(0),
+			seq:    blocksToSequenceProof(block.body),
+			source: block.first.source(),
 		}
 	case "each":
 		subs := []VerbatimOrState{}
@@ -321,23 +500,26 @@ func blockToProofCommand(block Block, scope *LocalScope) ProofCommand {
 			label:  block.first.label,
 			states: states,
 			seq:    blocksToSequenceProof(block.body),
+			source: block.first.source(),
 		}
 	case "lemma":
-		block.first.fixArgs(1)
 		return &LemmaProofCommand{
-			label: block.first.label,
-			name:  block.first.wordArg(0),
+			label:  block.first.label,
+			using:  block.first.usingArgs(1),
+			name:   block.first.wordArg(0),
+			source: block.first.source(),
 		}
 	case "have":
-		block.first.fixArgs(1)
 		return &HaveProofCommand{
 			label:     block.first.label,
+			using:     block.first.usingArgs(1),
 			condition: block.first.verbatimArg(0),
 			helper:    blocksToProofHelper(block.body),
+			source:    block.first.source(),
 		}
 	case "cond":
 		block.first.fixArgs(1)
-		scope.conditions = append(scope.conditions, block.first.verbatimArg(0))
+		scope.addCondition(&block.first)
 		return nil
 	case "state":
 		block.first.fixArgs(2)
@@ -348,6 +530,7 @@ func blockToProofCommand(block Block, scope *LocalScope) ProofCommand {
 		return &UseProofCommand{
 			name:   block.first.wordArg(0),
 			helper: blocksToProofHelper(block.body),
+			source: block.first.source(),
 		}
 	case "graph_induction":
 		block.first.fixArgs(0)
@@ -360,26 +543,45 @@ func blockToProofCommand(block Block, scope *LocalScope) ProofCommand {
 }
 
 func blocksToProofDocument(blocks []Block) ProofDocument {
-	lemmas := make(map[string]Lemma, 0)
-	defs := make(map[string]SequencedProofSteps, 0)
-
+	doc := NewProofDocument()
 	for _, block := range blocks {
-		switch block.first.operator {
-		case "lemma":
-			block.first.fixArgs(1)
-			name := block.first.wordArg(0)
-			lemmas[name] = Lemma{
-				label: block.first.label,
-				name:  name,
-				seq:   blocksToSequenceProof(block.body),
-			}
-		case "def":
-			block.first.fixArgs(1)
-			defs[block.first.wordArg(0)] = blocksToSequenceProof(block.body)
-		default:
-			panic(fmt.Errorf("bad first operator: %s", block.first.operator))
-		}
+		doc.addBlock(block)
 	}
+	return doc
+}
 
-	return ProofDocument{lemmas: lemmas, defs: defs}
+func (doc *ProofDocument) addBlock(block Block) {
+	defer block.first.locate()
+
+	switch block.first.operator {
+	case "lemma":
+		block.first.fixArgs(1)
+		name := block.first.wordArg(0)
+		doc.lemmas[name] = Lemma{
+			label: block.first.label,
+			name:  name,
+			seq:   blocksToSequenceProof(block.body),
+		}
+	case "def":
+		block.first.fixArgs(1)
+		doc.defs[block.first.wordArg(0)] = blocksToSequenceProof(block.body)
+	case "state":
+		block.first.fixArgs(2)
+		doc.addState(block.first.wordArg(0), GlobalState{value: block.first.verbatimArg(1), source: block.first.source()})
+	case "import":
+		block.first.fixArgs(3)
+		path, err := strconv.Unquote(block.first.wordArg(0))
+		if err != nil || block.first.wordArg(1) != "as" {
+			panic(fmt.Errorf("malformed import, expecting import \"path\" as name"))
+		}
+		alias := block.first.wordArg(2)
+		if strings.Contains(alias, ".") {
+			panic(fmt.Errorf("import name %s cannot contain a .", alias))
+		}
+		doc.imports = append(doc.imports, Import{path: path, alias: alias, source: block.first.source()})
+	case "cond":
+		panic(fmt.Errorf("cond must be within a lemma or def, only states can be declared outside of them"))
+	default:
+		panic(fmt.Errorf("bad first operator: %s", block.first.operator))
+	}
 }
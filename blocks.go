@@ -8,18 +8,38 @@ import (
 
 type CommandArg interface {
 	toString() string
+	toSource() string
 	toVerbatimOrState() VerbatimOrState
 }
 
+func labelSource(label string) string {
+	if label == "" {
+		return ""
+	}
+	return label + ": "
+}
+
+func argLabelSource(label string) string {
+	if label == "" {
+		return ""
+	}
+	return label + ":"
+}
+
 type WordArg struct {
 	label string
 	word  string
+	col   int // Offset of the word from the start of the command
 }
 
 func (word *WordArg) toString() string {
 	return word.word
 }
 
+func (word *WordArg) toSource() string {
+	return argLabelSource(word.label) + word.word
+}
+
 func (word *WordArg) toVerbatimOrState() VerbatimOrState {
 	return VerbatimOrState{
 		label:    word.label,
@@ -37,6 +57,10 @@ func (verbatim *VerbatimCommandArg) toString() string {
 	return "[[" + "]]"
 }
 
+func (verbatim *VerbatimCommandArg) toSource() string {
+	return argLabelSource(verbatim.label) + "(" + streamToString(trimWhitespace(verbatim.stream)) + ")"
+}
+
 func (verbatim *VerbatimCommandArg) toVerbatimOrState() VerbatimOrState {
 	return VerbatimOrState{
 		label:    verbatim.label,
@@ -60,6 +84,30 @@ type Command struct {
 	inlineArgs   []CommandArg
 	trailingMode TrailingMode
 	trailing     string
+	trailingCol  int
+	file         string
+	line         int // 1-indexed, 0 if not parsed from source
+	col          int
+}
+
+// A panic raised while handling a command, located at its source line
+type SourceError struct {
+	line int
+	err  any
+}
+
+func (err SourceError) Error() string {
+	return fmt.Sprintf("line %d: %v", err.line, err.err)
+}
+
+// Deferred while handling a command, so that any panic is attributed to the line of the command
+func (cmd *Command) locate() {
+	if r := recover(); r != nil {
+		if _, ok := r.(SourceError); ok || cmd.line == 0 {
+			panic(r)
+		}
+		panic(SourceError{line: cmd.line, err: r})
+	}
 }
 
 func (cmd *Command) hasFlag(flag string) bool {
@@ -107,7 +155,7 @@ func (cmd *Command) nowWordArray() []string {
 	if cmd.trailingMode != TRAILING_NOW {
 		panic(fmt.Errorf("malformed arguments, expected trailing now array to %s", cmd.operator))
 	}
-	return strings.Split(cmd.trailing, " ")
+	return strings.Fields(cmd.trailing)
 }
 
 func (cmd *Command) stepWordArray() []string {
@@ -117,7 +165,20 @@ func (cmd *Command) stepWordArray() []string {
 	if cmd.trailingMode != TRAILING_STEP {
 		panic(fmt.Errorf("malformed arguments, expected trailing step array to %s", cmd.operator))
 	}
-	return strings.Split(cmd.trailing, " ")
+	return strings.Fields(cmd.trailing)
+}
+
+// Checks there are n arguments, optionally followed by `using a b`, returning the names used or nil if there are none
+func (cmd *Command) usingArgs(n int) []string {
+	if len(cmd.inlineArgs) > n+1 && cmd.inlineArgs[n].toString() == "using" {
+		names := []string{}
+		for i := n + 1; i < len(cmd.inlineArgs); i++ {
+			names = append(names, cmd.wordArg(i))
+		}
+		return names
+	}
+	cmd.fixArgs(n)
+	return nil
 }
 
 func (cmd *Command) fixArgs(n int) {
@@ -130,6 +191,64 @@ func (cmd *Command) fixArgs(n int) {
 	}
 }
 
+func (cmd *Command) toSource() string {
+	str := labelSource(cmd.label) + cmd.operator
+	for _, flag := range cmd.flags {
+		str += " +" + flag
+	}
+	for _, arg := range cmd.inlineArgs {
+		str += " " + arg.toSource()
+	}
+	switch cmd.trailingMode {
+	case TRAILING_NOW:
+		str += " -> " + strings.Join(strings.Fields(cmd.trailing), " ")
+	case TRAILING_STEP:
+		str += " => " + strings.Join(strings.Fields(cmd.trailing), " ")
+	}
+	return str
+}
+
+// Like toSource, but with verbatim arguments laid out by formatStreamLines, which breaks them over several lines when the
+// command is too long. Every line but the last ends in a continuation.
+func (cmd *Command) toSourceLines(indent int, lineWidth int) []string {
+	fits := indent*4+len(cmd.toSource()) <= lineWidth
+	lines := []string{}
+	line := labelSource(cmd.label) + cmd.operator
+	for _, flag := range cmd.flags {
+		line += " +" + flag
+	}
+	for _, arg := range cmd.inlineArgs {
+		verbatim, ok := arg.(*VerbatimCommandArg)
+		if !ok {
+			line += " " + arg.toSource()
+			continue
+		}
+
+		width := lineWidth - (indent+1)*4
+		if fits || indent*4+len(line)+1+len(arg.toSource()) <= lineWidth {
+			width = lineWidth
+		}
+		line += " "
+		line += argLabelSource(verbatim.label) + "("
+		for i, sub := range formatStreamLines(trimWhitespace(verbatim.stream), width) {
+			if i == 0 {
+				line += sub
+				continue
+			}
+			lines = append(lines, line+" \\")
+			line = "    " + sub
+		}
+		line += ")"
+	}
+	switch cmd.trailingMode {
+	case TRAILING_NOW:
+		line += " -> " + strings.Join(strings.Fields(cmd.trailing), " ")
+	case TRAILING_STEP:
+		line += " => " + strings.Join(strings.Fields(cmd.trailing), " ")
+	}
+	return append(lines, line)
+}
+
 func parseLabel(str string) (string, string) {
 	labelRest := strings.SplitN(str, ":", 2)
 	if len(labelRest) > 1 {
@@ -184,6 +303,7 @@ func parseArg(str string) (string, CommandArg) {
 }
 
 func parseCommand(str string) Command {
+	full := str
 	label, rest := parseLabel(str)
 	operatorRest := strings.SplitN(rest, " ", 2)
 
@@ -196,6 +316,7 @@ func parseCommand(str string) Command {
 	inlineArgs := make([]CommandArg, 0)
 	flags := make([]string, 0)
 	trailing := ""
+	trailingCol := 0
 	trailingMode := TRAILING_NONE
 	i := 0
 	for i < len(str) {
@@ -206,10 +327,12 @@ func parseCommand(str string) Command {
 
 		if strings.HasPrefix(str[i:], "=>") {
 			trailing = strings.Trim(str[i+2:], " \t")
+			trailingCol = len(full) - len(strings.TrimLeft(str[i+2:], " \t"))
 			trailingMode = TRAILING_STEP
 			break
 		} else if strings.HasPrefix(str[i:], "->") {
 			trailing = strings.Trim(str[i+2:], " \t")
+			trailingCol = len(full) - len(strings.TrimLeft(str[i+2:], " \t"))
 			trailingMode = TRAILING_NOW
 			break
 		} else if strings.HasPrefix(str[i:], "+") {
@@ -221,6 +344,9 @@ func parseCommand(str string) Command {
 			flags = append(flags, str[start:i])
 		} else {
 			newStr, arg := parseArg(str[i:])
+			if word, ok := arg.(*WordArg); ok {
+				word.col = len(full) - len(newStr) - len(word.word)
+			}
 			str = newStr
 			inlineArgs = append(inlineArgs, arg)
 			i = 0
@@ -233,13 +359,18 @@ func parseCommand(str string) Command {
 		inlineArgs:   inlineArgs,
 		flags:        flags,
 		trailing:     trailing,
+		trailingCol:  trailingCol,
 		trailingMode: trailingMode,
 	}
 }
 
 type Block struct {
-	first Command
-	body  []Block
+	first       Command
+	body        []Block
+	blankBefore bool
+	comments    []string // Comment lines preceding the command, empty for blank lines between them
+	comment     string   // Comment trailing the command on the same line
+	after       []string // Comment lines following the command and its body, at the same depth
 }
 
 func lineDepth(line string) int {
@@ -263,22 +394,92 @@ func parenParenNestingDepth(str string) int {
 	return depth
 }
 
+type pendingComment struct {
+	line  int
+	depth int
+	text  string
+}
+
 func parseBlocks(lines []string, parentDepth int) (int, []Block) {
+	return parseBlocksFrom(lines, 0, parentDepth)
+}
+
+// As parseCommand, but panics are located at the given line
+func parseCommandAt(str string, line int, col int) Command {
+	defer func() {
+		if r := recover(); r != nil {
+			panic(SourceError{line: line, err: r})
+		}
+	}()
+
+	cmd := parseCommand(str)
+	cmd.line = line
+	cmd.col = col
+	return cmd
+}
+
+// Comments and blank lines are kept on the blocks they surround, they are otherwise ignored. Lines are numbered from
+// offset.
+func parseBlocksFrom(lines []string, offset int, parentDepth int) (int, []Block) {
 	blocks := make([]Block, 0)
 	l := 0
 	nestedDepth := -1
+	consumed := 0
+	pending := []pendingComment{}
+	blankBefore := false
+
+	// Comments after the last block at this depth are kept by the last block, any other comments and blank lines are
+	// left for the parent
+	finish := func() int {
+		after := []string{}
+		for _, comment := range pending {
+			if comment.text == "" {
+				after = append(after, "")
+				continue
+			}
+			if comment.depth < nestedDepth || len(blocks) == 0 {
+				break
+			}
+			after = append(after, comment.text)
+			consumed = comment.line + 1
+		}
+		for len(after) > 0 && after[len(after)-1] == "" {
+			after = after[:len(after)-1]
+		}
+		if len(after) > 0 {
+			blocks[len(blocks)-1].after = append(blocks[len(blocks)-1].after, after...)
+		}
+		return consumed
+	}
+
 	for l < len(lines) {
 		line := lines[l]
 
+		comment := ""
 		preComment := strings.SplitN(line, "# ", 2)
 		if len(preComment) > 1 {
 			line = preComment[0]
+			comment = "# " + strings.TrimRight(preComment[1], " \t")
 		}
 
 		lineDepth := lineDepth(line)
 		line = strings.Trim(line, " \t")
 
 		if len(line) == 0 || line == "#" {
+			if line == "#" {
+				comment = line
+			}
+			if comment != "" {
+				pending = append(pending, pendingComment{
+					line:  l,
+					depth: strings.Index(lines[l], "#"),
+					text:  comment,
+				})
+			} else if len(pending) != 0 {
+				pending = append(pending, pendingComment{line: l})
+			} else if len(blocks) != 0 {
+				blankBefore = true
+			}
 			l += 1
 			continue
 		}
@@ -288,18 +489,24 @@ func parseBlocks(lines []string, parentDepth int) (int, []Block) {
 		}
 
 		if lineDepth <= parentDepth {
-			return l, blocks
+			return finish(), blocks
 		}
 
 		if lineDepth > nestedDepth {
-			panic(fmt.Errorf("unexpected indent"))
+			panic(SourceError{line: offset + l + 1, err: fmt.Errorf("unexpected indent")})
 		}
 
+		start := l
 		for l < len(lines) {
+			continued := strings.HasSuffix(line, "\\") || strings.HasSuffix(line, ":") || parenParenNestingDepth(line) > 0
+			if continued && l+1 >= len(lines) {
+				panic(SourceError{line: offset + start + 1, err: fmt.Errorf("unterminated command")})
+			}
+
 			if strings.HasSuffix(line, "\\") {
 				l += 1
 				line = line[:len(line)-1] + strings.Trim(lines[l], " \t")
-			} else if strings.HasSuffix(line, ":") || parenParenNestingDepth(line) > 0 {
+			} else if continued {
 				l += 1
 				line = line + strings.Trim(lines[l], " \t")
 			} else {
@@ -307,16 +514,68 @@ func parseBlocks(lines []string, parentDepth int) (int, []Block) {
 			}
 		}
 
-		incL, body := parseBlocks(lines[l+1:], nestedDepth)
+		incL, body := parseBlocksFrom(lines[l+1:], offset+l+1, nestedDepth)
+
+		comments := []string{}
+		for _, comment := range pending {
+			comments = append(comments, comment.text)
+		}
 
 		blocks = append(blocks, Block{
-			first: parseCommand(line),
-			body:  body,
+			first:       parseCommandAt(line, offset+start+1, lineDepth),
+			body:        body,
+			blankBefore: blankBefore,
+			comments:    comments,
+			comment:     comment,
 		})
+		pending = []pendingComment{}
+		blankBefore = false
 
 		l += 1 + incL
+		consumed = l
+	}
+	return finish(), blocks
+}
+
+func commentLinesSource(comments []string, pad string) string {
+	str := ""
+	for _, comment := range comments {
+		if comment == "" {
+			str += "\n"
+		} else {
+			str += pad + comment + "\n"
+		}
+	}
+	return str
+}
+
+// Each block is formatted at indent*4 spaces, with long expressions broken across lines using formatStream
+func blocksToSource(blocks []Block, indent int, lineWidth int) string {
+	str := ""
+	pad := strings.Repeat(" ", indent*4)
+	for i, block := range blocks {
+		if block.blankBefore && i != 0 {
+			str += "\n"
+		}
+		str += commentLinesSource(block.comments, pad)
+
+		lines := block.first.toSourceLines(indent, lineWidth)
+		if block.comment != "" {
+			if len(lines) == 1 {
+				lines[0] += " " + block.comment
+			} else {
+				// Continuation lines cannot hold comments
+				str += pad + block.comment + "\n"
+			}
+		}
+		for _, line := range lines {
+			str += pad + line + "\n"
+		}
+
+		str += blocksToSource(block.body, indent+1, lineWidth)
+		str += commentLinesSource(block.after, pad)
 	}
-	return l, blocks
+	return str
 }
 
 // func dumpBlock(blocks []Block, indent int) {
@@ -0,0 +1,604 @@
+package main
+
+import (
+	"fmt"
+	"maps"
+	"slices"
+	"strconv"
+	"strings"
+)
+
+// Construction of proof documents directly, as an alternative to parsing .proof source. The
+// structures produced are identical to those produced by blocksToProofDocument.
+
+func SvExpr(sv string) TokenStream {
+	rest, stream := tokenize(sv)
+	if rest != "" {
+		panic(fmt.Errorf("failed to parse systemverilog: %s", sv))
+	}
+	return stream
+}
+
+func Verbatim(label string, sv string) VerbatimOrState {
+	return VerbatimOrState{
+		label:    label,
+		stream:   SvExpr(sv),
+		verbatim: true,
+	}
+}
+
+// Refers to a state, or inside a graph induction node to a named invariant
+func StateRef(label string, name string) VerbatimOrState {
+	return VerbatimOrState{
+		label:    label,
+		state:    name,
+		verbatim: false,
+	}
+}
+
+func helperOf(helpers []ProofHelper) ProofHelper {
+	if len(helpers) == 1 {
+		return helpers[0]
+	}
+	return &SequenceProofHelper{helpers: slices.Clone(helpers)}
+}
+
+func SplitCase(label string, condition VerbatimOrState, helpers ...ProofHelper) SplitProofCase {
+	return SplitProofCase{
+		label:     label,
+		condition: condition,
+		helper:    helperOf(helpers),
+	}
+}
+
+func SplitHelper(check bool, cases ...SplitProofCase) ProofHelper {
+	return &SplitProofHelper{
+		check: check,
+		cases: cases,
+	}
+}
+
+func SplitBoolHelper(pivots []VerbatimOrState, helpers ...ProofHelper) ProofHelper {
+	return &SplitBoolProofHelper{
+		pivots: pivots,
+		helper: helperOf(helpers),
+	}
+}
+
+func KInductionHelper(label string, k int) ProofHelper {
+	return &KInductionProofHelper{
+		label:    label,
+		k:        k,
+		wireSets: []string{},
+	}
+}
+
+type DocumentBuilder struct {
+	defs    map[string]*SequencedProofSteps
+	lemmas  map[string]*Lemma
+	states  map[string]TokenStream
+	imports []Import
+}
+
+func NewDocumentBuilder() *DocumentBuilder {
+	return &DocumentBuilder{
+		defs:    map[string]*SequencedProofSteps{},
+		lemmas:  map[string]*Lemma{},
+		states:  map[string]TokenStream{},
+		imports: []Import{},
+	}
+}
+
+// Equivalent to `import "path" as alias`, so that the lemmas and defs of the file can be used as alias.name
+func (builder *DocumentBuilder) Import(path string, alias string) *DocumentBuilder {
+	builder.imports = append(builder.imports, Import{path: path, alias: alias})
+	return builder
+}
+
+// Equivalent to a `state` outside of any lemma or def, visible to all of them
+func (builder *DocumentBuilder) State(name string, value TokenStream) *DocumentBuilder {
+	builder.states[name] = value
+	return builder
+}
+
+func (builder *DocumentBuilder) Lemma(label string, name string) *StepsBuilder {
+	lemma := &Lemma{
+		label: label,
+		name:  name,
+		seq:   NewSequencedProofSteps(),
+	}
+	builder.lemmas[name] = lemma
+	return &StepsBuilder{seq: &lemma.seq}
+}
+
+func (builder *DocumentBuilder) Def(name string) *StepsBuilder {
+	seq := NewSequencedProofSteps()
+	builder.defs[name] = &seq
+	return &StepsBuilder{seq: &seq}
+}
+
+func (builder *DocumentBuilder) Build() ProofDocument {
+	doc := NewProofDocument()
+	doc.imports = append(doc.imports, builder.imports...)
+	for name, value := range builder.states {
+		doc.states[name] = GlobalState{value: value}
+	}
+	for name, seq := range builder.defs {
+		doc.defs[name] = *seq
+	}
+	for name, lemma := range builder.lemmas {
+		doc.lemmas[name] = *lemma
+	}
+	return doc
+}
+
+type StepsBuilder struct {
+	seq *SequencedProofSteps
+}
+
+func (builder *StepsBuilder) add(cmd ProofCommand) {
+	last := len(builder.seq.sequence) - 1
+	builder.seq.sequence[last] = append(builder.seq.sequence[last], cmd)
+}
+
+// Equivalent to `/`, later commands are sequenced after those already added
+func (builder *StepsBuilder) Step() *StepsBuilder {
+	builder.seq.nextStep()
+	return builder
+}
+
+// Equivalent to `/ name after a b`, later commands form a named step sequenced only after the given earlier steps
+func (builder *StepsBuilder) StepAfter(name string, after ...string) *StepsBuilder {
+	builder.seq.nextStep()
+	builder.seq.setStep(name, append([]string{}, after...))
+	return builder
+}
+
+// Equivalent to `using a b` on the last have, lemma or block added, which then assumes only the named properties
+func (builder *StepsBuilder) Using(names ...string) *StepsBuilder {
+	last := builder.seq.sequence[len(builder.seq.sequence)-1]
+	if len(last) == 0 {
+		panic(fmt.Errorf("using without a command"))
+	}
+	switch cmd := last[len(last)-1].(type) {
+	case *HaveProofCommand:
+		cmd.using = append([]string{}, names...)
+	case *LemmaProofCommand:
+		cmd.using = append([]string{}, names...)
+	case *BlockProofCommand:
+		cmd.using = append([]string{}, names...)
+	default:
+		panic(fmt.Errorf("using cannot apply to %T", cmd))
+	}
+	return builder
+}
+
+func (builder *StepsBuilder) Cond(cond TokenStream) *StepsBuilder {
+	builder.seq.scope.conditions = append(builder.seq.scope.conditions, cond)
+	return builder
+}
+
+func (builder *StepsBuilder) State(name string, value TokenStream) *StepsBuilder {
+	builder.seq.scope.states[name] = value
+	return builder
+}
+
+func (builder *StepsBuilder) Have(label string, condition TokenStream, helpers ...ProofHelper) *StepsBuilder {
+	builder.add(&HaveProofCommand{
+		label:     label,
+		condition: condition,
+		helper:    helperOf(helpers),
+	})
+	return builder
+}
+
+func (builder *StepsBuilder) Lemma(label string, name string) *StepsBuilder {
+	builder.add(&LemmaProofCommand{
+		label: label,
+		name:  name,
+	})
+	return builder
+}
+
+func (builder *StepsBuilder) Use(name string, helpers ...ProofHelper) *StepsBuilder {
+	builder.add(&UseProofCommand{
+		name:   name,
+		helper: helperOf(helpers),
+	})
+	return builder
+}
+
+// Adds the graph, which later calls on its builder go on changing
+func (builder *StepsBuilder) GraphInduction(graph *GraphInductionBuilder) *StepsBuilder {
+	cmd := &GraphInductionProofCommand{
+		proof: *graph.graph,
+	}
+	graph.graph = &cmd.proof
+	builder.add(cmd)
+	return builder
+}
+
+// Returns a builder for the body of the new block
+func (builder *StepsBuilder) Block(label string) *StepsBuilder {
+	cmd := &BlockProofCommand{
+		label: label,
+		seq:   NewSequencedProofSteps(),
+	}
+	builder.add(cmd)
+	return &StepsBuilder{seq: &cmd.seq}
+}
+
+// Returns a builder for the body of the new in command
+func (builder *StepsBuilder) In(label string, states ...VerbatimOrState) *StepsBuilder {
+	cmd := &InStatesSubProofCommand{
+		label:  label,
+		states: states,
+		seq:    NewSequencedProofSteps(),
+	}
+	builder.add(cmd)
+	return &StepsBuilder{seq: &cmd.seq}
+}
+
+// Returns a builder for the body of the new each command
+func (builder *StepsBuilder) Each(label string, ident string, subs ...VerbatimOrState) *StepsBuilder {
+	cmd := &EachProofCommand{
+		label: label,
+		ident: ident,
+		subs:  subs,
+		seq:   NewSequencedProofSteps(),
+	}
+	builder.add(cmd)
+	return &StepsBuilder{seq: &cmd.seq}
+}
+
+type GraphInductionBuilder struct {
+	graph *GraphInductionProofHelper
+}
+
+func NewGraphInductionBuilder(label string) *GraphInductionBuilder {
+	return &GraphInductionBuilder{
+		graph: &GraphInductionProofHelper{
+			label:          label,
+			invariants:     map[string]TokenStream{},
+			entryCondition: nil,
+			entryNodes:     []string{},
+			entryHelper:    NopProofHelper(),
+			nodes:          map[string]GraphInductionNodeDefinition{},
+			scope:          NewLocalScope(),
+		},
+	}
+}
+
+// Equivalent to +rev
+func (builder *GraphInductionBuilder) Rev() *GraphInductionBuilder {
+	builder.graph.backward = true
+	return builder
+}
+
+// Equivalent to +complete
+func (builder *GraphInductionBuilder) Complete() *GraphInductionBuilder {
+	builder.graph.complete = true
+	return builder
+}
+
+// Equivalent to +onehot
+func (builder *GraphInductionBuilder) OneHot() *GraphInductionBuilder {
+	builder.graph.onehot = true
+	return builder
+}
+
+// Equivalent to +cover
+func (builder *GraphInductionBuilder) Cover() *GraphInductionBuilder {
+	builder.graph.cover = true
+	return builder
+}
+
+func (builder *GraphInductionBuilder) Cond(cond TokenStream) *GraphInductionBuilder {
+	builder.graph.scope.conditions = append(builder.graph.scope.conditions, cond)
+	return builder
+}
+
+func (builder *GraphInductionBuilder) Inv(name string, value TokenStream) *GraphInductionBuilder {
+	builder.graph.invariants[name] = value
+	return builder
+}
+
+func (builder *GraphInductionBuilder) Entry(condition TokenStream, nodes []string, helpers ...ProofHelper) *GraphInductionBuilder {
+	builder.graph.entryCondition = condition
+	builder.graph.entryNodes = append(builder.graph.entryNodes, nodes...)
+	builder.graph.entryHelper = helperOf(helpers)
+	return builder
+}
+
+// The invariant is either verbatim, or a StateRef naming an invariant given to Inv
+func (builder *GraphInductionBuilder) Node(name string, exit bool, invariant VerbatimOrState, condition VerbatimOrState, helpers ...ProofHelper) *GraphInductionBuilder {
+	builder.graph.nodes[name] = GraphInductionNodeDefinition{
+		exit:            exit,
+		invariant:       invariant,
+		condition:       condition,
+		stepTransitions: []string{},
+		helper:          helperOf(helpers),
+	}
+	return builder
+}
+
+// Equivalent to `edge src => dsts`
+func (builder *GraphInductionBuilder) StepEdge(src string, dsts ...string) *GraphInductionBuilder {
+	node := builder.graph.nodes[src]
+	node.stepTransitions = append(node.stepTransitions, dsts...)
+	builder.graph.nodes[src] = node
+	return builder
+}
+
+// Equivalent to `edge src -> dsts`
+func (builder *GraphInductionBuilder) EpsEdge(src string, dsts ...string) *GraphInductionBuilder {
+	node := builder.graph.nodes[src]
+	node.epsTransitions = append(node.epsTransitions, dsts...)
+	builder.graph.nodes[src] = node
+	return builder
+}
+
+// Conversion back to blocks, so that constructed proofs can be printed as .proof source
+
+func vosToArg(vos VerbatimOrState) CommandArg {
+	if vos.verbatim {
+		return &VerbatimCommandArg{label: vos.label, stream: vos.stream}
+	}
+	return &WordArg{label: vos.label, word: vos.state}
+}
+
+// Appends a using clause to a command's arguments
+func usingToArgs(args []CommandArg, using []string) []CommandArg {
+	if using == nil {
+		return args
+	}
+	args = append(args, &WordArg{word: "using"})
+	for _, name := range using {
+		args = append(args, &WordArg{word: name})
+	}
+	return args
+}
+
+func streamBlock(operator string, args ...CommandArg) Block {
+	return Block{
+		first: Command{operator: operator, inlineArgs: args},
+		body:  []Block{},
+	}
+}
+
+func localScopeToBlocks(scope *LocalScope) []Block {
+	blocks := []Block{}
+	for _, name := range slices.Sorted(maps.Keys(scope.states)) {
+		blocks = append(blocks, streamBlock("state", &WordArg{word: name}, &VerbatimCommandArg{stream: scope.states[name]}))
+	}
+	for _, cond := range scope.conditions {
+		blocks = append(blocks, streamBlock("cond", &VerbatimCommandArg{stream: cond}))
+	}
+	return blocks
+}
+
+func (seq *SequencedProofSteps) toBlocks() []Block {
+	blocks := localScopeToBlocks(&seq.scope)
+	for i, step := range seq.sequence {
+		args := []CommandArg{}
+		if seq.names[i] != "" {
+			args = append(args, &WordArg{word: seq.names[i]})
+		}
+		if seq.after[i] != nil && (i != 0 || len(seq.after[i]) != 0) {
+			args = append(args, &WordArg{word: "after"})
+			for _, j := range seq.after[i] {
+				args = append(args, &WordArg{word: seq.names[j]})
+			}
+		}
+		if i != 0 || len(args) != 0 {
+			blocks = append(blocks, streamBlock("/", args...))
+		}
+		for _, cmd := range step {
+			blocks = append(blocks, proofCommandToBlock(cmd))
+		}
+	}
+	return blocks
+}
+
+func proofCommandToBlock(cmd ProofCommand) Block {
+	switch cmd := cmd.(type) {
+	case *BlockProofCommand:
+		return Block{
+			first: Command{label: cmd.label, operator: "block", inlineArgs: usingToArgs([]CommandArg{}, cmd.using)},
+			body:  cmd.seq.toBlocks(),
+		}
+	case *EachProofCommand:
+		args := []CommandArg{&WordArg{word: cmd.ident}}
+		for _, sub := range cmd.subs {
+			args = append(args, vosToArg(sub))
+		}
+		return Block{
+			first: Command{label: cmd.label, operator: "each", inlineArgs: args},
+			body:  cmd.seq.toBlocks(),
+		}
+	case *InStatesSubProofCommand:
+		args := []CommandArg{}
+		for _, state := range cmd.states {
+			args = append(args, vosToArg(state))
+		}
+		return Block{
+			first: Command{label: cmd.label, operator: "in", inlineArgs: args},
+			body:  cmd.seq.toBlocks(),
+		}
+	case *LemmaProofCommand:
+		return Block{
+			first: Command{label: cmd.label, operator: "lemma", inlineArgs: usingToArgs([]CommandArg{&WordArg{word: cmd.name}}, cmd.using)},
+			body:  []Block{},
+		}
+	case *HaveProofCommand:
+		return Block{
+			first: Command{label: cmd.label, operator: "have", inlineArgs: usingToArgs([]CommandArg{&VerbatimCommandArg{stream: cmd.condition}}, cmd.using)},
+			body:  proofHelperToBlocks(cmd.helper),
+		}
+	case *UseProofCommand:
+		return Block{
+			first: Command{operator: "use", inlineArgs: []CommandArg{&WordArg{word: cmd.name}}},
+			body:  proofHelperToBlocks(cmd.helper),
+		}
+	case *GraphInductionProofCommand:
+		return cmd.proof.toBlock()
+	default:
+		panic(fmt.Errorf("cannot print proof command %T", cmd))
+	}
+}
+
+func proofHelperToBlocks(helper ProofHelper) []Block {
+	switch helper := helper.(type) {
+	case *SequenceProofHelper:
+		blocks := []Block{}
+		for _, sub := range helper.helpers {
+			blocks = append(blocks, proofHelperToBlocks(sub)...)
+		}
+		return blocks
+	case *SplitProofHelper:
+		block := Block{
+			first: Command{operator: "split"},
+			body:  []Block{},
+		}
+		if !helper.check {
+			block.first.flags = []string{"nocheck"}
+		}
+		for i, cas := range helper.cases {
+			label := cas.label
+			if label == "" {
+				// Unlabelled cases are named by their index, which must be preserved
+				label = "Case" + strconv.Itoa(i)
+			}
+			block.body = append(block.body, Block{
+				first: Command{label: label, operator: "case", inlineArgs: []CommandArg{vosToArg(cas.condition)}},
+				body:  proofHelperToBlocks(cas.helper),
+			})
+		}
+		return []Block{block}
+	case *SplitBoolProofHelper:
+		args := []CommandArg{}
+		for _, pivot := range helper.pivots {
+			args = append(args, vosToArg(pivot))
+		}
+		return []Block{{
+			first: Command{operator: "split_bool", inlineArgs: args},
+			body:  proofHelperToBlocks(helper.helper),
+		}}
+	case *KInductionProofHelper:
+		return []Block{{
+			first: Command{label: helper.label, operator: "k_induction", inlineArgs: []CommandArg{&WordArg{word: strconv.Itoa(helper.k)}}},
+			body:  []Block{},
+		}}
+	case *GraphInductionProofHelper:
+		return []Block{helper.toBlock()}
+	default:
+		panic(fmt.Errorf("cannot print proof helper %T", helper))
+	}
+}
+
+func (cmd *GraphInductionProofHelper) toBlock() Block {
+	block := Block{
+		first: Command{label: cmd.label, operator: "graph_induction", flags: []string{}},
+		body:  []Block{},
+	}
+	if cmd.backward {
+		block.first.flags = append(block.first.flags, "rev")
+	}
+	if cmd.complete {
+		block.first.flags = append(block.first.flags, "complete")
+	}
+	if cmd.onehot {
+		block.first.flags = append(block.first.flags, "onehot")
+	}
+	if cmd.cover {
+		block.first.flags = append(block.first.flags, "cover")
+	}
+
+	for _, cond := range cmd.scope.conditions {
+		block.body = append(block.body, streamBlock("cond", &VerbatimCommandArg{stream: cond}))
+	}
+	for _, name := range slices.Sorted(maps.Keys(cmd.invariants)) {
+		block.body = append(block.body, streamBlock("inv", &WordArg{word: name}, &VerbatimCommandArg{stream: cmd.invariants[name]}))
+	}
+
+	if cmd.entryCondition != nil {
+		entry := streamBlock("entry", &VerbatimCommandArg{stream: cmd.entryCondition})
+		if len(cmd.entryNodes) > 0 {
+			entry.first.trailingMode = TRAILING_NOW
+			entry.first.trailing = strings.Join(cmd.entryNodes, " ")
+		}
+		entry.body = proofHelperToBlocks(cmd.entryHelper)
+		block.body = append(block.body, entry)
+	}
+
+	edges := []Block{}
+	for _, name := range slices.Sorted(maps.Keys(cmd.nodes)) {
+		node := cmd.nodes[name]
+		def := Block{
+			first: Command{
+				operator:   "node",
+				inlineArgs: []CommandArg{&WordArg{word: name}, vosToArg(node.invariant), vosToArg(node.condition)},
+			},
+			body: proofHelperToBlocks(node.helper),
+		}
+		if node.exit {
+			def.first.flags = []string{"exit"}
+		}
+		if len(node.stepTransitions) > 0 {
+			def.first.trailingMode = TRAILING_STEP
+			def.first.trailing = strings.Join(node.stepTransitions, " ")
+		}
+		if len(node.epsTransitions) > 0 {
+			edge := Block{
+				first: Command{
+					operator:     "edge",
+					inlineArgs:   []CommandArg{&WordArg{word: name}},
+					trailingMode: TRAILING_NOW,
+					trailing:     strings.Join(node.epsTransitions, " "),
+				},
+				body: []Block{},
+			}
+			if def.first.trailingMode == TRAILING_NONE {
+				def.first.trailingMode = edge.first.trailingMode
+				def.first.trailing = edge.first.trailing
+			} else {
+				edges = append(edges, edge)
+			}
+		}
+		block.body = append(block.body, def)
+	}
+	block.body = append(block.body, edges...)
+
+	return block
+}
+
+func (doc *ProofDocument) toBlocks() []Block {
+	blocks := []Block{}
+	for _, imp := range doc.imports {
+		blocks = append(blocks, streamBlock("import", &WordArg{word: strconv.Quote(imp.path)}, &WordArg{word: "as"}, &WordArg{word: imp.alias}))
+	}
+	for _, name := range slices.Sorted(maps.Keys(doc.states)) {
+		blocks = append(blocks, streamBlock("state", &WordArg{word: name}, &VerbatimCommandArg{stream: doc.states[name].value}))
+	}
+	for _, name := range slices.Sorted(maps.Keys(doc.defs)) {
+		seq := doc.defs[name]
+		blocks = append(blocks, Block{
+			first: Command{operator: "def", inlineArgs: []CommandArg{&WordArg{word: name}}},
+			body:  seq.toBlocks(),
+		})
+	}
+	for _, name := range slices.Sorted(maps.Keys(doc.lemmas)) {
+		lemma := doc.lemmas[name]
+		blocks = append(blocks, Block{
+			first: Command{label: lemma.label, operator: "lemma", inlineArgs: []CommandArg{&WordArg{word: name}}},
+			body:  lemma.seq.toBlocks(),
+		})
+	}
+	return blocks
+}
+
+func (doc *ProofDocument) toSource(lineWidth int) string {
+	blocks := doc.toBlocks()
+	separateTopLevel(blocks)
+	return blocksToSource(blocks, 0, lineWidth)
+}
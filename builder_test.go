@@ -0,0 +1,72 @@
+package main
+
+import (
+	"maps"
+	"slices"
+	"strings"
+	"testing"
+)
+
+// A document using every kind of command the builder can add
+func testBuiltDocument() ProofDocument {
+	builder := NewDocumentBuilder()
+	builder.State("busy", SvExpr("valid && !ready"))
+	builder.Def("stable").Have("Stable", SvExpr("$stable(data)"))
+
+	other := builder.Lemma("", "other")
+	other.Have("O", SvExpr("o"))
+
+	lemma := builder.Lemma("", "built")
+	lemma.Cond(SvExpr("en"))
+	lemma.State("local", SvExpr("a || b"))
+	lemma.Have("A", SvExpr("a"), SplitBoolHelper([]VerbatimOrState{Verbatim("", "r"), StateRef("", "local")}))
+	lemma.Have("B", SvExpr("b"), SplitHelper(true,
+		SplitCase("X", Verbatim("", "x")),
+		SplitCase("NotX", Verbatim("", "!x"), KInductionHelper("K", 2)),
+	))
+	lemma.StepAfter("left")
+	lemma.Lemma("L", "other")
+	lemma.Use("stable")
+	lemma.StepAfter("right", "left")
+	lemma.Have("C", SvExpr("c")).Using("A")
+	block := lemma.Block("Blk")
+	block.Have("D", SvExpr("d"))
+	block.Step()
+	block.Have("E", SvExpr("e"))
+	lemma.In("InBusy", StateRef("", "busy")).Have("F", SvExpr("f"))
+	lemma.Each("Each", "i", Verbatim("", "0"), Verbatim("", "1")).Have("G", SvExpr("g[`i]"))
+
+	graph := NewGraphInductionBuilder("G").OneHot()
+	graph.Inv("t", SvExpr("1"))
+	graph.Entry(SvExpr("start"), []string{"idle"})
+	graph.Node("idle", false, StateRef("", "t"), Verbatim("", "!run"))
+	graph.Node("run", true, Verbatim("", "cnt < 4"), Verbatim("", "run"))
+	graph.StepEdge("idle", "run")
+	graph.StepEdge("run", "idle")
+	lemma.GraphInduction(graph)
+	return builder.Build()
+}
+
+func TestBuilderMatchesSource(t *testing.T) {
+	built := testBuiltDocument()
+	source := built.toSource(100)
+	_, blocks := parseBlocks(strings.Split(source, "\n"), -1)
+	parsed := blocksToProofDocument(blocks)
+
+	if again := parsed.toSource(100); again != source {
+		t.Errorf("built document prints as:\n%s\nbut parsing that prints as:\n%s", source, again)
+	}
+	if !slices.Equal(slices.Sorted(maps.Keys(built.lemmas)), slices.Sorted(maps.Keys(parsed.lemmas))) ||
+		!slices.Equal(slices.Sorted(maps.Keys(built.defs)), slices.Sorted(maps.Keys(parsed.defs))) ||
+		!slices.Equal(slices.Sorted(maps.Keys(built.states)), slices.Sorted(maps.Keys(parsed.states))) {
+		t.Fatalf("built document has different names to its source:\n%s", source)
+	}
+	for name := range built.lemmas {
+		if builtSva, parsedSva := lemmaSva(&built, name), lemmaSva(&parsed, name); builtSva != parsedSva {
+			t.Errorf("lemma %s generates:\n%s\nbut from its source:\n%s", name, builtSva, parsedSva)
+		}
+		if strings.HasPrefix(lemmaSva(&built, name), "error:") {
+			t.Errorf("lemma %s fails to generate: %s", name, lemmaSva(&built, name))
+		}
+	}
+}
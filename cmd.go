@@ -3,12 +3,20 @@ package main
 import (
 	"flag"
 	"fmt"
+	"maps"
 	"os"
-	"strings"
+	"path/filepath"
+	"regexp"
+	"slices"
 	"strconv"
 )
 
 var paths []string
+var includes []string
+var defines map[string]string
+var svIncludes []string
+var dutFiles []string
+var dutTop string
 var rootLemma string
 var svOut string
 var tclOut string
@@ -17,21 +25,160 @@ var task bool
 var clocking bool
 var stepPrefix bool
 var listOut string
+var mapOut string
+var watchMode bool
+var manifestIn string
+var manifestOut string
+var changedOut string
+var changedTclOut string
+var slicesOut string
+var onlyPatterns []*regexp.Regexp
+var excludePatterns []*regexp.Regexp
+var shards int
+var resultPaths []string
+var resultsFormat string
+
+// Every file loaded by the last call to loadScope, including those imported
+var sourceFiles []string
+
+// Parses every source file, and those they import, into a single root scope
+func loadScope(paths []string, includes []string, defines map[string]string, svIncludes []string) (Scope, error) {
+	doc := NewProofDocument()
+	imports := map[string]*ProofModule{}
+	macros, err := loadSvMacros(svIncludes)
+	if err != nil {
+		return NewScope(&doc), err
+	}
+	loader := NewModuleLoader(includes, defines, macros)
+	lemmaFiles := map[string]string{}
+	defFiles := map[string]string{}
+
+	for _, path := range paths {
+		module, err := loader.load(path)
+		if err != nil {
+			return NewScope(&doc), err
+		}
+		structure := module.doc
+
+		// Files given by -path share one namespace
+		for _, k := range slices.Sorted(maps.Keys(structure.lemmas)) {
+			if prev, ok := lemmaFiles[k]; ok && prev != path {
+				doc.warnings = append(doc.warnings, fmt.Sprintf("warning: lemma %s in %s replaces the one in %s", k, path, prev))
+			}
+			lemmaFiles[k] = path
+			doc.lemmas[k] = structure.lemmas[k]
+		}
+		for _, k := range slices.Sorted(maps.Keys(structure.defs)) {
+			if prev, ok := defFiles[k]; ok && prev != path {
+				doc.warnings = append(doc.warnings, fmt.Sprintf("warning: def %s in %s replaces the one in %s", k, path, prev))
+			}
+			defFiles[k] = path
+			doc.defs[k] = structure.defs[k]
+		}
+		for _, k := range slices.Sorted(maps.Keys(structure.states)) {
+			doc.addState(k, structure.states[k])
+		}
+		for alias, imported := range module.imports {
+			if prev, ok := imports[alias]; ok && prev != imported {
+				return NewScope(&doc), fmt.Errorf("%s imports %s as %s, but it already imports %s", path, imported.path, alias, prev.path)
+			}
+			imports[alias] = imported
+		}
+	}
+
+	for _, file := range loader.files() {
+		module, _ := loader.load(file)
+		for _, warning := range module.doc.warnings {
+			fmt.Fprintln(os.Stderr, warning)
+		}
+	}
+	sourceFiles = append(loader.files(), svIncludes...)
+	for _, warning := range doc.warnings {
+		fmt.Fprintln(os.Stderr, warning)
+	}
+	scope := NewScope(&doc)
+	scope.env.imports = imports
+	return scope, nil
+}
+
+func genSequence(scope *Scope, rootLemma string) FlatProofSequence {
+	lemma, root, err := scope.lemmaScope(rootLemma)
+	if err != nil {
+		panic(err)
+	}
+	prop := lemma.genProperty(&root)
+	seq := NewFlatProofSequence()
+	prop.flatten(&seq, FlatPosition{after: []int{}})
+	seq.checkNames(scope)
+	seq.checkUsing(scope)
+	seq.simplify(scope)
+	return seq
+}
 
 func main() {
+	if len(os.Args) > 1 {
+		switch os.Args[1] {
+		case "fmt":
+			fmtMain(os.Args[2:])
+			return
+		case "lsp":
+			lspMain(os.Args[2:])
+			return
+		case "report":
+			reportMain(os.Args[2:])
+			return
+		case "html":
+			htmlMain(os.Args[2:])
+			return
+		case "eval":
+			evalMain(os.Args[2:])
+			return
+		case "explain":
+			explainMain(os.Args[2:])
+			return
+		case "mine":
+			mineMain(os.Args[2:])
+			return
+		}
+	}
+
 	paths = []string{}
 	flag.Func("path", "paths to source files", func(s string) error {
 		paths = append(paths, s)
 		return nil
 	})
+	includes = []string{}
+	includeFlag(flag.CommandLine, &includes)
+	defines = map[string]string{}
+	definesFlag(flag.CommandLine, defines)
+	svIncludes = []string{}
+	svIncludeFlag(flag.CommandLine, &svIncludes)
+	dutFiles = []string{}
+	flag.Func("dut", "SystemVerilog source of the design under test, whose declarations every signal in a property is checked against", func(s string) error {
+		dutFiles = append(dutFiles, s)
+		return nil
+	})
+	flag.StringVar(&dutTop, "dut-top", "", "top module of the -dut sources, by default the only module no other instantiates")
 	flag.StringVar(&rootLemma, "root", "", "name of root lemma")
+	flag.Func("only", "glob over property names or label paths (re: for a regular expression) selecting the properties to assert, those they assume are kept as assumptions", patternFlag(&onlyPatterns))
+	flag.Func("exclude", "glob over property names or label paths (re: for a regular expression) of properties not to assert", patternFlag(&excludePatterns))
 	flag.IntVar(&slice, "slice", -1, "select a slice to assert, those leading up to it will be assumed and those after ignored")
+	flag.StringVar(&slicesOut, "slices-out", "", "directory to write the SystemVerilog and TCL of every slice to, with a Makefile proving them in order, or empty to ignore")
 	flag.StringVar(&svOut, "sv-out", "", "path to write generated SystemVerilog to, or empty to ignore")
 	flag.StringVar(&tclOut, "tcl-out", "", "path to write generated TCL to, or empty to ignore")
 	flag.StringVar(&listOut, "list", "", "path to write property list to, or empty to ignore")
+	flag.StringVar(&mapOut, "map", "", "path to write a JSON map from each property to its source to, or empty to ignore")
 	flag.BoolVar(&task, "task", false, "instead of using proof_structure, generate a set of TCL tasks of assumptions and assertions")
 	flag.BoolVar(&clocking, "clocking", false, "produce @(posedge clk_i) disable iff (~rst_ni) in front of each property")
 	flag.BoolVar(&stepPrefix, "step-prefix", false, "Prefix all properties with Step[step number]_")
+	flag.StringVar(&manifestIn, "manifest", "", "path of a previous manifest to compare property hashes against, or empty to treat every property as changed")
+	flag.StringVar(&manifestOut, "manifest-out", "", "path to write a manifest of the hash of each property's proof obligation to, or empty to ignore")
+	flag.StringVar(&changedOut, "changed", "", "path to write the list of properties changed since -manifest to, or empty to ignore")
+	flag.StringVar(&changedTclOut, "changed-tcl", "", "path to write TCL tasks proving only the properties changed since -manifest to, or empty to ignore")
+	flag.IntVar(&shards, "shards", 1, "split the properties of each step into up to this many shards, proved by separate tasks")
+	resultsFlag(flag.CommandLine, &resultPaths)
+	flag.StringVar(&resultsFormat, "results-format", "auto", "format of the -results files giving runtimes to balance shards by: jasper, sby, csv or auto")
+	flag.BoolVar(&watchMode, "watch", false, "keep running, regenerating outputs whenever a source file changes")
 	flag.Parse()
 
 	if len(paths) == 0 {
@@ -44,56 +191,91 @@ func main() {
 		return
 	}
 
-	scope := Scope{
-		lemmas: map[string]Lemma{},
-		stack:  make([]*LocalScope, 0),
-		defs:   map[string]SequencedProofSteps{},
+	if shards > 1 && tclOut != "" && !task {
+		fmt.Println(fmt.Errorf("error: -shards needs -task with -tcl-out, as proof_structure is not sharded"))
+		return
 	}
 
-	for _, path := range paths {
-		data, err := os.ReadFile(path)
-		if err != nil {
-			fmt.Println(err)
-			return
-		}
+	if watchMode {
+		watch()
+		return
+	}
 
-		str := strings.Split(string(data), "\n")
-		_, blocks := parseBlocks(str, -1)
-		structure := blocksToProofDocument(blocks)
+	outputs, _, err := generate()
+	if err != nil {
+		fmt.Println(err)
+		return
+	}
+	for _, path := range slices.Sorted(maps.Keys(outputs)) {
+		if err := writeOutput(path, outputs[path]); err != nil {
+			fmt.Println(fmt.Errorf("error: %v", err))
+		}
+	}
+}
 
-		for k, v := range structure.lemmas {
-			scope.lemmas[k] = v
+// Generates the content of every requested output, and what each property checks by name
+func generate() (map[string]string, map[string]string, error) {
+	scope, err := loadScope(paths, includes, defines, svIncludes)
+	if err != nil {
+		return nil, nil, err
+	}
+	if len(dutFiles) > 0 {
+		sourceFiles = append(sourceFiles, dutFiles...)
+		design, err := loadDesign(dutFiles, dutTop)
+		if err != nil {
+			return nil, nil, err
 		}
-		for k, v := range structure.defs {
-			scope.defs[k] = v
+		scope.env.design = design
+	}
+	full := genSequence(&scope, rootLemma)
+	if scope.env.design != nil {
+		full.checkSignals(scope.env.design)
+	}
+	seq := full
+	if len(onlyPatterns) != 0 || len(excludePatterns) != 0 {
+		seq = full.selectProps(onlyPatterns, excludePatterns)
+	}
+	if shards > 1 {
+		results, err := loadResults(resultPaths, resultsFormat)
+		if err != nil {
+			return nil, nil, err
 		}
+		seq.shard(shards, seq.runtimes(results))
 	}
-
-	lemma, ok := scope.lemmas[rootLemma]
-	if !ok {
-		panic(fmt.Errorf("root lemma %s does not exist", rootLemma))
+	if svOut != "" && slice != -1 {
+		if _, err := seq.sliceAssumptions(slice); err != nil {
+			return nil, nil, err
+		}
 	}
-	prop := lemma.genProperty(&scope)
-	seq := FlatProofSequence{
-		wires: []Wiring{},
-		props: make([][]*Property, 0),
+	if slicesOut != "" {
+		for i := range seq.props {
+			if _, err := seq.sliceAssumptions(i); err != nil {
+				return nil, nil, err
+			}
+		}
 	}
-	prop.flatten(&seq, 0)
-	seq.checkNames()
+	outputs := map[string]string{}
 
 	if svOut != "" {
-		sva := seq.toSva(slice, clocking, stepPrefix, 100)
-		os.WriteFile(svOut, []byte(sva), 0664)
+		outputs[svOut] = seq.toSva(slice, clocking, stepPrefix, 100)
 	}
 
 	if tclOut != "" {
-		var tcl string
 		if task {
-			tcl = seq.toTasks()
+			outputs[tclOut] = seq.toTasks()
 		} else {
-			tcl = seq.toProofStructure()
+			outputs[tclOut] = seq.toProofStructure()
+		}
+	}
+
+	if slicesOut != "" {
+		for path, content := range seq.toSlices(slicesOut, clocking, stepPrefix) {
+			outputs[path] = content
 		}
-		os.WriteFile(tclOut, []byte(tcl), 0664)
+	}
+
+	if mapOut != "" {
+		outputs[mapOut] = seq.toProvenanceMap(stepPrefix)
 	}
 
 	if listOut != "" {
@@ -101,9 +283,78 @@ func main() {
 		for s, step := range seq.props {
 			list += strconv.Itoa(s) + "\n"
 			for _, prop := range step {
-				list += "  " + prop.name + "\n"
+				if !prop.assumed {
+					list += "  " + prop.name + "\n"
+				}
 			}
 		}
-		os.WriteFile(listOut, []byte(list), 0664)
+		outputs[listOut] = list
+	}
+
+	if manifestOut != "" {
+		// Hashes cover every property whatever is selected, so that manifests stay comparable
+		outputs[manifestOut] = full.toManifest()
+	}
+
+	if changedOut != "" || changedTclOut != "" {
+		// Only the changed properties which are selected
+		asserted := map[string]bool{}
+		for _, step := range seq.props {
+			for _, prop := range step {
+				if !prop.assumed {
+					asserted[prop.name] = true
+				}
+			}
+		}
+		previous, err := readManifest(manifestIn)
+		if err != nil {
+			return nil, nil, err
+		}
+		changed := map[string]bool{}
+		for name := range full.changedProperties(previous) {
+			if asserted[name] {
+				changed[name] = true
+			}
+		}
+		total := len(asserted)
+		if changedOut != "" {
+			outputs[changedOut] = seq.toChangedList(changed)
+		}
+		if changedTclOut != "" {
+			outputs[changedTclOut] = fmt.Sprintf("# Proving %d of %d properties, the rest are unchanged since the last manifest\n", len(changed), total) +
+				"# Unchanged properties are assumed where changed ones assume them, relying on their last proofs\n" +
+				seq.toTasksOf(changed)
+		}
+	}
+
+	// Each property's SystemVerilog along with the wires it uses, such as graph node conditions
+	wires := map[string]TokenStream{}
+	for _, wire := range seq.wires {
+		wires[wire.name] = wire.value
+	}
+	props := map[string]string{}
+	for i, step := range seq.props {
+		for _, prop := range step {
+			props[prop.svaName(stepPrefix, i)] = stripSvComments(prop.toSva(false, clocking, stepPrefix, 100, i)) + prop.obligation(wires)
+		}
+	}
+	return outputs, props, nil
+}
+
+func writeOutput(path string, content string) error {
+	if err := os.MkdirAll(filepath.Dir(path), 0775); err != nil {
+		return err
+	}
+	return os.WriteFile(path, []byte(content), 0664)
+}
+
+// Writes a file unless it already has the given content, so that its modification time is only updated on change.
+// Anything but a regular file, such as a pipe, is always written, as reading it back could block or consume it.
+func writeIfChanged(path string, content string) (bool, error) {
+	if info, err := os.Stat(path); err == nil && info.Mode().IsRegular() {
+		if old, err := os.ReadFile(path); err == nil && string(old) == content {
+			return false, nil
+		}
 	}
+	return true, writeOutput(path, content)
 }
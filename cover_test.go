@@ -0,0 +1,96 @@
+package main
+
+import (
+	"slices"
+	"strings"
+	"testing"
+)
+
+const coverProof = `
+lemma l
+  P: have (p)
+  /
+  G: graph_induction +cover
+    inv ok (x)
+    entry (start) -> idle
+    node idle ok (~run) => busy
+    node busy ok (run) => idle
+    edge idle -> busy
+`
+
+func TestGraphCovers(t *testing.T) {
+	seq := testSequence(t, coverProof, "l")
+	covers := []string{}
+	for _, step := range seq.props {
+		for _, prop := range step {
+			if prop.cover {
+				covers = append(covers, prop.name+": "+streamToString(prop.svaStream()))
+			}
+		}
+	}
+	// A transition which is both => and -> has a cover for each, the -> one suffixed with Now
+	want := []string{
+		"G_Initial_Idle_Cov: g_initial && g_idle",
+		"G_Busy_Cov: g_busy",
+		"G_Busy_Idle_Cov: g_busy ##1 g_idle",
+		"G_Idle_Cov: g_idle",
+		"G_Idle_Busy_Cov: g_idle ##1 g_busy",
+		"G_Idle_Busy_Now_Cov: g_idle && g_busy",
+	}
+	if !slices.Equal(covers, want) {
+		t.Errorf("covers:\n%s\nwant:\n%s", strings.Join(covers, "\n"), strings.Join(want, "\n"))
+	}
+	if sva := seq.toSva(-1, false, false, 100); !strings.Contains(sva, "G_Busy_Cov: cover property (g_busy);") {
+		t.Errorf("no cover property in:\n%s", sva)
+	}
+
+	// Covers are proved with their graph, but never assumed after it
+	for prop, assumed := range taskAssumptions(seq.toTasks()) {
+		if strings.HasSuffix(prop, "_Cov") {
+			continue
+		}
+		if slices.ContainsFunc(assumed, func(name string) bool { return strings.HasSuffix(name, "_Cov") }) {
+			t.Errorf("%s assumes covers %v", prop, assumed)
+		}
+	}
+}
+
+func TestReportCovers(t *testing.T) {
+	seq := testSequence(t, coverProof, "l")
+	results := []PropertyResult{}
+	for _, step := range seq.props {
+		for _, prop := range step {
+			status := StatusProven
+			if prop.name == "G_Idle_Busy_Now_Cov" {
+				status = normalizeStatus("unreachable")
+			} else if prop.cover {
+				status = normalizeStatus("covered")
+			}
+			results = append(results, PropertyResult{name: prop.name, status: status})
+		}
+	}
+	report := buildReport(&seq, "l", results, false)
+
+	// An unreachable cover leaves the proof sound, and is listed apart rather than counted as a failure
+	if !report.sound() || report.counts[StatusVacuous] != 0 || report.summary() != "10 proven" {
+		t.Errorf("report is %s, want every assertion proven", report.summary())
+	}
+	if report.coverSummary() != "5 covered, 1 uncovered" {
+		t.Errorf("covers are %s, want 5 covered and 1 uncovered", report.coverSummary())
+	}
+	if len(report.uncovered) != 1 || report.uncoveredText("") != "G_Idle_Busy_Now_Cov ("+seq.props[1][0].source.String()+")\n" {
+		t.Errorf("uncovered:\n%s", report.uncoveredText(""))
+	}
+	for _, step := range report.steps {
+		if len(step.unsound) != 0 {
+			t.Errorf("step assumes unproven %v", step.unsound)
+		}
+	}
+
+	// A cover without a result is missing, which does not make the proof unsound either
+	results = slices.DeleteFunc(results, func(result PropertyResult) bool { return result.name == "G_Busy_Cov" })
+	report = buildReport(&seq, "l", results, false)
+	if !report.sound() || report.coverCounts[StatusMissing] != 1 {
+		t.Errorf("report is %s with covers %s", report.summary(), report.coverSummary())
+	}
+}
@@ -0,0 +1,922 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+	"math/bits"
+	"os"
+	"slices"
+	"strconv"
+	"strings"
+)
+
+// A SystemVerilog value of up to 64 bits. A width of 0 is an unsized '0 or '1, which fills whatever it is used with.
+type svValue struct {
+	bits  uint64
+	width int
+}
+
+func widthMask(width int) uint64 {
+	if width <= 0 || width >= 64 {
+		return ^uint64(0)
+	}
+	return 1<<width - 1
+}
+
+func sized(value uint64, width int) svValue {
+	return svValue{bits: value & widthMask(width), width: width}
+}
+
+func boolValue(b bool) svValue {
+	if b {
+		return svValue{bits: 1, width: 1}
+	}
+	return svValue{bits: 0, width: 1}
+}
+
+func (v svValue) truthy() bool {
+	return v.bits != 0
+}
+
+// The value as used along with another, filling an unsized '1 to the width of the other
+func (v svValue) fit(other svValue) svValue {
+	if v.width == 0 {
+		return sized(v.bits, other.width)
+	}
+	return v
+}
+
+var evalOperators = []string{
+	"|->", "|=>", "===", "!==", "<<<", ">>>", "##", "==", "!=", "<=", ">=", "&&", "||", "<<", ">>", "->", "~&", "~|", "~^", "^~", "**",
+}
+
+// Splits SystemVerilog expressions and sequences into names, numbers, macro uses and operators
+func evalLex(text string) []string {
+	lexemes := []string{}
+	for i := 0; i < len(text); {
+		start := i
+		switch c := text[i]; {
+		case isWhitespace(c):
+			i += 1
+			continue
+		case isIdentStart(c) || c == '`':
+			i += 1
+			for i < len(text) && (isIdentStep(text[i]) || strings.HasPrefix(text[i:], "::")) {
+				if text[i] == ':' {
+					i += 1
+				}
+				i += 1
+			}
+		case isNum(c) || c == '\'':
+			for i < len(text) && isDecStep(text[i]) {
+				i += 1
+			}
+			if i < len(text) && text[i] == '\'' {
+				i += 1
+				for i < len(text) && (isHexStep(text[i]) || strings.IndexByte("sSdDbBoOhHxXzZ?", text[i]) != -1) {
+					i += 1
+				}
+			}
+		default:
+			i += 1
+			for _, op := range evalOperators {
+				if strings.HasPrefix(text[start:], op) {
+					i = start + len(op)
+					break
+				}
+			}
+		}
+		lexemes = append(lexemes, text[start:i])
+	}
+	return lexemes
+}
+
+func parseSvNumber(num string) svValue {
+	size, rest, based := strings.Cut(num, "'")
+	if !based {
+		n, err := strconv.ParseUint(strings.ReplaceAll(num, "_", ""), 10, 64)
+		if err != nil {
+			panic(fmt.Errorf("bad number %s", num))
+		}
+		return sized(n, 32)
+	}
+	if size == "" && (rest == "0" || rest == "1") {
+		value, _ := vcdValue(rest)
+		return svValue{bits: widthMask(64) * value, width: 0}
+	}
+	width := 32
+	if size != "" {
+		width, _ = strconv.Atoi(strings.ReplaceAll(size, "_", ""))
+	}
+	rest = strings.TrimLeft(rest, "sS")
+	if rest == "" {
+		panic(fmt.Errorf("bad number %s", num))
+	}
+	base := map[byte]int{'b': 2, 'B': 2, 'o': 8, 'O': 8, 'd': 10, 'D': 10, 'h': 16, 'H': 16}[rest[0]]
+	digits := strings.Map(func(r rune) rune {
+		switch r {
+		case '_':
+			return -1
+		case 'x', 'X', 'z', 'Z', '?':
+			return '0'
+		}
+		return r
+	}, rest[1:])
+	n, err := strconv.ParseUint(digits, base, 64)
+	if base == 0 || err != nil {
+		panic(fmt.Errorf("bad number %s", num))
+	}
+	return sized(n, width)
+}
+
+// An expression, which is an operator applied to its args, a name, a number, a call of a system function, an index,
+// a concatenation or a replication
+type evalExpr struct {
+	op    string
+	name  string
+	value svValue
+	args  []*evalExpr
+}
+
+// A sequence, which is a boolean condition, or the "or", "and" or "then" of its items. The items of "then" each follow
+// the one before by their delay, with the first delayed from the start of the sequence.
+type evalSeq struct {
+	op     string
+	cond   *evalExpr
+	delays []int
+	items  []*evalSeq
+}
+
+// A property, which is a sequence which must match, or with a step the property which must hold after each match of
+// the sequence
+type evalProperty struct {
+	seq        *evalSeq
+	step       string
+	consequent *evalProperty
+}
+
+type evalParser struct {
+	lexemes []string
+	pos     int
+}
+
+func (parser *evalParser) peek() string {
+	if parser.pos < len(parser.lexemes) {
+		return parser.lexemes[parser.pos]
+	}
+	return ""
+}
+
+func (parser *evalParser) next() string {
+	lexeme := parser.peek()
+	if lexeme == "" {
+		panic(fmt.Errorf("unexpected end of expression"))
+	}
+	parser.pos += 1
+	return lexeme
+}
+
+func (parser *evalParser) expect(lexeme string) {
+	if next := parser.next(); next != lexeme {
+		panic(fmt.Errorf("expected %s, found %s", lexeme, next))
+	}
+}
+
+var evalPrecedence = map[string]int{
+	"||": 1, "&&": 2, "|": 3, "^": 4, "~^": 4, "^~": 4, "&": 5,
+	"==": 6, "!=": 6, "===": 6, "!==": 6,
+	"<": 7, "<=": 7, ">": 7, ">=": 7,
+	"<<": 8, ">>": 8, "<<<": 8, ">>>": 8,
+	"+": 9, "-": 9, "*": 10, "/": 10, "%": 10, "**": 11,
+}
+
+var evalUnaryOperators = []string{"!", "~", "-", "+", "&", "|", "^", "~&", "~|", "~^"}
+
+func (parser *evalParser) parseExpr() *evalExpr {
+	cond := parser.parseBinary(1)
+	if parser.peek() == "?" {
+		parser.next()
+		then := parser.parseExpr()
+		parser.expect(":")
+		return &evalExpr{op: "?", args: []*evalExpr{cond, then, parser.parseExpr()}}
+	}
+	if parser.peek() == "->" {
+		parser.next()
+		return &evalExpr{op: "||", args: []*evalExpr{{op: "u!", args: []*evalExpr{cond}}, parser.parseExpr()}}
+	}
+	return cond
+}
+
+func (parser *evalParser) parseBinary(precedence int) *evalExpr {
+	left := parser.parseUnary()
+	for {
+		op := parser.peek()
+		opPrecedence, ok := evalPrecedence[op]
+		if !ok || opPrecedence < precedence {
+			return left
+		}
+		parser.next()
+		left = &evalExpr{op: op, args: []*evalExpr{left, parser.parseBinary(opPrecedence + 1)}}
+	}
+}
+
+func (parser *evalParser) parseUnary() *evalExpr {
+	if slices.Contains(evalUnaryOperators, parser.peek()) {
+		op := parser.next()
+		return &evalExpr{op: "u" + op, args: []*evalExpr{parser.parseUnary()}}
+	}
+	return parser.parsePostfix(parser.parsePrimary())
+}
+
+func (parser *evalParser) parseList(end string) []*evalExpr {
+	args := []*evalExpr{parser.parseExpr()}
+	for parser.peek() == "," {
+		parser.next()
+		args = append(args, parser.parseExpr())
+	}
+	parser.expect(end)
+	return args
+}
+
+func (parser *evalParser) parsePrimary() *evalExpr {
+	lexeme := parser.next()
+	switch {
+	case lexeme == "(":
+		expr := parser.parseExpr()
+		parser.expect(")")
+		return expr
+	case lexeme == "{":
+		first := parser.parseExpr()
+		if parser.peek() == "{" {
+			parser.next()
+			inner := &evalExpr{op: "concat", args: parser.parseList("}")}
+			parser.expect("}")
+			return &evalExpr{op: "repeat", args: []*evalExpr{first, inner}}
+		}
+		args := []*evalExpr{first}
+		if parser.peek() == "," {
+			parser.next()
+			args = append(args, parser.parseList("}")...)
+		} else {
+			parser.expect("}")
+		}
+		return &evalExpr{op: "concat", args: args}
+	case lexeme[0] == '`':
+		panic(fmt.Errorf("macro %s is not expanded, give its header with -sv-include", lexeme))
+	case isNum(lexeme[0]) || lexeme[0] == '\'':
+		return &evalExpr{op: "num", value: parseSvNumber(lexeme)}
+	case isIdentStart(lexeme[0]):
+		if parser.peek() == "(" {
+			parser.next()
+			return &evalExpr{op: "call", name: lexeme, args: parser.parseList(")")}
+		}
+		return &evalExpr{op: "name", name: lexeme}
+	}
+	panic(fmt.Errorf("unexpected %s", lexeme))
+}
+
+func (parser *evalParser) parsePostfix(expr *evalExpr) *evalExpr {
+	for parser.peek() == "[" {
+		parser.next()
+		args := []*evalExpr{expr, parser.parseExpr()}
+		if parser.peek() == ":" {
+			parser.next()
+			args = append(args, parser.parseExpr())
+		}
+		parser.expect("]")
+		expr = &evalExpr{op: "index", args: args}
+	}
+	return expr
+}
+
+// Whether the brackets starting at the current lexeme hold a sequence rather than an expression
+func (parser *evalParser) bracketsHoldSequence() bool {
+	depth := 0
+	for _, lexeme := range parser.lexemes[parser.pos:] {
+		switch lexeme {
+		case "(", "[", "{":
+			depth += 1
+		case ")", "]", "}":
+			depth -= 1
+			if depth == 0 {
+				return false
+			}
+		case "##", "or", "and", "|->", "|=>":
+			if depth == 1 {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+func (parser *evalParser) parseSeq() *evalSeq {
+	return parser.parseSeqJunction("or", func() *evalSeq {
+		return parser.parseSeqJunction("and", parser.parseSeqThen)
+	})
+}
+
+func (parser *evalParser) parseSeqJunction(op string, parseItem func() *evalSeq) *evalSeq {
+	items := []*evalSeq{parseItem()}
+	for parser.peek() == op {
+		parser.next()
+		items = append(items, parseItem())
+	}
+	if len(items) == 1 {
+		return items[0]
+	}
+	return &evalSeq{op: op, items: items}
+}
+
+func (parser *evalParser) parseSeqThen() *evalSeq {
+	seq := &evalSeq{op: "then", delays: []int{}, items: []*evalSeq{}}
+	for {
+		delay := 0
+		if parser.peek() == "##" {
+			parser.next()
+			lexeme := parser.next()
+			n, err := strconv.Atoi(lexeme)
+			if err != nil {
+				panic(fmt.Errorf("unsupported delay ##%s", lexeme))
+			}
+			delay = n
+		} else if len(seq.items) > 0 {
+			break
+		}
+		seq.delays = append(seq.delays, delay)
+		if parser.peek() == "(" && parser.bracketsHoldSequence() {
+			parser.next()
+			seq.items = append(seq.items, parser.parseSeq())
+			parser.expect(")")
+		} else {
+			seq.items = append(seq.items, &evalSeq{op: "bool", cond: parser.parseExpr()})
+		}
+	}
+	if len(seq.items) == 1 && seq.delays[0] == 0 {
+		return seq.items[0]
+	}
+	return seq
+}
+
+func (parser *evalParser) parseProperty() *evalProperty {
+	prop := &evalProperty{seq: parser.parseSeq()}
+	if step := parser.peek(); step == "|->" || step == "|=>" {
+		parser.next()
+		prop.step = step
+		prop.consequent = parser.parseProperty()
+	}
+	return prop
+}
+
+func parseEvalProperty(text string) *evalProperty {
+	parser := evalParser{lexemes: evalLex(text)}
+	prop := parser.parseProperty()
+	if parser.peek() != "" {
+		panic(fmt.Errorf("unexpected %s", parser.peek()))
+	}
+	return prop
+}
+
+func parseEvalExpr(text string) *evalExpr {
+	parser := evalParser{lexemes: evalLex(text)}
+	expr := parser.parseExpr()
+	if parser.peek() != "" {
+		panic(fmt.Errorf("unexpected %s", parser.peek()))
+	}
+	return expr
+}
+
+// Evaluates properties over a trace, along with the wires they use
+type Evaluator struct {
+	trace      *Trace
+	wires      map[string]TokenStream
+	wireValues map[string][]svValue // Each wire's value in each cycle, once evaluated
+	wireXZ     map[string][]bool    // Whether each wire's value in each cycle read any x or z bits, once evaluated
+	readXZ     bool                 // Set when a value with x or z bits is read
+	evaluating map[string]bool
+	disable    *evalExpr // Disables properties in the cycles it holds, or nil
+	nodes      []Wiring  // The graph_induction nodes
+}
+
+func NewEvaluator(trace *Trace, wires []Wiring) *Evaluator {
+	ev := &Evaluator{
+		trace:      trace,
+		wires:      map[string]TokenStream{},
+		wireValues: map[string][]svValue{},
+		wireXZ:     map[string][]bool{},
+		evaluating: map[string]bool{},
+	}
+	for _, wire := range wires {
+		if _, ok := ev.wires[wire.name]; !ok {
+			ev.wires[wire.name] = wire.value
+			if wire.node != nil {
+				ev.nodes = append(ev.nodes, wire)
+			}
+		}
+	}
+	return ev
+}
+
+// Disables properties wherever a condition holds, unless it is empty. If it cannot be evaluated, such as when its
+// signals are not in the trace, properties are never disabled.
+func (ev *Evaluator) setDisable(disable string) {
+	if disable == "" {
+		return
+	}
+	defer func() {
+		if r := recover(); r != nil {
+			fmt.Fprintln(os.Stderr, fmt.Errorf("warning: properties are never disabled, %s cannot be evaluated: %v", disable, r))
+			ev.disable = nil
+		}
+	}()
+	ev.disable = parseEvalExpr(disable)
+	ev.disabled(0, 0)
+}
+
+func (ev *Evaluator) cycleName(t int) string {
+	return fmt.Sprintf("cycle %d (time %d)", t, ev.trace.times[t])
+}
+
+// The names of the graph_induction nodes whose conditions hold in a cycle, marking those which cannot be evaluated
+// with ?, or none
+func (ev *Evaluator) activeNodes(t int) []string {
+	active := []string{}
+	for _, node := range ev.nodes {
+		func() {
+			defer func() {
+				if r := recover(); r != nil {
+					active = append(active, node.name+"?")
+				}
+			}()
+			if ev.lookup(node.name, t).truthy() {
+				active = append(active, node.name)
+			}
+		}()
+	}
+	if len(active) == 0 {
+		active = append(active, "none")
+	}
+	return active
+}
+
+func (ev *Evaluator) cycles() int {
+	return len(ev.trace.cycles)
+}
+
+func (ev *Evaluator) lookup(name string, t int) svValue {
+	if wire, ok := ev.wires[name]; ok {
+		values, ok := ev.wireValues[name]
+		if !ok {
+			if ev.evaluating[name] {
+				panic(fmt.Errorf("wire %s depends on itself", name))
+			}
+			ev.evaluating[name] = true
+			defer delete(ev.evaluating, name)
+			expr := parseEvalExpr(streamToString(wire))
+			values = make([]svValue, ev.cycles())
+			xz := make([]bool, ev.cycles())
+			readXZ := ev.readXZ
+			for c := range values {
+				ev.readXZ = false
+				values[c] = ev.eval(expr, c)
+				xz[c] = ev.readXZ
+			}
+			ev.readXZ = readXZ
+			ev.wireValues[name] = values
+			ev.wireXZ[name] = xz
+		}
+		ev.readXZ = ev.readXZ || ev.wireXZ[name][t]
+		return values[t]
+	}
+	slot, ok := ev.trace.slot(name)
+	if !ok {
+		panic(fmt.Errorf("no signal %s in the trace", name))
+	}
+	ev.readXZ = ev.readXZ || ev.trace.isUnknown(t, slot)
+	return sized(ev.trace.cycles[t][slot], ev.trace.widths[slot])
+}
+
+func (ev *Evaluator) call(expr *evalExpr, t int) svValue {
+	arg := func(i int, t int) svValue {
+		if i >= len(expr.args) {
+			panic(fmt.Errorf("%s expects %d arguments", expr.name, i+1))
+		}
+		return ev.eval(expr.args[i], max(t, 0))
+	}
+	switch expr.name {
+	case "$past":
+		n := 1
+		if len(expr.args) > 1 {
+			if expr.args[1].op != "num" {
+				panic(fmt.Errorf("$past expects a constant number of cycles"))
+			}
+			n = int(expr.args[1].value.bits)
+		}
+		// Before the start of the trace, the first cycle is taken to have lasted
+		return arg(0, t-n)
+	case "$stable":
+		return boolValue(arg(0, t) == arg(0, t-1))
+	case "$changed":
+		return boolValue(arg(0, t) != arg(0, t-1))
+	case "$rose":
+		return boolValue(arg(0, t).bits&1 == 1 && arg(0, t-1).bits&1 == 0)
+	case "$fell":
+		return boolValue(arg(0, t).bits&1 == 0 && arg(0, t-1).bits&1 == 1)
+	case "$onehot":
+		return boolValue(bits.OnesCount64(arg(0, t).bits) == 1)
+	case "$onehot0":
+		return boolValue(bits.OnesCount64(arg(0, t).bits) <= 1)
+	case "$countones":
+		return sized(uint64(bits.OnesCount64(arg(0, t).bits)), 32)
+	case "$isunknown":
+		// Unknown bits are read from the trace as 0
+		return boolValue(false)
+	}
+	panic(fmt.Errorf("unsupported function %s", expr.name))
+}
+
+func (ev *Evaluator) eval(expr *evalExpr, t int) svValue {
+	switch expr.op {
+	case "num":
+		return expr.value
+	case "name":
+		return ev.lookup(expr.name, t)
+	case "call":
+		return ev.call(expr, t)
+	case "?":
+		if ev.eval(expr.args[0], t).truthy() {
+			return ev.eval(expr.args[1], t)
+		}
+		return ev.eval(expr.args[2], t)
+	case "index":
+		value := ev.eval(expr.args[0], t)
+		lo := ev.eval(expr.args[len(expr.args)-1], t).bits
+		width := 1
+		if len(expr.args) == 3 {
+			width = int(ev.eval(expr.args[1], t).bits-lo) + 1
+		}
+		if lo >= 64 {
+			return sized(0, width)
+		}
+		return sized(value.bits>>lo, width)
+	case "concat":
+		result := svValue{}
+		for _, arg := range expr.args {
+			value := ev.eval(arg, t)
+			result = sized(result.bits<<value.width|value.bits, result.width+value.width)
+		}
+		return result
+	case "repeat":
+		n := ev.eval(expr.args[0], t).bits
+		value := ev.eval(expr.args[1], t)
+		result := svValue{}
+		for range n {
+			result = sized(result.bits<<value.width|value.bits, result.width+value.width)
+		}
+		return result
+	}
+
+	if len(expr.args) == 1 {
+		return evalUnary(expr.op[1:], ev.eval(expr.args[0], t))
+	}
+	a := ev.eval(expr.args[0], t)
+	// && and || only evaluate their right hand side when they need to, as in SystemVerilog
+	if expr.op == "&&" && !a.truthy() {
+		return boolValue(false)
+	}
+	if expr.op == "||" && a.truthy() {
+		return boolValue(true)
+	}
+	b := ev.eval(expr.args[1], t)
+	return evalBinary(expr.op, a.fit(b), b.fit(a))
+}
+
+func evalUnary(op string, a svValue) svValue {
+	switch op {
+	case "!":
+		return boolValue(!a.truthy())
+	case "~":
+		return sized(^a.bits, a.width)
+	case "-":
+		return sized(-a.bits, a.width)
+	case "+":
+		return a
+	case "&":
+		return boolValue(a.bits == widthMask(a.width))
+	case "|":
+		return boolValue(a.bits != 0)
+	case "^":
+		return boolValue(bits.OnesCount64(a.bits)%2 == 1)
+	case "~&":
+		return boolValue(a.bits != widthMask(a.width))
+	case "~|":
+		return boolValue(a.bits == 0)
+	case "~^":
+		return boolValue(bits.OnesCount64(a.bits)%2 == 0)
+	}
+	panic(fmt.Errorf("unsupported operator %s", op))
+}
+
+func evalBinary(op string, a svValue, b svValue) svValue {
+	width := max(a.width, b.width)
+	switch op {
+	case "&&":
+		return boolValue(b.truthy())
+	case "||":
+		return boolValue(b.truthy())
+	case "|":
+		return sized(a.bits|b.bits, width)
+	case "&":
+		return sized(a.bits&b.bits, width)
+	case "^":
+		return sized(a.bits^b.bits, width)
+	case "~^", "^~":
+		return sized(^(a.bits ^ b.bits), width)
+	case "==", "===":
+		return boolValue(a.bits == b.bits)
+	case "!=", "!==":
+		return boolValue(a.bits != b.bits)
+	case "<":
+		return boolValue(a.bits < b.bits)
+	case "<=":
+		return boolValue(a.bits <= b.bits)
+	case ">":
+		return boolValue(a.bits > b.bits)
+	case ">=":
+		return boolValue(a.bits >= b.bits)
+	case "<<", "<<<":
+		return sized(a.bits<<b.bits, a.width)
+	case ">>", ">>>":
+		return sized(a.bits>>b.bits, a.width)
+	case "+":
+		return sized(a.bits+b.bits, width)
+	case "-":
+		return sized(a.bits-b.bits, width)
+	case "*":
+		return sized(a.bits*b.bits, width)
+	case "/", "%":
+		if b.bits == 0 {
+			return sized(0, width)
+		}
+		if op == "/" {
+			return sized(a.bits/b.bits, width)
+		}
+		return sized(a.bits%b.bits, width)
+	case "**":
+		result := uint64(1)
+		for range b.bits {
+			result *= a.bits
+		}
+		return sized(result, width)
+	}
+	panic(fmt.Errorf("unsupported operator %s", op))
+}
+
+// The cycles a sequence started at cycle t can end at, and whether the trace ends before it can tell whether there
+// are any more
+func (ev *Evaluator) match(seq *evalSeq, t int) ([]int, bool) {
+	switch seq.op {
+	case "bool":
+		if t >= ev.cycles() {
+			return nil, true
+		}
+		if ev.eval(seq.cond, t).truthy() {
+			return []int{t}, false
+		}
+		return nil, false
+	case "or":
+		ends := []int{}
+		pending := false
+		for _, item := range seq.items {
+			itemEnds, itemPending := ev.match(item, t)
+			ends = append(ends, itemEnds...)
+			pending = pending || itemPending
+		}
+		slices.Sort(ends)
+		return slices.Compact(ends), pending
+	case "and":
+		ends := []int{t}
+		pending := false
+		for _, item := range seq.items {
+			itemEnds, itemPending := ev.match(item, t)
+			pending = pending || itemPending
+			both := []int{}
+			for _, end := range ends {
+				for _, itemEnd := range itemEnds {
+					both = append(both, max(end, itemEnd))
+				}
+			}
+			ends = both
+		}
+		slices.Sort(ends)
+		return slices.Compact(ends), pending
+	}
+
+	starts := []int{t + seq.delays[0]}
+	ends := []int{}
+	pending := false
+	for i, item := range seq.items {
+		ends = []int{}
+		for _, start := range starts {
+			itemEnds, itemPending := ev.match(item, start)
+			ends = append(ends, itemEnds...)
+			pending = pending || itemPending
+		}
+		slices.Sort(ends)
+		ends = slices.Compact(ends)
+		if i+1 < len(seq.items) {
+			starts = []int{}
+			for _, end := range ends {
+				starts = append(starts, end+seq.delays[i+1])
+			}
+		}
+	}
+	return ends, pending
+}
+
+// Whether the disable condition holds in any cycle from start to end
+func (ev *Evaluator) disabled(start int, end int) bool {
+	if ev.disable == nil {
+		return false
+	}
+	for t := start; t <= end && t < ev.cycles(); t++ {
+		if ev.eval(ev.disable, t).truthy() {
+			return true
+		}
+	}
+	return false
+}
+
+// The cycles a property started at cycle t fails in, by the cycle its last sequence fails to match from
+func (ev *Evaluator) failsFrom(prop *evalProperty, t int) []int {
+	ends, pending := ev.match(prop.seq, t)
+	if prop.step == "" {
+		if len(ends) == 0 && !pending {
+			return []int{t}
+		}
+		return nil
+	}
+	failures := []int{}
+	for _, end := range ends {
+		if prop.step == "|=>" {
+			end += 1
+		}
+		failures = append(failures, ev.failsFrom(prop.consequent, end)...)
+	}
+	return failures
+}
+
+// The cycles in which an attempt of a property fails, unless it is disabled
+func (ev *Evaluator) failures(prop *evalProperty) []int {
+	failures := []int{}
+	for t := range ev.cycles() {
+		for _, failure := range ev.failsFrom(prop, t) {
+			if !ev.disabled(t, failure) {
+				failures = append(failures, failure)
+			}
+		}
+	}
+	slices.Sort(failures)
+	return slices.Compact(failures)
+}
+
+// The first cycle in which the sequence of a cover property ends, or -1 if it is never covered
+func (ev *Evaluator) covered(prop *evalProperty) int {
+	for t := range ev.cycles() {
+		ends, _ := ev.match(prop.seq, t)
+		for _, end := range ends {
+			if !ev.disabled(t, end) {
+				return end
+			}
+		}
+	}
+	return -1
+}
+
+// Reports, for every property, the cycles of the trace in which it fails, and the cover properties it never covers
+func (seq *FlatProofSequence) evalTrace(ev *Evaluator, stepPrefix bool) string {
+	out := ""
+	total, failing, unknown := 0, 0, 0
+	covers, uncovered := 0, 0
+	for i, step := range seq.props {
+		for _, prop := range step {
+			name := prop.svaName(stepPrefix, i)
+			if prop.cover {
+				covers += 1
+				err := func() (err error) {
+					defer func() {
+						if r := recover(); r != nil {
+							err = fmt.Errorf("%v", r)
+						}
+					}()
+					if ev.covered(parseEvalProperty(streamToString(prop.svaStream()))) == -1 {
+						uncovered += 1
+						out += fmt.Sprintf("%s is not covered (%s)\n", name, prop.source)
+					}
+					return nil
+				}()
+				if err != nil {
+					unknown += 1
+					out += fmt.Sprintf("%s cannot be evaluated: %v\n", name, err)
+				}
+				continue
+			}
+			total += 1
+			var failures []int
+			err := func() (err error) {
+				defer func() {
+					if r := recover(); r != nil {
+						err = fmt.Errorf("%v", r)
+					}
+				}()
+				failures = ev.failures(parseEvalProperty(streamToString(prop.svaStream())))
+				return nil
+			}()
+			switch {
+			case err != nil:
+				unknown += 1
+				out += fmt.Sprintf("%s cannot be evaluated: %v\n", name, err)
+			case len(failures) > 0:
+				failing += 1
+				out += fmt.Sprintf("%s fails at %s", name, ev.cycleName(failures[0]))
+				if len(failures) == 2 {
+					out += " and 1 other cycle"
+				} else if len(failures) > 2 {
+					out += fmt.Sprintf(" and %d other cycles", len(failures)-1)
+				}
+				out += fmt.Sprintf(" (%s)\n", prop.source)
+			}
+		}
+	}
+	out += fmt.Sprintf("%d of %d properties fail over %d cycles", failing, total, ev.cycles())
+	if unknown > 0 {
+		out += fmt.Sprintf(", %d cannot be evaluated", unknown)
+	}
+	out += "\n"
+	if covers > 0 {
+		out += fmt.Sprintf("%d of %d covers are not covered\n", uncovered, covers)
+	}
+
+	if len(ev.nodes) > 0 {
+		out += "\ngraph nodes active in each cycle:\n"
+		for t := range ev.cycles() {
+			out += ev.cycleName(t) + ": " + strings.Join(ev.activeNodes(t), " ") + "\n"
+		}
+	}
+	return out
+}
+
+func evalMain(args []string) {
+	flags := flag.NewFlagSet("eval", flag.ExitOnError)
+	paths := []string{}
+	flags.Func("path", "paths to source files", func(s string) error {
+		paths = append(paths, s)
+		return nil
+	})
+	includes := []string{}
+	includeFlag(flags, &includes)
+	defines := map[string]string{}
+	definesFlag(flags, defines)
+	svIncludes := []string{}
+	svIncludeFlag(flags, &svIncludes)
+	rootLemma := flags.String("root", "", "name of root lemma")
+	clock := flags.String("clock", "clk_i", "signal of the trace whose rising edges are the cycles properties are sampled in")
+	disable := flags.String("disable", "~rst_ni", "condition disabling properties, as with disable iff, or empty for none")
+	stepPrefix := flags.Bool("step-prefix", false, "Prefix all properties with Step[step number]_")
+	out := flags.String("o", "", "path to write the results to, or empty to print them")
+	flags.Parse(args)
+
+	if len(paths) == 0 {
+		fmt.Println(fmt.Errorf("error: must specify at least one path"))
+		return
+	}
+	if *rootLemma == "" {
+		fmt.Println(fmt.Errorf("error: must specify a root lemma"))
+		return
+	}
+	if flags.NArg() != 1 {
+		fmt.Println(fmt.Errorf("error: must specify one VCD trace"))
+		return
+	}
+
+	trace, err := loadTrace(flags.Arg(0), *clock)
+	if err != nil {
+		fmt.Println(fmt.Errorf("error: %v", err))
+		return
+	}
+	scope, err := loadScope(paths, includes, defines, svIncludes)
+	if err != nil {
+		fmt.Println(err)
+		return
+	}
+	seq := genSequence(&scope, *rootLemma)
+	ev := NewEvaluator(trace, seq.wires)
+	ev.setDisable(*disable)
+
+	results := seq.evalTrace(ev, *stepPrefix)
+	if *out != "" {
+		os.WriteFile(*out, []byte(results), 0664)
+	} else {
+		fmt.Print(results)
+	}
+}
@@ -0,0 +1,161 @@
+package main
+
+import (
+	"fmt"
+	"slices"
+	"strings"
+	"testing"
+)
+
+func TestEvalLex(t *testing.T) {
+	for _, test := range []struct {
+		text    string
+		lexemes []string
+	}{
+		{"a && b", []string{"a", "&&", "b"}},
+		{"a|=>b", []string{"a", "|=>", "b"}},
+		{"a ##2 b |-> c", []string{"a", "##", "2", "b", "|->", "c"}},
+		{"x == 4'b10_10", []string{"x", "==", "4'b10_10"}},
+		{"x != '1", []string{"x", "!=", "'1"}},
+		{"$past(a, 2)", []string{"$past", "(", "a", ",", "2", ")"}},
+		{"pkg::VALUE <<< 1", []string{"pkg::VALUE", "<<<", "1"}},
+		{"~&v[3:0]", []string{"~&", "v", "[", "3", ":", "0", "]"}},
+		{"`CR.valid", []string{"`CR.valid"}},
+	} {
+		if lexemes := evalLex(test.text); !slices.Equal(lexemes, test.lexemes) {
+			t.Errorf("evalLex(%q) = %q, want %q", test.text, lexemes, test.lexemes)
+		}
+	}
+}
+
+func TestParseEvalProperty(t *testing.T) {
+	prop := parseEvalProperty("a ##1 (b || c) |=> d")
+	if prop.step != "|=>" || prop.seq.op != "then" || !slices.Equal(prop.seq.delays, []int{0, 1}) {
+		t.Fatalf("parsed %+v, want a |=> after a two item sequence", prop)
+	}
+	if item := prop.seq.items[1]; item.op != "bool" || item.cond.op != "||" {
+		t.Errorf("second item %+v, want the boolean b || c", item)
+	}
+	if prop.consequent.step != "" || prop.consequent.seq.op != "bool" || prop.consequent.seq.cond.name != "d" {
+		t.Errorf("consequent %+v, want d", prop.consequent)
+	}
+
+	prop = parseEvalProperty("(a ##1 b) or c |-> d")
+	if prop.step != "|->" || prop.seq.op != "or" || len(prop.seq.items) != 2 || prop.seq.items[0].op != "then" {
+		t.Errorf("parsed %+v, want |-> after an or of a sequence and c", prop)
+	}
+
+	for _, text := range []string{"a |->", "a b", "(a", "a ##b c", "`M"} {
+		func() {
+			defer func() {
+				if recover() == nil {
+					t.Errorf("parseEvalProperty(%q) did not panic", text)
+				}
+			}()
+			parseEvalProperty(text)
+		}()
+	}
+}
+
+func TestParseSvNumber(t *testing.T) {
+	for _, test := range []struct {
+		num   string
+		value svValue
+	}{
+		{"12", svValue{12, 32}},
+		{"4'b10_10", svValue{10, 4}},
+		{"8'hff", svValue{255, 8}},
+		{"'d7", svValue{7, 32}},
+		{"3'bx1z", svValue{2, 3}},
+		{"'1", svValue{widthMask(64), 0}},
+	} {
+		if value := parseSvNumber(test.num); value != test.value {
+			t.Errorf("parseSvNumber(%q) = %v, want %v", test.num, value, test.value)
+		}
+	}
+}
+
+// An evaluator over a trace of the 1 bit signals a, b and c, given as a string of their values in each cycle
+func testEvaluator(cycles ...string) *Evaluator {
+	trace := &Trace{
+		slots:  map[string]int{"a": 0, "b": 1, "c": 2},
+		widths: []int{1, 1, 1},
+		cycles: [][]uint64{},
+		times:  []int64{},
+	}
+	for t, cycle := range cycles {
+		values := []uint64{}
+		for _, c := range cycle {
+			values = append(values, uint64(c-'0'))
+		}
+		trace.cycles = append(trace.cycles, values)
+		trace.times = append(trace.times, int64(t*10))
+	}
+	return NewEvaluator(trace, []Wiring{})
+}
+
+func TestEvalFailures(t *testing.T) {
+	// a, b and c in each cycle
+	ev := testEvaluator("100", "011", "110", "001", "000")
+	for _, test := range []struct {
+		prop     string
+		failures []int
+	}{
+		{"a |-> b", []int{0}},
+		{"a |=> b", []int{3}},
+		{"a |=> c", []int{}},
+		{"b |=> a", []int{3}},
+		{"a || b", []int{3, 4}},
+		{"$past(a) |-> c", []int{0}},
+		{"$past(a, 2) |-> b", []int{0, 4}},
+		{"$rose(b) |-> c", []int{}},
+		{"$fell(a) |-> b", []int{3}},
+		{"a ##1 b |-> c", []int{}},
+		{"a ##1 b |=> c", []int{2}},
+		{"a ##1 b |=> ##1 c", []int{}},
+		{"(a or c) |-> b", []int{0, 3}},
+		// The attempt from cycle 3 runs past the end of the trace, so is not a failure
+		{"c |=> ##1 a", []int{2}},
+	} {
+		if failures := ev.failures(parseEvalProperty(test.prop)); !slices.Equal(failures, test.failures) {
+			t.Errorf("%s fails in %v, want %v", test.prop, failures, test.failures)
+		}
+	}
+
+	ev.setDisable("c")
+	if failures := ev.failures(parseEvalProperty("a |=> b")); len(failures) != 0 {
+		t.Errorf("a |=> b fails in %v while disabled by c, want none", failures)
+	}
+}
+
+func TestEvalCovered(t *testing.T) {
+	ev := testEvaluator("100", "011", "110", "001", "000")
+	for _, test := range []struct {
+		prop  string
+		cycle int
+	}{
+		{"a ##1 b", 1},
+		{"b && c", 1},
+		{"a ##2 a", 2},
+		{"c ##1 c", -1},
+	} {
+		if cycle := ev.covered(parseEvalProperty(test.prop)); cycle != test.cycle {
+			t.Errorf("%s covered in %d, want %d", test.prop, cycle, test.cycle)
+		}
+	}
+}
+
+func TestEvalWires(t *testing.T) {
+	_, ab := tokenize("a && b")
+	_, loop := tokenize("loop")
+	ev := NewEvaluator(testEvaluator("100", "110").trace, []Wiring{{name: "ab", value: ab}, {name: "loop", value: loop}})
+	if failures := ev.failures(parseEvalProperty("ab |-> c")); !slices.Equal(failures, []int{1}) {
+		t.Errorf("ab |-> c fails in %v, want [1]", failures)
+	}
+	defer func() {
+		if r := recover(); r == nil || !strings.Contains(fmt.Sprint(r), "depends on itself") {
+			t.Errorf("expected a wire depending on itself to panic, got %v", r)
+		}
+	}()
+	ev.failures(parseEvalProperty("loop"))
+}
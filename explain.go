@@ -0,0 +1,241 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+	"slices"
+	"strings"
+)
+
+func (value svValue) String() string {
+	if value.width == 1 {
+		return fmt.Sprint(value.bits)
+	}
+	return fmt.Sprintf("%d'h%x", value.width, value.bits)
+}
+
+// The value of an expression in a cycle, or ? if it cannot be evaluated
+func (ev *Evaluator) show(stream TokenStream, t int) (str string) {
+	defer func() {
+		if r := recover(); r != nil {
+			str = "?"
+		}
+	}()
+	if t < 0 || t >= ev.cycles() {
+		return "?"
+	}
+	return ev.eval(parseEvalExpr(streamToString(stream)), t).String()
+}
+
+// The latest attempt of a property which fails in a cycle
+func (ev *Evaluator) attemptOf(prop *evalProperty, failure int) int {
+	for t := failure; t >= 0; t-- {
+		if slices.Contains(ev.failsFrom(prop, t), failure) && !ev.disabled(t, failure) {
+			return t
+		}
+	}
+	return failure
+}
+
+// The names of the signals and wires of an expression, in the order they first appear
+func evalNames(stream TokenStream) []string {
+	lexemes := evalLex(streamToString(stream))
+	names := []string{}
+	for i, lexeme := range lexemes {
+		if !isIdentStart(lexeme[0]) || lexeme == "or" || lexeme == "and" || slices.Contains(names, lexeme) {
+			continue
+		}
+		if i+1 < len(lexemes) && lexemes[i+1] == "(" {
+			continue
+		}
+		names = append(names, lexeme)
+	}
+	return names
+}
+
+// How the nodes of a graph were active in a cycle, and how they were reached from the cycle before
+func (ev *Evaluator) explainNodes(t int) string {
+	active := func(t int) map[string]bool {
+		nodes := map[string]bool{}
+		for _, name := range ev.activeNodes(t) {
+			nodes[name] = true
+		}
+		return nodes
+	}
+	now := active(t)
+	str := ev.cycleName(t) + ": " + strings.Join(ev.activeNodes(t), " ")
+	if t == 0 {
+		return str + "\n"
+	}
+
+	before := active(t - 1)
+	for _, src := range ev.nodes {
+		if !before[src.name] {
+			continue
+		}
+		taken := []string{}
+		for _, dst := range ev.nodes {
+			if dst.node.graph != src.node.graph || !now[dst.name] {
+				continue
+			}
+			if slices.Contains(src.node.steps, dst.name) {
+				taken = append(taken, dst.name)
+			} else {
+				str += fmt.Sprintf("\n    %s => %s is not a transition of %s", src.name, dst.name, src.name)
+			}
+		}
+		if len(taken) > 0 {
+			str += fmt.Sprintf("\n    %s => %s", src.name, strings.Join(taken, " "))
+		} else if len(src.node.steps) > 0 {
+			str += fmt.Sprintf("\n    %s => none of %s", src.name, strings.Join(src.node.steps, " "))
+		}
+	}
+	return str + "\n"
+}
+
+// Explains why a property fails in a trace, by the cycle it fails in (or its first failure if at is -1), the
+// commands and helpers it came from, the values of its conditions and signals, and the graph nodes active around it
+func (seq *FlatProofSequence) explain(ev *Evaluator, name string, stepPrefix bool, at int, history int) (string, error) {
+	var prop *Property
+	for i, step := range seq.props {
+		for _, other := range step {
+			if other.svaName(stepPrefix, i) == name {
+				prop = other
+			}
+		}
+	}
+	if prop == nil {
+		return "", fmt.Errorf("no property named %s", name)
+	}
+	if prop.cover {
+		return "", fmt.Errorf("%s is a cover property, which cannot fail", name)
+	}
+
+	var parsed *evalProperty
+	var failures []int
+	err := func() (err error) {
+		defer func() {
+			if r := recover(); r != nil {
+				err = fmt.Errorf("%s cannot be evaluated: %v", name, r)
+			}
+		}()
+		parsed = parseEvalProperty(streamToString(prop.svaStream()))
+		failures = ev.failures(parsed)
+		return nil
+	}()
+	if err != nil {
+		return "", err
+	}
+	if len(failures) == 0 {
+		return name + " holds in every cycle of the trace\n", nil
+	}
+	failure := failures[0]
+	if at != -1 {
+		if !slices.Contains(failures, at) {
+			return "", fmt.Errorf("%s does not fail in cycle %d, it fails in %s", name, at, ev.cycleName(failures[0]))
+		}
+		failure = at
+	}
+	start := ev.attemptOf(parsed, failure)
+	// The cycle the preconditions held in, when the postcondition was checked from the failure
+	matched := failure
+	if prop.step == "|=>" {
+		matched -= 1
+	}
+
+	out := fmt.Sprintf("%s fails in %s, in the attempt from %s\n", name, ev.cycleName(failure), ev.cycleName(start))
+	out += "from " + prop.source.String()
+	if prop.lemma != "" {
+		out += " in lemma " + prop.lemma
+	}
+	out += "\n"
+	for _, origin := range prop.origins {
+		out += fmt.Sprintf("  %s  %s\n", ev.show(origin.condition, matched), origin.command.String())
+	}
+	for _, helper := range prop.helpers {
+		out += "  via " + helper.String() + "\n"
+	}
+
+	if len(prop.preConditions) > 0 {
+		out += fmt.Sprintf("\npreconditions in %s:\n", ev.cycleName(max(matched, 0)))
+		for _, pre := range prop.preConditions {
+			out += fmt.Sprintf("  %s  %s\n", ev.show(pre, matched), streamToString(trimWhitespace(pre)))
+		}
+	}
+	out += fmt.Sprintf("\npostcondition from %s:\n  %s  %s\n", ev.cycleName(failure), ev.show(prop.postCondition, failure), streamToString(trimWhitespace(prop.postCondition)))
+	for _, name := range evalNames(prop.postCondition) {
+		out += fmt.Sprintf("  %s = %s\n", name, ev.show(TokenStream{&NameToken{content: name}}, failure))
+	}
+
+	names := evalNames(prop.svaStream())
+	graph := slices.ContainsFunc(prop.helpers, func(helper ProvenanceStep) bool { return strings.HasPrefix(helper.kind, "graph") })
+	if len(ev.nodes) > 0 && (graph || slices.ContainsFunc(ev.nodes, func(node Wiring) bool { return slices.Contains(names, node.name) })) {
+		out += "\ngraph nodes active in each cycle:\n"
+		// Up to the cycle after the failure, which the postcondition of a step may look at
+		for t := max(start-history, 0); t <= failure+1 && t < ev.cycles(); t++ {
+			out += ev.explainNodes(t)
+		}
+	}
+	return out, nil
+}
+
+func explainMain(args []string) {
+	flags := flag.NewFlagSet("explain", flag.ExitOnError)
+	paths := []string{}
+	flags.Func("path", "paths to source files", func(s string) error {
+		paths = append(paths, s)
+		return nil
+	})
+	includes := []string{}
+	includeFlag(flags, &includes)
+	defines := map[string]string{}
+	definesFlag(flags, defines)
+	svIncludes := []string{}
+	svIncludeFlag(flags, &svIncludes)
+	rootLemma := flags.String("root", "", "name of root lemma")
+	name := flags.String("property", "", "name of the failing property to explain")
+	at := flags.Int("cycle", -1, "cycle of the failure to explain, or -1 for the first")
+	history := flags.Int("history", 3, "number of cycles before the failing attempt to show graph nodes for")
+	clock := flags.String("clock", "clk_i", "signal of the trace whose rising edges are the cycles properties are sampled in")
+	disable := flags.String("disable", "~rst_ni", "condition disabling properties, as with disable iff, or empty for none")
+	stepPrefix := flags.Bool("step-prefix", false, "Prefix all properties with Step[step number]_")
+	flags.Parse(args)
+
+	if len(paths) == 0 {
+		fmt.Println(fmt.Errorf("error: must specify at least one path"))
+		return
+	}
+	if *rootLemma == "" {
+		fmt.Println(fmt.Errorf("error: must specify a root lemma"))
+		return
+	}
+	if *name == "" {
+		fmt.Println(fmt.Errorf("error: must specify a property"))
+		return
+	}
+	if flags.NArg() != 1 {
+		fmt.Println(fmt.Errorf("error: must specify one VCD trace"))
+		return
+	}
+
+	trace, err := loadTrace(flags.Arg(0), *clock)
+	if err != nil {
+		fmt.Println(fmt.Errorf("error: %v", err))
+		return
+	}
+	scope, err := loadScope(paths, includes, defines, svIncludes)
+	if err != nil {
+		fmt.Println(err)
+		return
+	}
+	seq := genSequence(&scope, *rootLemma)
+	ev := NewEvaluator(trace, seq.wires)
+	ev.setDisable(*disable)
+
+	explanation, err := seq.explain(ev, *name, *stepPrefix, *at, *history)
+	if err != nil {
+		fmt.Println(fmt.Errorf("error: %v", err))
+		return
+	}
+	fmt.Print(explanation)
+}
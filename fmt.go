@@ -0,0 +1,278 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+	"maps"
+	"os"
+	"slices"
+	"strconv"
+	"strings"
+)
+
+func formatSource(lines []string, lineWidth int) string {
+	_, blocks := parseBlocks(lines, -1)
+	separateTopLevel(blocks)
+	return blocksToSource(blocks, 0, lineWidth)
+}
+
+func oneLine(block Block) bool {
+	return block.first.operator == "state" || block.first.operator == "import" || block.first.operator == "define"
+}
+
+// Puts a blank line before each top level block, except between consecutive imports, global states or defines, and
+// just inside ifdef blocks
+func separateTopLevel(blocks []Block) {
+	for i := range blocks {
+		if i == 0 {
+			blocks[i].blankBefore = true
+			continue
+		}
+		prev, op := blocks[i-1].first.operator, blocks[i].first.operator
+		opens := prev == "ifdef" || prev == "ifndef" || prev == "else"
+		closes := op == "else" || op == "endif"
+		blocks[i].blankBefore = !opens && !closes && (!oneLine(blocks[i]) || op != prev)
+	}
+}
+
+// The SystemVerilog generated for every lemma in a document with some names defined, or the error encountered
+// generating it
+func documentSva(lines []string, defines map[string]string) (svas map[string]string, err error) {
+	defer func() {
+		if r := recover(); r != nil {
+			err = fmt.Errorf("%v", r)
+		}
+	}()
+
+	_, blocks := parseBlocks(preprocess(lines, defines), -1)
+	doc := blocksToProofDocument(blocks)
+	svas = map[string]string{}
+	for _, name := range slices.Sorted(maps.Keys(doc.lemmas)) {
+		svas[name] = lemmaSva(&doc, name)
+	}
+	return svas, nil
+}
+
+// Documents testing more names than this are only checked with none and all of them defined
+const maxCheckedDefines = 6
+
+// The names tested by ifdef and ifndef lines
+func ifdefNames(lines []string) []string {
+	names := []string{}
+	for _, line := range lines {
+		fields := strings.Fields(strings.SplitN(line, "# ", 2)[0])
+		if len(fields) == 2 && (fields[0] == "ifdef" || fields[0] == "ifndef") && !slices.Contains(names, fields[1]) {
+			names = append(names, fields[1])
+		}
+	}
+	slices.Sort(names)
+	return names
+}
+
+// The sets of names to check a document with, so that every branch of its ifdefs is checked
+func defineSets(names []string) []map[string]string {
+	sets := []map[string]string{}
+	for mask := range 1 << len(names) {
+		if len(names) > maxCheckedDefines && mask != 0 && mask != 1<<len(names)-1 {
+			continue
+		}
+		defines := map[string]string{}
+		for i, name := range names {
+			if mask&(1<<i) != 0 {
+				defines[name] = "1"
+			}
+		}
+		sets = append(sets, defines)
+	}
+	return sets
+}
+
+// Removes whole line comments, such as provenance, from generated SystemVerilog
+func stripSvComments(sva string) string {
+	lines := []string{}
+	for _, line := range strings.Split(sva, "\n") {
+		if !strings.HasPrefix(strings.TrimSpace(line), "//") {
+			lines = append(lines, line)
+		}
+	}
+	return strings.Join(lines, "\n")
+}
+
+func lemmaSva(doc *ProofDocument, name string) (sva string) {
+	defer func() {
+		if r := recover(); r != nil {
+			sva = fmt.Sprintf("error: %v", r)
+		}
+	}()
+
+	scope := NewScope(doc)
+	// Warnings are given when generating, not for every lemma checked while formatting
+	scope.env.quiet = true
+	lemma := doc.lemmas[name]
+	seq := NewFlatProofSequence()
+	lemma.genProperty(&scope).flatten(&seq, FlatPosition{after: []int{}})
+	// Formatting may only change whitespace within expressions, and moves the source lines provenance refers to
+	return strings.Join(strings.Fields(stripSvComments(seq.toSva(-1, false, false, 100))), " ")
+}
+
+// Checks that formatting has not changed the properties generated by any lemma, with each set of names its ifdefs test
+// defined
+func checkFormatted(before []string, after []string) error {
+	for _, defines := range defineSets(ifdefNames(before)) {
+		with := ""
+		if len(defines) > 0 {
+			with = " with " + strings.Join(slices.Sorted(maps.Keys(defines)), ", ") + " defined"
+		}
+		svaBefore, errBefore := documentSva(before, defines)
+		svaAfter, errAfter := documentSva(after, defines)
+		if errBefore != nil || errAfter != nil {
+			// A document which is already broken must remain broken in the same way
+			if errBefore == nil || errAfter == nil || errBefore.Error() != errAfter.Error() {
+				return fmt.Errorf("formatting changes the document%s: before: %v, after: %v", with, errBefore, errAfter)
+			}
+			continue
+		}
+
+		for _, name := range slices.Sorted(maps.Keys(svaBefore)) {
+			if svaBefore[name] != svaAfter[name] {
+				return fmt.Errorf("formatting changes the properties of lemma %s%s", name, with)
+			}
+		}
+		if len(svaBefore) != len(svaAfter) {
+			return fmt.Errorf("formatting changes the set of lemmas%s", with)
+		}
+	}
+	return nil
+}
+
+type diffOp struct {
+	kind byte
+	line string
+	a    int
+	b    int
+}
+
+// A unified diff of two files, based on their longest common subsequence of lines
+func unifiedDiff(name string, a []string, b []string) string {
+	lcs := make([][]int, len(a)+1)
+	for i := range lcs {
+		lcs[i] = make([]int, len(b)+1)
+	}
+	for i := len(a) - 1; i >= 0; i-- {
+		for j := len(b) - 1; j >= 0; j-- {
+			if a[i] == b[j] {
+				lcs[i][j] = lcs[i+1][j+1] + 1
+			} else {
+				lcs[i][j] = max(lcs[i+1][j], lcs[i][j+1])
+			}
+		}
+	}
+
+	ops := []diffOp{}
+	i, j := 0, 0
+	for i < len(a) || j < len(b) {
+		if i < len(a) && j < len(b) && a[i] == b[j] {
+			ops = append(ops, diffOp{' ', a[i], i, j})
+			i += 1
+			j += 1
+		} else if i < len(a) && (j == len(b) || lcs[i+1][j] >= lcs[i][j+1]) {
+			ops = append(ops, diffOp{'-', a[i], i, j})
+			i += 1
+		} else {
+			ops = append(ops, diffOp{'+', b[j], i, j})
+			j += 1
+		}
+	}
+
+	const context = 3
+	diff := ""
+	k := 0
+	for k < len(ops) {
+		if ops[k].kind == ' ' {
+			k += 1
+			continue
+		}
+
+		start := max(k-context, 0)
+		end := k
+		for end < len(ops) {
+			if ops[end].kind != ' ' {
+				end += 1
+				continue
+			}
+			run := end
+			for run < len(ops) && ops[run].kind == ' ' {
+				run += 1
+			}
+			if run == len(ops) || run-end > 2*context {
+				end = min(end+context, len(ops))
+				break
+			}
+			end = run
+		}
+
+		countA, countB := 0, 0
+		hunk := ""
+		for _, op := range ops[start:end] {
+			hunk += string(op.kind) + op.line + "\n"
+			if op.kind != '+' {
+				countA += 1
+			}
+			if op.kind != '-' {
+				countB += 1
+			}
+		}
+		diff += "@@ -" + strconv.Itoa(ops[start].a+1) + "," + strconv.Itoa(countA) +
+			" +" + strconv.Itoa(ops[start].b+1) + "," + strconv.Itoa(countB) + " @@\n" + hunk
+		k = end
+	}
+
+	if diff == "" {
+		return ""
+	}
+	return "--- " + name + "\n+++ " + name + "\n" + diff
+}
+
+func fmtMain(args []string) {
+	flags := flag.NewFlagSet("fmt", flag.ExitOnError)
+	write := flags.Bool("w", false, "write the formatted source back to each file instead of printing it, if it generates the "+
+		"same properties with each combination of the names tested by ifdef defined")
+	diff := flags.Bool("d", false, "print a diff of the changes formatting would make")
+	lineWidth := flags.Int("width", 100, "line width to break long expressions at")
+	flags.Parse(args)
+
+	if flags.NArg() == 0 {
+		fmt.Println(fmt.Errorf("error: must specify at least one file to format"))
+		return
+	}
+
+	for _, path := range flags.Args() {
+		data, err := os.ReadFile(path)
+		if err != nil {
+			fmt.Println(err)
+			return
+		}
+
+		before := strings.Split(string(data), "\n")
+		formatted := formatSource(before, *lineWidth)
+		after := strings.Split(formatted, "\n")
+		if err := checkFormatted(before, after); err != nil {
+			fmt.Println(fmt.Errorf("error: refusing to format %s: %v", path, err))
+			continue
+		}
+
+		if *diff {
+			fmt.Print(unifiedDiff(path, strings.Split(strings.TrimSuffix(string(data), "\n"), "\n"), strings.Split(strings.TrimSuffix(formatted, "\n"), "\n")))
+		}
+		if *write {
+			if formatted != string(data) {
+				if err := os.WriteFile(path, []byte(formatted), 0664); err != nil {
+					fmt.Println(fmt.Errorf("error: %v", err))
+					continue
+				}
+			}
+		} else if !*diff {
+			fmt.Print(formatted)
+		}
+	}
+}
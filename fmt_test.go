@@ -0,0 +1,83 @@
+package main
+
+import (
+	"strings"
+	"testing"
+)
+
+const unformatted = `# A comment
+state busy (en &&  fin)
+lemma l
+  # inner comment
+  A:   have (a&&b)
+
+  B: have (a) => b
+  C:  block
+      D: have (a_very_long_signal_name && another_very_long_signal_name || yet_another_long_signal_name && the_last_one)
+  cond (x \
+    && y)
+  have (z)
+lemma m
+  have (q)
+`
+
+const formatted = `# A comment
+state busy (en && fin)
+
+lemma l
+    # inner comment
+    A: have (a&&b)
+
+    B: have (a) => b
+    C: block
+        D: have (a_very_long_signal_name && \
+            another_very_long_signal_name || \
+            yet_another_long_signal_name && the_last_one)
+    cond (x && y)
+    have (z)
+
+lemma m
+    have (q)
+`
+
+func TestFormatSource(t *testing.T) {
+	got := formatSource(strings.Split(unformatted, "\n"), 60)
+	if got != formatted {
+		t.Errorf("formatted:\n%s\nwant:\n%s", got, formatted)
+	}
+	if again := formatSource(strings.Split(got, "\n"), 60); again != got {
+		t.Errorf("formatting again gives:\n%s\nwant:\n%s", again, got)
+	}
+	if err := checkFormatted(strings.Split(unformatted, "\n"), strings.Split(got, "\n")); err != nil {
+		t.Error(err)
+	}
+
+	// Expressions which fit are kept on one line
+	if wide := formatSource(strings.Split(unformatted, "\n"), 200); strings.Contains(wide, "\\") {
+		t.Errorf("formatted at width 200 with continuations:\n%s", wide)
+	}
+}
+
+func TestCheckFormatted(t *testing.T) {
+	before := "lemma l\n    ifdef X\n    have (a)\n    else\n    have (b)\n    endif\n"
+	for _, test := range []struct {
+		after string
+		err   string
+	}{
+		{"lemma l\n    ifdef X\n    have (a)\n    else\n    have (b)\n    endif\n", ""},
+		{"lemma l\n    ifdef X\n    have (c)\n    else\n    have (b)\n    endif\n", "formatting changes the properties of lemma l with X defined"},
+		{"lemma l\n    ifdef X\n    have (a)\n    else\n    have (c)\n    endif\n", "formatting changes the properties of lemma l"},
+	} {
+		err := checkFormatted(strings.Split(before, "\n"), strings.Split(test.after, "\n"))
+		if (err == nil) != (test.err == "") || (err != nil && err.Error() != test.err) {
+			t.Errorf("checking %q gave %v, want %q", test.after, err, test.err)
+		}
+	}
+
+	if sets := defineSets(ifdefNames([]string{"ifdef A", "ifndef B", "ifdef A"})); len(sets) != 4 {
+		t.Errorf("checked %d sets of defines for two names, want 4", len(sets))
+	}
+	if sets := defineSets([]string{"A", "B", "C", "D", "E", "F", "G"}); len(sets) != 2 || len(sets[0]) != 0 || len(sets[1]) != 7 {
+		t.Errorf("checked %v for seven names, want none and all of them", sets)
+	}
+}
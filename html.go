@@ -0,0 +1,387 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+	"html"
+	"math"
+	"os"
+	"strconv"
+	"strings"
+)
+
+const htmlStyle = `
+body { font-family: sans-serif; margin: 2em; color: #222; }
+code, pre { font-family: monospace; }
+pre { background: #f6f6f6; padding: 0.5em; margin: 0.25em 0 0.5em 1.5em; overflow-x: auto; }
+details { margin-left: 1.25em; }
+details.section { margin-left: 0; margin-top: 1em; }
+summary.section { font-size: 1.4em; font-weight: bold; }
+summary { cursor: pointer; padding: 0.1em 0; }
+.leaf { margin-left: 2.25em; padding: 0.1em 0; }
+.source { color: #888; font-size: 0.9em; }
+.status { display: inline-block; min-width: 8em; font-weight: bold; }
+.unsound { color: #a00; margin-left: 1.25em; }
+.proven { background: #d4f7d4; }
+.cex { background: #f9d0d0; }
+.undetermined { background: #fff3c4; }
+.vacuous { background: #e6ddf5; }
+.missing { background: #eeeeee; }
+.covered { background: #d4f7d4; }
+.uncovered { background: #f9d0d0; }
+table { border-collapse: collapse; margin: 0.5em 0 1em 1.25em; }
+td, th { border: 1px solid #ccc; padding: 0.2em 0.6em; text-align: left; }
+svg { margin-left: 1.25em; }
+svg circle { fill: #fff; stroke: #333; }
+svg circle.entry { fill: #ddd; }
+svg path { fill: none; stroke: #333; }
+svg path.eps { stroke-dasharray: 5 4; }
+svg text { font-size: 12px; }
+`
+
+// A graph induction found in the document, named after the labels leading to it
+type namedGraph struct {
+	name  string
+	graph *GraphInductionProofHelper
+}
+
+func qualify(name string, label string) string {
+	if label == "" {
+		return name
+	}
+	return name + "_" + label
+}
+
+func graphsOfSeq(seq *SequencedProofSteps, name string, graphs *[]namedGraph) {
+	for _, step := range seq.sequence {
+		for _, cmd := range step {
+			switch cmd := cmd.(type) {
+			case *EachProofCommand:
+				graphsOfSeq(&cmd.seq, qualify(name, cmd.label), graphs)
+			case *InStatesSubProofCommand:
+				graphsOfSeq(&cmd.seq, qualify(name, cmd.label), graphs)
+			case *BlockProofCommand:
+				graphsOfSeq(&cmd.seq, qualify(name, cmd.label), graphs)
+			case *HaveProofCommand:
+				graphsOfHelper(cmd.helper, qualify(name, cmd.label), graphs)
+			case *UseProofCommand:
+				graphsOfHelper(cmd.helper, qualify(name, cmd.name), graphs)
+			case *GraphInductionProofCommand:
+				graphsOfHelper(&cmd.proof, name, graphs)
+			}
+		}
+	}
+}
+
+func graphsOfHelper(helper HelpProperty, name string, graphs *[]namedGraph) {
+	switch helper := helper.(type) {
+	case *SplitProofHelper:
+		for _, c := range helper.cases {
+			graphsOfHelper(c.helper, qualify(name, c.label), graphs)
+		}
+	case *SplitBoolProofHelper:
+		graphsOfHelper(helper.helper, name, graphs)
+	case *SequenceProofHelper:
+		for _, sub := range helper.helpers {
+			graphsOfHelper(sub, name, graphs)
+		}
+	case *GraphInductionProofHelper:
+		name = qualify(name, helper.label)
+		*graphs = append(*graphs, namedGraph{name: name, graph: helper})
+		graphsOfHelper(helper.entryHelper, name, graphs)
+		for _, node := range helper.nodeNames() {
+			graphsOfHelper(helper.nodes[node].helper, qualify(name, camelCase(node)), graphs)
+		}
+	}
+}
+
+type svgNode struct {
+	x, y, r float64
+}
+
+func svgFloat(f float64) string {
+	return strconv.FormatFloat(f, 'f', 1, 64)
+}
+
+// An arrow between two nodes, curved so that edges in opposite directions do not overlap
+func svgEdge(from svgNode, to svgNode, class string, marker string) string {
+	d := ""
+	if from == to {
+		d = "M " + svgFloat(from.x-from.r*0.5) + " " + svgFloat(from.y-from.r*0.85) +
+			" C " + svgFloat(from.x-from.r*1.5) + " " + svgFloat(from.y-from.r*2.6) +
+			" " + svgFloat(from.x+from.r*1.5) + " " + svgFloat(from.y-from.r*2.6) +
+			" " + svgFloat(from.x+from.r*0.5) + " " + svgFloat(from.y-from.r*0.85)
+	} else {
+		dx, dy := to.x-from.x, to.y-from.y
+		dist := math.Hypot(dx, dy)
+		ux, uy := dx/dist, dy/dist
+		d = "M " + svgFloat(from.x+ux*from.r) + " " + svgFloat(from.y+uy*from.r) +
+			" Q " + svgFloat((from.x+to.x)/2-uy*18) + " " + svgFloat((from.y+to.y)/2+ux*18) +
+			" " + svgFloat(to.x-ux*(to.r+2)) + " " + svgFloat(to.y-uy*(to.r+2))
+	}
+	return `<path class="` + class + `" d="` + d + `" marker-end="url(#` + marker + `)"/>` + "\n"
+}
+
+// A diagram of the nodes of a graph induction laid out on a circle, solid edges taking a cycle and dashed edges none
+func graphSvg(graph *GraphInductionProofHelper, id int) string {
+	labels := []string{}
+	if graph.entryCondition != nil {
+		labels = append(labels, "entry")
+	}
+	names := graph.nodeNames()
+	labels = append(labels, names...)
+
+	maxR := 0.0
+	radii := []float64{}
+	for _, label := range labels {
+		r := max(22, 3.5*float64(len(label))+10)
+		radii = append(radii, r)
+		maxR = max(maxR, r)
+	}
+	layout := 0.0
+	if len(labels) > 1 {
+		layout = max(90, float64(len(labels))*30+maxR)
+	}
+	size := 2*(layout+maxR) + 80
+	centre := size / 2
+
+	nodes := []svgNode{}
+	for i := range labels {
+		angle := 2*math.Pi*float64(i)/float64(len(labels)) - math.Pi/2
+		nodes = append(nodes, svgNode{x: centre + layout*math.Cos(angle), y: centre + layout*math.Sin(angle), r: radii[i]})
+	}
+	node := map[string]svgNode{}
+	offset := len(labels) - len(names)
+	for i, name := range names {
+		node[name] = nodes[offset+i]
+	}
+
+	marker := "arrow" + strconv.Itoa(id)
+	svg := `<svg xmlns="http://www.w3.org/2000/svg" width="` + svgFloat(size) + `" height="` + svgFloat(size) + `">` + "\n"
+	svg += `<defs><marker id="` + marker + `" viewBox="0 0 10 10" refX="9" refY="5" markerWidth="7" markerHeight="7" orient="auto-start-reverse"><path d="M 0 0 L 10 5 L 0 10 z" fill="#333"/></marker></defs>` + "\n"
+
+	if graph.entryCondition != nil {
+		for _, name := range graph.entryNodes {
+			if to, ok := node[name]; ok {
+				svg += svgEdge(nodes[0], to, "eps", marker)
+			}
+		}
+	}
+	for _, name := range names {
+		def := graph.nodes[name]
+		for _, next := range def.stepTransitions {
+			if to, ok := node[next]; ok {
+				svg += svgEdge(node[name], to, "step", marker)
+			}
+		}
+		for _, next := range def.epsTransitions {
+			if to, ok := node[next]; ok {
+				svg += svgEdge(node[name], to, "eps", marker)
+			}
+		}
+	}
+
+	for i, label := range labels {
+		n := nodes[i]
+		class := ""
+		if i < offset {
+			class = ` class="entry"`
+		}
+		svg += `<circle` + class + ` cx="` + svgFloat(n.x) + `" cy="` + svgFloat(n.y) + `" r="` + svgFloat(n.r) + `"/>` + "\n"
+		if i >= offset && graph.nodes[label].exit {
+			svg += `<circle cx="` + svgFloat(n.x) + `" cy="` + svgFloat(n.y) + `" r="` + svgFloat(n.r-4) + `"/>` + "\n"
+		}
+		svg += `<text x="` + svgFloat(n.x) + `" y="` + svgFloat(n.y) + `" text-anchor="middle" dominant-baseline="central">` + html.EscapeString(label) + "</text>\n"
+	}
+	return svg + "</svg>\n"
+}
+
+func graphHtml(named namedGraph, id int) string {
+	graph := named.graph
+	str := "<details open><summary>" + html.EscapeString(named.name)
+	if src := graph.source.String(); src != "" {
+		str += ` <span class="source">` + html.EscapeString(src) + "</span>"
+	}
+	str += "</summary>\n" + graphSvg(graph, id)
+	str += "<table>\n<tr><th>Node</th><th>Condition</th><th>Invariant</th><th>Next cycle (=&gt;)</th><th>Same cycle (-&gt;)</th></tr>\n"
+	if graph.entryCondition != nil {
+		str += "<tr><td>entry</td><td><code>" + html.EscapeString(streamToString(trimWhitespace(graph.entryCondition))) + "</code></td><td></td><td></td><td>" +
+			html.EscapeString(strings.Join(graph.entryNodes, " ")) + "</td></tr>\n"
+	}
+	for _, name := range graph.nodeNames() {
+		node := graph.nodes[name]
+		label := name
+		if node.exit {
+			label += " (exit)"
+		}
+		str += "<tr><td>" + html.EscapeString(label) + "</td><td><code>" + html.EscapeString(node.condition.describe()) + "</code></td><td><code>" +
+			html.EscapeString(node.invariant.describe()) + "</code></td><td>" + html.EscapeString(strings.Join(node.stepTransitions, " ")) + "</td><td>" +
+			html.EscapeString(strings.Join(node.epsTransitions, " ")) + "</td></tr>\n"
+	}
+	return str + "</table>\n</details>\n"
+}
+
+// The lemma, def and helper tree as nested collapsible commands
+func blockHtml(block Block) string {
+	summary := "<code>" + html.EscapeString(block.first.toSource()) + "</code>"
+	if len(block.body) == 0 {
+		return `<div class="leaf">` + summary + "</div>\n"
+	}
+	str := "<details open><summary>" + summary + "</summary>\n"
+	for _, child := range block.body {
+		str += blockHtml(child)
+	}
+	return str + "</details>\n"
+}
+
+func propertyHtml(entry ReportProperty, withResults bool, clocking bool, stepPrefix bool) string {
+	class, status := "", ""
+	if withResults {
+		class = ` class="` + entry.status + `"`
+		status = `<span class="status">` + entry.status + "</span> "
+	}
+	str := "<details" + class + "><summary>" + status + "<code>" + html.EscapeString(entry.name) + "</code>"
+	if withResults && entry.time != "" {
+		str += " " + html.EscapeString(entry.time)
+	}
+	if src := entry.prop.source.String(); src != "" {
+		str += ` <span class="source">` + html.EscapeString(src) + "</span>"
+	}
+	str += "</summary>\n<pre>" + html.EscapeString(entry.prop.toSva(false, clocking, stepPrefix, 100, entry.step)) + "</pre>\n</details>\n"
+	return str
+}
+
+func stepsHtml(report *ProofReport, withResults bool, clocking bool, stepPrefix bool) string {
+	str := ""
+	for i, step := range report.steps {
+		count := 0
+		for _, lemma := range step.lemmas {
+			count += len(lemma.props)
+		}
+		detail := strconv.Itoa(count) + " properties"
+		if after := report.stepAfter(step); after != "" {
+			detail += ", " + after
+		}
+		str += "<details open><summary>Step " + strconv.Itoa(i) + ` <span class="source">` + html.EscapeString(detail) + "</span></summary>\n"
+		if withResults && len(step.unsound) > 0 {
+			str += `<div class="unsound">Unsound: ` + html.EscapeString(step.unsoundSummary()) + "</div>\n"
+		}
+		for _, lemma := range step.lemmas {
+			str += "<details open><summary>lemma " + html.EscapeString(lemma.name) + "</summary>\n"
+			for _, entry := range lemma.props {
+				str += propertyHtml(entry, withResults, clocking, stepPrefix)
+			}
+			str += "</details>\n"
+		}
+		str += "</details>\n"
+	}
+	return str
+}
+
+func proofHtml(scope *Scope, seq *FlatProofSequence, report *ProofReport, withResults bool, clocking bool, stepPrefix bool) string {
+	title := html.EscapeString(report.root)
+	str := "<!DOCTYPE html>\n<html>\n<head>\n<meta charset=\"utf-8\">\n<title>" + title + "</title>\n<style>" + htmlStyle + "</style>\n</head>\n<body>\n"
+	str += "<h1>" + title + "</h1>\n"
+	if withResults {
+		str += "<p>" + html.EscapeString(report.summary()) + "</p>\n"
+		if covers := report.coverSummary(); covers != "" {
+			str += "<p>Covers: " + html.EscapeString(covers) + "</p>\n"
+		}
+		if len(report.unmatched) > 0 {
+			str += "<p>Unmatched results: " + html.EscapeString(strings.Join(report.unmatched, ", ")) + "</p>\n"
+		}
+	}
+
+	str += "<details class=\"section\" open><summary class=\"section\">Proof Steps</summary>\n"
+	str += stepsHtml(report, withResults, clocking, stepPrefix)
+	str += "</details>\n"
+
+	graphs := []namedGraph{}
+	doc := ProofDocument{defs: scope.env.defs, lemmas: scope.env.lemmas, states: map[string]GlobalState{}}
+	for _, block := range doc.toBlocks() {
+		name := block.first.inlineArgs[0].toSource()
+		if block.first.operator == "def" {
+			seq := doc.defs[name]
+			graphsOfSeq(&seq, name, &graphs)
+		} else {
+			lemma := doc.lemmas[name]
+			graphsOfSeq(&lemma.seq, qualify(name, lemma.label), &graphs)
+		}
+	}
+	if len(graphs) > 0 {
+		str += "<details class=\"section\" open><summary class=\"section\">Graph Induction</summary>\n"
+		for i, graph := range graphs {
+			str += graphHtml(graph, i)
+		}
+		str += "</details>\n"
+	}
+
+	str += "<details class=\"section\"><summary class=\"section\">Lemmas and Defs</summary>\n"
+	for _, block := range doc.toBlocks() {
+		str += blockHtml(block)
+	}
+	str += "</details>\n"
+
+	if len(seq.wires) > 0 {
+		wires := ""
+		for _, wire := range seq.wires {
+			wires += wire.toSva(100) + "\n"
+		}
+		str += "<details class=\"section\"><summary class=\"section\">Wiring</summary>\n<pre>" + html.EscapeString(wires) + "</pre>\n</details>\n"
+	}
+
+	return str + "</body>\n</html>\n"
+}
+
+func htmlMain(args []string) {
+	flags := flag.NewFlagSet("html", flag.ExitOnError)
+	paths := []string{}
+	flags.Func("path", "paths to source files", func(s string) error {
+		paths = append(paths, s)
+		return nil
+	})
+	includes := []string{}
+	includeFlag(flags, &includes)
+	defines := map[string]string{}
+	definesFlag(flags, defines)
+	svIncludes := []string{}
+	svIncludeFlag(flags, &svIncludes)
+	resultPaths := []string{}
+	resultsFlag(flags, &resultPaths)
+	rootLemma := flags.String("root", "", "name of root lemma")
+	format := flags.String("format", "auto", "format of the result files: jasper, sby, csv or auto")
+	clocking := flags.Bool("clocking", false, "produce @(posedge clk_i) disable iff (~rst_ni) in front of each property")
+	stepPrefix := flags.Bool("step-prefix", false, "Prefix all properties with Step[step number]_")
+	out := flags.String("o", "", "path to write the page to, or empty to print it")
+	flags.Parse(args)
+
+	if len(paths) == 0 {
+		fmt.Println(fmt.Errorf("error: must specify at least one path"))
+		return
+	}
+	if *rootLemma == "" {
+		fmt.Println(fmt.Errorf("error: must specify a root lemma"))
+		return
+	}
+
+	results, err := loadResults(resultPaths, *format)
+	if err != nil {
+		fmt.Println(fmt.Errorf("error: %v", err))
+		return
+	}
+
+	scope, err := loadScope(paths, includes, defines, svIncludes)
+	if err != nil {
+		fmt.Println(err)
+		return
+	}
+	seq := genSequence(&scope, *rootLemma)
+	report := buildReport(&seq, *rootLemma, results, *stepPrefix)
+	page := proofHtml(&scope, &seq, &report, len(resultPaths) > 0, *clocking, *stepPrefix)
+
+	if *out != "" {
+		os.WriteFile(*out, []byte(page), 0664)
+	} else {
+		fmt.Print(page)
+	}
+}
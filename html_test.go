@@ -0,0 +1,62 @@
+package main
+
+import (
+	"strings"
+	"testing"
+)
+
+const htmlProof = `
+lemma l
+  / base
+  P: have (a < b)
+  / left after base
+  Q: have (q)
+  / right after base
+  G: graph_induction
+    inv ok (x)
+    entry (start) -> idle
+    node idle ok (~run) => busy
+    node busy ok (run) => idle
+`
+
+func TestProofHtml(t *testing.T) {
+	seq := testSequence(t, htmlProof, "l")
+	scope, err := loadScope([]string{seq.props[0][0].source.file}, []string{}, map[string]string{}, []string{})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	results := []PropertyResult{{"P", StatusCex, "3s"}, {"Q", StatusProven, ""}}
+	report := buildReport(&seq, "l", results, false)
+	page := proofHtml(&scope, &seq, &report, true, false, false)
+	for _, want := range []string{
+		"<title>l</title>",
+		"<p>1 proven, 1 cex, 8 missing</p>",
+		// Properties show their status and time, with their SystemVerilog escaped
+		`<details class="cex"><summary><span class="status">cex</span> <code>P</code> 3s`,
+		"P: assert property (a &lt; b);",
+		`<details class="proven"><summary><span class="status">proven</span> <code>Q</code>`,
+		// Each step after base assumes the failed P
+		"Step 1 <span class=\"source\">1 properties, after 0</span>",
+		`<div class="unsound">Unsound: assumes 1 unproven properties from step 0</div>`,
+		"<summary>l_G ",
+		"<tr><td>entry</td><td><code>start</code></td><td></td><td></td><td>idle</td></tr>",
+		"<tr><td>idle</td><td><code>(~run)</code></td><td><code>ok</code></td><td>busy</td><td></td></tr>",
+		"<svg",
+		"<summary class=\"section\">Wiring</summary>",
+		"assign g_idle = ~run;",
+	} {
+		if !strings.Contains(page, want) {
+			t.Errorf("no %s in page:\n%s", want, page)
+		}
+	}
+	if strings.Count(page, `<div class="unsound">`) != 3 {
+		t.Errorf("page does not mark exactly the three steps after base unsound:\n%s", page)
+	}
+
+	// Without results the page only shows the proof
+	page = proofHtml(&scope, &seq, &report, false, false, false)
+	if strings.Contains(page, `<span class="status">`) || strings.Contains(page, `<div class="unsound">`) || strings.Contains(page, "missing</p>") {
+		t.Errorf("page without results shows statuses:\n%s", page)
+	}
+}
@@ -0,0 +1,997 @@
+package main
+
+import (
+	"bufio"
+	"encoding/json"
+	"fmt"
+	"io"
+	"maps"
+	"net/url"
+	"os"
+	"slices"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// A Language Server Protocol server over stdio, supporting diagnostics, go to definition, hover, completion and
+// document symbols
+
+type lspPosition struct {
+	Line      int `json:"line"`
+	Character int `json:"character"`
+}
+
+type lspRange struct {
+	Start lspPosition `json:"start"`
+	End   lspPosition `json:"end"`
+}
+
+type lspLocation struct {
+	URI   string   `json:"uri"`
+	Range lspRange `json:"range"`
+}
+
+type lspDiagnostic struct {
+	Range    lspRange `json:"range"`
+	Severity int      `json:"severity"`
+	Source   string   `json:"source"`
+	Message  string   `json:"message"`
+}
+
+type lspDocumentSymbol struct {
+	Name           string   `json:"name"`
+	Detail         string   `json:"detail,omitempty"`
+	Kind           int      `json:"kind"`
+	Range          lspRange `json:"range"`
+	SelectionRange lspRange `json:"selectionRange"`
+}
+
+type lspCompletionItem struct {
+	Label  string `json:"label"`
+	Kind   int    `json:"kind"`
+	Detail string `json:"detail,omitempty"`
+}
+
+type lspTextDocumentPosition struct {
+	TextDocument struct {
+		URI string `json:"uri"`
+	} `json:"textDocument"`
+	Position lspPosition `json:"position"`
+}
+
+type lspRequest struct {
+	ID     json.RawMessage `json:"id,omitempty"`
+	Method string          `json:"method"`
+	Params json.RawMessage `json:"params,omitempty"`
+}
+
+type lspResponse struct {
+	JSONRPC string          `json:"jsonrpc"`
+	ID      json.RawMessage `json:"id"`
+	Result  any             `json:"result"`
+}
+
+type lspErrorResponse struct {
+	JSONRPC string          `json:"jsonrpc"`
+	ID      json.RawMessage `json:"id"`
+	Error   struct {
+		Code    int    `json:"code"`
+		Message string `json:"message"`
+	} `json:"error"`
+}
+
+type lspNotification struct {
+	JSONRPC string `json:"jsonrpc"`
+	Method  string `json:"method"`
+	Params  any    `json:"params"`
+}
+
+const (
+	LSP_SEVERITY_ERROR   = 1
+	LSP_SEVERITY_WARNING = 2
+
+	LSP_SYMBOL_METHOD   = 6
+	LSP_SYMBOL_FUNCTION = 12
+	LSP_SYMBOL_VARIABLE = 13
+
+	LSP_COMPLETION_FUNCTION = 3
+	LSP_COMPLETION_VARIABLE = 6
+	LSP_COMPLETION_MODULE   = 9
+	LSP_COMPLETION_ENUM     = 20
+)
+
+type proofSymbol struct {
+	kind string // lemma, def, state, node or inv
+	name string
+	uri  string
+	line int // 0-indexed, as are all positions in this file
+	col  int // In UTF-16 code units, as are all columns sent or received
+	end  int // Last line of the definition's body
+}
+
+// The length of a string in UTF-16 code units
+func utf16Len(s string) int {
+	n := 0
+	for _, r := range s {
+		if r >= 0x10000 {
+			n += 2
+		} else {
+			n += 1
+		}
+	}
+	return n
+}
+
+func (sym *proofSymbol) location() lspLocation {
+	return lspLocation{
+		URI: sym.uri,
+		Range: lspRange{
+			Start: lspPosition{sym.line, sym.col},
+			End:   lspPosition{sym.line, sym.col + utf16Len(sym.name)},
+		},
+	}
+}
+
+type proofReference struct {
+	kind    string
+	name    string
+	line    int
+	col     int
+	target  *proofSymbol // Only set for references which resolve within the document
+	dynamic bool         // Within a def, whose states may be those of the lemmas using it
+}
+
+// Everything which is known about a single open document
+type proofIndex struct {
+	uri         string
+	lines       []string
+	blocks      []Block
+	doc         ProofDocument
+	symbols     []*proofSymbol
+	refs        []proofReference
+	haves       map[int][]Block // The path of blocks to each have, by line
+	diagnostics []lspDiagnostic
+	states      map[string]*proofSymbol // States declared outside of any lemma or def
+}
+
+func lineRange(lines []string, line int) lspRange {
+	width := 0
+	if line < len(lines) {
+		width = utf16Len(lines[line])
+	}
+	return lspRange{Start: lspPosition{line, 0}, End: lspPosition{line, width}}
+}
+
+// Diagnoses the panic raised while handling part of a document
+func (index *proofIndex) diagnose(r any, fallbackLine int) {
+	line := fallbackLine
+	msg := fmt.Sprint(r)
+	if err, ok := r.(SourceError); ok {
+		line = err.line - 1
+		msg = fmt.Sprint(err.err)
+	}
+	index.diagnostics = append(index.diagnostics, lspDiagnostic{
+		Range:    lineRange(index.lines, line),
+		Severity: LSP_SEVERITY_ERROR,
+		Source:   "psgen",
+		Message:  msg,
+	})
+}
+
+// The column in UTF-16 code units of a byte offset into a line
+func (index *proofIndex) column(line int, col int) int {
+	if line < 0 || line >= len(index.lines) || col > len(index.lines[line]) {
+		return col
+	}
+	return utf16Len(index.lines[line][:col])
+}
+
+func (index *proofIndex) diagnoseAt(line int, col int, length int, msg string) {
+	index.diagnostics = append(index.diagnostics, lspDiagnostic{
+		Range: lspRange{
+			Start: lspPosition{line, col},
+			End:   lspPosition{line, col + length},
+		},
+		Severity: LSP_SEVERITY_ERROR,
+		Source:   "psgen",
+		Message:  msg,
+	})
+}
+
+func newProofIndex(uri string, text string) *proofIndex {
+	index := &proofIndex{
+		uri:         uri,
+		lines:       strings.Split(text, "\n"),
+		blocks:      []Block{},
+		doc:         NewProofDocument(),
+		symbols:     []*proofSymbol{},
+		refs:        []proofReference{},
+		haves:       map[int][]Block{},
+		diagnostics: []lspDiagnostic{},
+		states:      map[string]*proofSymbol{},
+	}
+
+	func() {
+		defer func() {
+			if r := recover(); r != nil {
+				index.diagnose(r, 0)
+			}
+		}()
+		_, index.blocks = parseBlocks(preprocess(index.lines, map[string]string{}), -1)
+		setBlocksFile(index.blocks, uriPath(uri))
+	}()
+
+	for _, block := range index.blocks {
+		func() {
+			defer func() {
+				if r := recover(); r != nil {
+					index.diagnose(r, block.first.line-1)
+				}
+			}()
+			index.doc.addBlock(block)
+		}()
+	}
+
+	for _, block := range index.blocks {
+		if block.first.operator == "state" {
+			if sym := index.define("state", &block.first, 0); sym != nil {
+				index.states[sym.name] = sym
+			}
+			continue
+		}
+		if len(block.first.inlineArgs) != 1 {
+			continue
+		}
+		if block.first.operator != "lemma" && block.first.operator != "def" {
+			continue
+		}
+		if sym := index.define(block.first.operator, &block.first, 0); sym != nil {
+			sym.end = blockEnd(block)
+		}
+	}
+	for _, block := range index.blocks {
+		start := len(index.refs)
+		index.walkSeq(block.body, []map[string]*proofSymbol{index.states}, []Block{block})
+		if block.first.operator == "def" {
+			for i := start; i < len(index.refs); i++ {
+				index.refs[i].dynamic = true
+			}
+		}
+	}
+
+	return index
+}
+
+func blockEnd(block Block) int {
+	if len(block.body) == 0 {
+		return block.first.line - 1
+	}
+	return blockEnd(block.body[len(block.body)-1])
+}
+
+// Defines the word argument at index i of the command
+func (index *proofIndex) define(kind string, cmd *Command, i int) *proofSymbol {
+	if i >= len(cmd.inlineArgs) {
+		return nil
+	}
+	word, ok := cmd.inlineArgs[i].(*WordArg)
+	if !ok {
+		return nil
+	}
+	sym := &proofSymbol{
+		kind: kind,
+		name: word.word,
+		uri:  index.uri,
+		line: cmd.line - 1,
+		col:  index.column(cmd.line-1, cmd.col+word.col),
+		end:  cmd.line - 1,
+	}
+	index.symbols = append(index.symbols, sym)
+	return sym
+}
+
+// References the word argument at index i of the command, if it is a word
+func (index *proofIndex) reference(kind string, cmd *Command, i int, scope map[string]*proofSymbol) {
+	if i >= len(cmd.inlineArgs) {
+		return
+	}
+	word, ok := cmd.inlineArgs[i].(*WordArg)
+	if !ok {
+		return
+	}
+	index.referenceName(kind, word.word, cmd.line-1, cmd.col+word.col, scope)
+}
+
+// References each name trailing -> or =>
+func (index *proofIndex) referenceTrailing(kind string, cmd *Command, scope map[string]*proofSymbol) {
+	col := 0
+	for _, name := range strings.Fields(cmd.trailing) {
+		col += strings.Index(cmd.trailing[col:], name)
+		index.referenceName(kind, name, cmd.line-1, cmd.col+cmd.trailingCol+col, scope)
+		col += len(name)
+	}
+}
+
+// References a name at a byte offset into a line
+func (index *proofIndex) referenceName(kind string, name string, line int, col int, scope map[string]*proofSymbol) {
+	col = index.column(line, col)
+	ref := proofReference{kind: kind, name: name, line: line, col: col}
+	if scope != nil {
+		ref.target = scope[name]
+		// States may also be declared globally in another document
+		if ref.target == nil && kind != "state" {
+			index.diagnoseAt(line, col, utf16Len(name), "undefined "+kind+" "+name)
+		}
+	}
+	index.refs = append(index.refs, ref)
+}
+
+func flattenFrames(frames []map[string]*proofSymbol) map[string]*proofSymbol {
+	states := map[string]*proofSymbol{}
+	for _, frame := range frames {
+		maps.Copy(states, frame)
+	}
+	return states
+}
+
+func (index *proofIndex) walkSeq(body []Block, frames []map[string]*proofSymbol, path []Block) {
+	frame := map[string]*proofSymbol{}
+	for _, block := range body {
+		if block.first.operator == "state" {
+			if sym := index.define("state", &block.first, 0); sym != nil {
+				frame[sym.name] = sym
+			}
+		}
+	}
+	frames = append(slices.Clone(frames), frame)
+	states := flattenFrames(frames)
+	steps := map[string]*proofSymbol{}
+
+	for _, block := range body {
+		cmd := &block.first
+		blockPath := append(slices.Clone(path), block)
+		switch cmd.operator {
+		case "/":
+			index.walkStep(cmd, steps)
+		case "have":
+			index.haves[cmd.line-1] = blockPath
+			index.walkHelpers(block.body, states, blockPath)
+		case "lemma":
+			index.reference("lemma", cmd, 0, nil)
+		case "use":
+			index.reference("def", cmd, 0, nil)
+			index.walkHelpers(block.body, states, blockPath)
+		case "block":
+			index.walkSeq(block.body, frames, blockPath)
+		case "in":
+			for i := range cmd.inlineArgs {
+				index.reference("state", cmd, i, states)
+			}
+			index.walkSeq(block.body, frames, blockPath)
+		case "each":
+			for i := 1; i < len(cmd.inlineArgs); i++ {
+				index.reference("state", cmd, i, states)
+			}
+			index.walkSeq(block.body, frames, blockPath)
+		case "graph_induction":
+			index.walkGraph(block, states, blockPath)
+		}
+	}
+}
+
+// Defines the name of a step, and references the earlier steps it is after
+func (index *proofIndex) walkStep(cmd *Command, steps map[string]*proofSymbol) {
+	start := 0
+	if len(cmd.inlineArgs) > 0 && cmd.inlineArgs[0].toString() != "after" {
+		if sym := index.define("step", cmd, 0); sym != nil {
+			steps[sym.name] = sym
+		}
+		start = 1
+	}
+	for i := start + 1; i < len(cmd.inlineArgs); i++ {
+		// Unknown steps are already reported when the document is parsed
+		if word, ok := cmd.inlineArgs[i].(*WordArg); ok {
+			index.referenceName("step", word.word, cmd.line-1, cmd.col+word.col, nil)
+			index.refs[len(index.refs)-1].target = steps[word.word]
+		}
+	}
+}
+
+func (index *proofIndex) walkHelpers(blocks []Block, states map[string]*proofSymbol, path []Block) {
+	for _, block := range blocks {
+		cmd := &block.first
+		blockPath := append(slices.Clone(path), block)
+		switch cmd.operator {
+		case "split":
+			for i := range cmd.inlineArgs {
+				index.reference("state", cmd, i, states)
+			}
+			for _, cas := range block.body {
+				index.reference("state", &cas.first, 0, states)
+				index.walkHelpers(cas.body, states, append(slices.Clone(blockPath), cas))
+			}
+		case "split_bool":
+			for i := range cmd.inlineArgs {
+				index.reference("state", cmd, i, states)
+			}
+			index.walkHelpers(block.body, states, blockPath)
+		case "graph_induction":
+			index.walkGraph(block, states, blockPath)
+		}
+	}
+}
+
+func (index *proofIndex) walkGraph(graph Block, states map[string]*proofSymbol, path []Block) {
+	nodes := map[string]*proofSymbol{}
+	invs := map[string]*proofSymbol{}
+	for _, block := range graph.body {
+		switch block.first.operator {
+		case "node":
+			if sym := index.define("node", &block.first, 0); sym != nil {
+				nodes[sym.name] = sym
+			}
+		case "inv":
+			if sym := index.define("inv", &block.first, 0); sym != nil {
+				invs[sym.name] = sym
+			}
+		}
+	}
+
+	for _, block := range graph.body {
+		cmd := &block.first
+		blockPath := append(slices.Clone(path), block)
+		switch cmd.operator {
+		case "entry":
+			index.referenceTrailing("node", cmd, nodes)
+			index.walkHelpers(block.body, states, blockPath)
+		case "node":
+			index.reference("inv", cmd, 1, invs)
+			index.reference("state", cmd, 2, states)
+			index.referenceTrailing("node", cmd, nodes)
+			index.walkHelpers(block.body, states, blockPath)
+		case "edge":
+			index.reference("node", cmd, 0, nodes)
+			index.referenceTrailing("node", cmd, nodes)
+		}
+	}
+}
+
+// Each alternative precondition of the have at the end of path, with states resolved and each substitutions made. In a
+// def, states which are not found are those of the lemmas using it, so are left as their names, which are returned.
+func havePreConditions(path []Block, states map[string]GlobalState) ([]string, []string) {
+	pres := []string{}
+	unresolved := []string{}
+	resolve := func(vos VerbatimOrState, scope *Scope) (stream TokenStream) {
+		if vos.verbatim || path[0].first.operator != "def" {
+			return vos.getStream(scope)
+		}
+		defer func() {
+			if r := recover(); r != nil {
+				if !slices.Contains(unresolved, vos.state) {
+					unresolved = append(unresolved, vos.state)
+				}
+				stream = TokenStream{&NameToken{content: vos.state}}
+			}
+		}()
+		return vos.getStream(scope)
+	}
+
+	var expand func(i int, scope *Scope, subs map[string]TokenStream)
+	expand = func(i int, scope *Scope, subs map[string]TokenStream) {
+		if len(pres) >= 16 {
+			return
+		}
+		if i == len(path)-1 {
+			pre := conjoin(scope.getPreConditions())
+			for ident, sub := range subs {
+				pre = subsStream(pre, ident, sub)
+			}
+			pres = append(pres, strings.TrimSuffix(formatStream(pre, 80), "\n"))
+			return
+		}
+
+		block := path[i]
+		switch block.first.operator {
+		case "lemma", "def", "block":
+			scope.push(localScopeOfBlocks(block.body))
+			expand(i+1, scope, subs)
+			scope.pop()
+		case "in":
+			for _, arg := range block.first.inlineArgs {
+				vos := arg.toVerbatimOrState()
+				scope.push(&LocalScope{
+					states:     map[string]TokenStream{},
+					conditions: []TokenStream{resolve(vos, scope)},
+				})
+				scope.push(localScopeOfBlocks(block.body))
+				expand(i+1, scope, subs)
+				scope.pop()
+				scope.pop()
+			}
+		case "each":
+			for _, arg := range block.first.inlineArgs[1:] {
+				vos := arg.toVerbatimOrState()
+				newSubs := maps.Clone(subs)
+				newSubs[block.first.wordArg(0)] = resolve(vos, scope)
+				scope.push(localScopeOfBlocks(block.body))
+				expand(i+1, scope, newSubs)
+				scope.pop()
+			}
+		default:
+			expand(i+1, scope, subs)
+		}
+	}
+
+	doc := NewProofDocument()
+	doc.states = states
+	scope := NewScope(&doc)
+	expand(0, &scope, map[string]TokenStream{})
+	return pres, unresolved
+}
+
+// The states and conditions given directly in a body
+func localScopeOfBlocks(body []Block) *LocalScope {
+	scope := NewLocalScope()
+	for _, block := range body {
+		switch block.first.operator {
+		case "state":
+			scope.states[block.first.wordArg(0)] = block.first.verbatimArg(1)
+		case "cond":
+			scope.conditions = append(scope.conditions, block.first.verbatimArg(0))
+		}
+	}
+	return &scope
+}
+
+func (index *proofIndex) hover(pos lspPosition) (hover string) {
+	path, ok := index.haves[pos.Line]
+	if !ok {
+		return ""
+	}
+	defer func() {
+		if r := recover(); r != nil {
+			hover = ""
+		}
+	}()
+
+	post := streamToString(trimWhitespace(path[len(path)-1].first.verbatimArg(0)))
+	hover = ""
+	pres, unresolved := havePreConditions(path, index.doc.states)
+	for _, pre := range pres {
+		hover += "```systemverilog\n" + pre + "\n|->\n" + post + "\n```\n"
+	}
+	if path[0].first.operator == "def" {
+		hover += "Additionally conditioned by the preconditions at each `use`.\n"
+	}
+	for _, name := range unresolved {
+		hover += "`" + name + "` is a state of each lemma using this def.\n"
+	}
+	return hover
+}
+
+type lspServer struct {
+	docs   map[string]*proofIndex
+	writer io.Writer
+	stale  bool // Documents have changed since diagnostics were last published
+}
+
+// All lemmas, defs and global states in all open documents
+func (server *lspServer) globals() map[string]*proofSymbol {
+	globals := map[string]*proofSymbol{}
+	for _, uri := range slices.Sorted(maps.Keys(server.docs)) {
+		for _, sym := range server.docs[uri].symbols {
+			if sym.kind == "lemma" || sym.kind == "def" {
+				globals[sym.kind+" "+sym.name] = sym
+			}
+		}
+		for _, sym := range server.docs[uri].states {
+			globals["state "+sym.name] = sym
+		}
+	}
+	return globals
+}
+
+// The path of a file URI, or the URI itself otherwise
+func uriPath(uri string) string {
+	if u, err := url.Parse(uri); err == nil && u.Scheme == "file" {
+		return u.Path
+	}
+	return uri
+}
+
+func (server *lspServer) globalDocument() ProofDocument {
+	doc := NewProofDocument()
+	for _, uri := range slices.Sorted(maps.Keys(server.docs)) {
+		maps.Copy(doc.lemmas, server.docs[uri].doc.lemmas)
+		maps.Copy(doc.defs, server.docs[uri].doc.defs)
+		maps.Copy(doc.states, server.docs[uri].doc.states)
+	}
+	return doc
+}
+
+func (server *lspServer) diagnostics(index *proofIndex) []lspDiagnostic {
+	diagnostics := slices.Clone(index.diagnostics)
+	globals := server.globals()
+	for _, ref := range index.refs {
+		// Qualified names are in imported files, which are checked when generating below
+		if strings.Contains(ref.name, ".") {
+			continue
+		}
+		// Defs are dynamically scoped, so may use states of the lemmas using them
+		if ref.kind == "state" && ref.dynamic {
+			continue
+		}
+		if ref.target == nil && (ref.kind == "lemma" || ref.kind == "def" || ref.kind == "state") && globals[ref.kind+" "+ref.name] == nil {
+			diagnostics = append(diagnostics, lspDiagnostic{
+				Range:    lspRange{Start: lspPosition{ref.line, ref.col}, End: lspPosition{ref.line, ref.col + utf16Len(ref.name)}},
+				Severity: LSP_SEVERITY_ERROR,
+				Source:   "psgen",
+				Message:  "undefined " + ref.kind + " " + ref.name,
+			})
+		}
+	}
+	if len(diagnostics) > 0 {
+		return diagnostics
+	}
+
+	// Imports are read from disk, relative to the document
+	imports := map[string]*ProofModule{}
+	loader := NewModuleLoader([]string{}, map[string]string{}, SvMacros{})
+	for _, imp := range index.doc.imports {
+		module, err := loader.loadImport(imp, uriPath(index.uri))
+		if err != nil {
+			diagnostics = append(diagnostics, lspDiagnostic{
+				Range:    lineRange(index.lines, imp.source.line-1),
+				Severity: LSP_SEVERITY_ERROR,
+				Source:   "psgen",
+				Message:  err.Error(),
+			})
+			continue
+		}
+		imports[imp.alias] = module
+	}
+
+	// Only once nothing else is wrong, try generating each lemma to find any remaining errors and warnings
+	doc := server.globalDocument()
+	for _, sym := range index.symbols {
+		if sym.kind != "lemma" {
+			continue
+		}
+		scope := NewScope(&doc)
+		scope.env.imports = imports
+		scope.env.quiet = true
+		func() {
+			defer func() {
+				if r := recover(); r != nil {
+					diagnostics = append(diagnostics, lspDiagnostic{
+						Range:    lineRange(index.lines, sym.line),
+						Severity: LSP_SEVERITY_ERROR,
+						Source:   "psgen",
+						Message:  fmt.Sprint(r),
+					})
+				}
+			}()
+			genSequence(&scope, sym.name)
+		}()
+		for _, warning := range scope.env.warnings {
+			diagnostic := index.warningDiagnostic(warning, sym.line)
+			if !slices.Contains(diagnostics, diagnostic) {
+				diagnostics = append(diagnostics, diagnostic)
+			}
+		}
+	}
+	return diagnostics
+}
+
+// A warning at its line, or at the line of the lemma it was found generating if it is elsewhere or unknown
+func (index *proofIndex) warningDiagnostic(warning Warning, lemmaLine int) lspDiagnostic {
+	line := lemmaLine
+	msg := strings.TrimPrefix(warning.err.Error(), "warning: ")
+	if warning.source.line > 0 {
+		msg = strings.TrimPrefix(msg, warning.source.String()+": ")
+		if warning.source.file == uriPath(index.uri) {
+			line = warning.source.line - 1
+		}
+	}
+	return lspDiagnostic{
+		Range:    lineRange(index.lines, line),
+		Severity: LSP_SEVERITY_WARNING,
+		Source:   "psgen",
+		Message:  msg,
+	}
+}
+
+func (server *lspServer) send(msg any) {
+	data, err := json.Marshal(msg)
+	if err != nil {
+		panic(err)
+	}
+	fmt.Fprintf(server.writer, "Content-Length: %d\r\n\r\n%s", len(data), data)
+}
+
+func (server *lspServer) publishDiagnostics() {
+	server.stale = false
+	for _, uri := range slices.Sorted(maps.Keys(server.docs)) {
+		server.send(lspNotification{
+			JSONRPC: "2.0",
+			Method:  "textDocument/publishDiagnostics",
+			Params: map[string]any{
+				"uri":         uri,
+				"diagnostics": server.diagnostics(server.docs[uri]),
+			},
+		})
+	}
+}
+
+func (server *lspServer) definition(params lspTextDocumentPosition) any {
+	index, ok := server.docs[params.TextDocument.URI]
+	if !ok {
+		return nil
+	}
+	pos := params.Position
+
+	for _, sym := range index.symbols {
+		if sym.line == pos.Line && sym.col <= pos.Character && pos.Character <= sym.col+utf16Len(sym.name) {
+			return sym.location()
+		}
+	}
+	for _, ref := range index.refs {
+		if ref.line != pos.Line || pos.Character < ref.col || pos.Character > ref.col+utf16Len(ref.name) {
+			continue
+		}
+		if ref.target != nil {
+			return ref.target.location()
+		}
+		if sym := server.globals()[ref.kind+" "+ref.name]; sym != nil {
+			return sym.location()
+		}
+	}
+	return nil
+}
+
+func (server *lspServer) completion(params lspTextDocumentPosition) []lspCompletionItem {
+	items := []lspCompletionItem{}
+	globals := server.globals()
+	for _, key := range slices.Sorted(maps.Keys(globals)) {
+		sym := globals[key]
+		kind := LSP_COMPLETION_FUNCTION
+		switch sym.kind {
+		case "lemma":
+			kind = LSP_COMPLETION_MODULE
+		case "state":
+			kind = LSP_COMPLETION_VARIABLE
+		}
+		items = append(items, lspCompletionItem{Label: sym.name, Kind: kind, Detail: sym.kind})
+	}
+
+	index, ok := server.docs[params.TextDocument.URI]
+	if !ok {
+		return items
+	}
+
+	// States of the enclosing lemma or def, and nodes of any graph induction surrounding the cursor
+	var top *proofSymbol
+	for _, sym := range index.symbols {
+		if (sym.kind == "lemma" || sym.kind == "def") && sym.line <= params.Position.Line && params.Position.Line <= sym.end+1 {
+			top = sym
+		}
+	}
+	if top == nil {
+		return items
+	}
+	for _, sym := range index.symbols {
+		if sym.line <= top.line || sym.line > top.end {
+			continue
+		}
+		switch sym.kind {
+		case "state":
+			items = append(items, lspCompletionItem{Label: sym.name, Kind: LSP_COMPLETION_VARIABLE, Detail: "state"})
+		case "node", "inv":
+			items = append(items, lspCompletionItem{Label: sym.name, Kind: LSP_COMPLETION_ENUM, Detail: sym.kind})
+		}
+	}
+	return items
+}
+
+func (server *lspServer) documentSymbols(uri string) []lspDocumentSymbol {
+	index, ok := server.docs[uri]
+	if !ok {
+		return []lspDocumentSymbol{}
+	}
+
+	symbols := []lspDocumentSymbol{}
+	for _, sym := range index.symbols {
+		kind := LSP_SYMBOL_FUNCTION
+		if sym.kind == "def" {
+			kind = LSP_SYMBOL_METHOD
+		} else if sym.kind != "lemma" {
+			continue
+		}
+		symbols = append(symbols, lspDocumentSymbol{
+			Name:   sym.name,
+			Detail: sym.kind,
+			Kind:   kind,
+			Range: lspRange{
+				Start: lspPosition{sym.line, 0},
+				End:   lineRange(index.lines, sym.end).End,
+			},
+			SelectionRange: sym.location().Range,
+		})
+	}
+	return symbols
+}
+
+func readLspMessage(reader *bufio.Reader) ([]byte, error) {
+	length := -1
+	for {
+		header, err := reader.ReadString('\n')
+		if err != nil {
+			return nil, err
+		}
+		header = strings.TrimRight(header, "\r\n")
+		if header == "" {
+			break
+		}
+		if value, ok := strings.CutPrefix(header, "Content-Length: "); ok {
+			length, err = strconv.Atoi(value)
+			if err != nil {
+				return nil, err
+			}
+		}
+	}
+	if length < 0 {
+		return nil, fmt.Errorf("missing Content-Length header")
+	}
+	body := make([]byte, length)
+	_, err := io.ReadFull(reader, body)
+	return body, err
+}
+
+func (server *lspServer) handle(req lspRequest) {
+	var result any
+
+	switch req.Method {
+	case "initialize":
+		result = map[string]any{
+			"capabilities": map[string]any{
+				"textDocumentSync":       1,
+				"definitionProvider":     true,
+				"hoverProvider":          true,
+				"completionProvider":     map[string]any{},
+				"documentSymbolProvider": true,
+			},
+			"serverInfo": map[string]any{"name": "psgen"},
+		}
+	case "shutdown":
+		result = nil
+	case "exit":
+		os.Exit(0)
+	case "textDocument/didOpen":
+		var params struct {
+			TextDocument struct {
+				URI  string `json:"uri"`
+				Text string `json:"text"`
+			} `json:"textDocument"`
+		}
+		json.Unmarshal(req.Params, &params)
+		server.docs[params.TextDocument.URI] = newProofIndex(params.TextDocument.URI, params.TextDocument.Text)
+		server.stale = true
+	case "textDocument/didChange":
+		var params struct {
+			TextDocument struct {
+				URI string `json:"uri"`
+			} `json:"textDocument"`
+			ContentChanges []struct {
+				Text string `json:"text"`
+			} `json:"contentChanges"`
+		}
+		json.Unmarshal(req.Params, &params)
+		if len(params.ContentChanges) > 0 {
+			text := params.ContentChanges[len(params.ContentChanges)-1].Text
+			server.docs[params.TextDocument.URI] = newProofIndex(params.TextDocument.URI, text)
+			server.stale = true
+		}
+	case "textDocument/didClose":
+		var params struct {
+			TextDocument struct {
+				URI string `json:"uri"`
+			} `json:"textDocument"`
+		}
+		json.Unmarshal(req.Params, &params)
+		delete(server.docs, params.TextDocument.URI)
+		server.send(lspNotification{
+			JSONRPC: "2.0",
+			Method:  "textDocument/publishDiagnostics",
+			Params:  map[string]any{"uri": params.TextDocument.URI, "diagnostics": []lspDiagnostic{}},
+		})
+		server.stale = true
+	case "textDocument/definition":
+		var params lspTextDocumentPosition
+		json.Unmarshal(req.Params, &params)
+		result = server.definition(params)
+	case "textDocument/hover":
+		var params lspTextDocumentPosition
+		json.Unmarshal(req.Params, &params)
+		if index, ok := server.docs[params.TextDocument.URI]; ok {
+			if hover := index.hover(params.Position); hover != "" {
+				result = map[string]any{"contents": map[string]any{"kind": "markdown", "value": hover}}
+			}
+		}
+	case "textDocument/completion":
+		var params lspTextDocumentPosition
+		json.Unmarshal(req.Params, &params)
+		result = server.completion(params)
+	case "textDocument/documentSymbol":
+		var params lspTextDocumentPosition
+		json.Unmarshal(req.Params, &params)
+		result = server.documentSymbols(params.TextDocument.URI)
+	default:
+		if req.ID != nil {
+			resp := lspErrorResponse{JSONRPC: "2.0", ID: req.ID}
+			resp.Error.Code = -32601
+			resp.Error.Message = "method not found: " + req.Method
+			server.send(resp)
+		}
+		return
+	}
+
+	if req.ID != nil {
+		server.send(lspResponse{JSONRPC: "2.0", ID: req.ID, Result: result})
+	}
+}
+
+// How long documents must go unchanged before their diagnostics are published, as every lemma is generated again
+const lspDebounce = 300 * time.Millisecond
+
+// Handles messages until the input ends, publishing diagnostics once changes to the documents pause
+func (server *lspServer) serve(input io.Reader) {
+	messages := make(chan []byte)
+	go func() {
+		reader := bufio.NewReader(input)
+		for {
+			body, err := readLspMessage(reader)
+			if err != nil {
+				if err != io.EOF {
+					fmt.Fprintln(os.Stderr, err)
+				}
+				close(messages)
+				return
+			}
+			messages <- body
+		}
+	}()
+
+	var debounce <-chan time.Time
+	for {
+		select {
+		case body, ok := <-messages:
+			if !ok {
+				if server.stale {
+					server.publishDiagnostics()
+				}
+				return
+			}
+			var req lspRequest
+			if err := json.Unmarshal(body, &req); err != nil {
+				fmt.Fprintln(os.Stderr, err)
+				continue
+			}
+			server.handle(req)
+			if server.stale && strings.HasPrefix(req.Method, "textDocument/did") {
+				debounce = time.After(lspDebounce)
+			}
+		case <-debounce:
+			debounce = nil
+			server.publishDiagnostics()
+		}
+	}
+}
+
+func lspMain(args []string) {
+	server := lspServer{
+		docs:   map[string]*proofIndex{},
+		writer: os.Stdout,
+	}
+	server.serve(os.Stdin)
+}
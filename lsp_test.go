@@ -0,0 +1,103 @@
+package main
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"strings"
+	"testing"
+)
+
+// A message framed as the language server protocol sends it
+func lspFrame(msg string) string {
+	return fmt.Sprintf("Content-Length: %d\r\n\r\n%s", len(msg), msg)
+}
+
+func TestReadLspMessage(t *testing.T) {
+	reader := bufio.NewReader(strings.NewReader(
+		lspFrame(`{"a":1}`) + "Content-Type: application/vscode-jsonrpc\r\n" + lspFrame(`{"b":"é"}`)))
+	for _, want := range []string{`{"a":1}`, `{"b":"é"}`} {
+		body, err := readLspMessage(reader)
+		if err != nil || string(body) != want {
+			t.Errorf("read %q, %v, want %q", body, err, want)
+		}
+	}
+	if _, err := readLspMessage(reader); err == nil {
+		t.Errorf("read past the last message")
+	}
+
+	if _, err := readLspMessage(bufio.NewReader(strings.NewReader("Content-Type: x\r\n\r\n{}"))); err == nil {
+		t.Errorf("read a message without a Content-Length")
+	}
+
+	var out bytes.Buffer
+	server := lspServer{docs: map[string]*proofIndex{}, writer: &out}
+	server.send(map[string]int{"id": 1})
+	if out.String() != lspFrame(`{"id":1}`) {
+		t.Errorf("sent %q, want %q", out.String(), lspFrame(`{"id":1}`))
+	}
+}
+
+func TestLspDiagnostics(t *testing.T) {
+	open := func(uri string, text string) string {
+		params, _ := json.Marshal(map[string]any{"textDocument": map[string]any{"uri": uri, "text": text}})
+		return lspFrame(`{"jsonrpc":"2.0","method":"textDocument/didOpen","params":` + string(params) + `}`)
+	}
+	change := func(uri string, text string) string {
+		params, _ := json.Marshal(map[string]any{"textDocument": map[string]any{"uri": uri}, "contentChanges": []any{map[string]any{"text": text}}})
+		return lspFrame(`{"jsonrpc":"2.0","method":"textDocument/didChange","params":` + string(params) + `}`)
+	}
+	input := lspFrame(`{"jsonrpc":"2.0","id":1,"method":"initialize","params":{}}`) +
+		open("file:///proofs/v.proof", "lemma l\n    cond (a)\n    cond (~a)\n    P: have (b)\n") +
+		open("file:///proofs/u.proof", "lemma m\n    lemma l\n    lemma missing\n") +
+		change("file:///proofs/u.proof", "lemma m\n    lemma l\n    Q: have (b)\n")
+
+	var out bytes.Buffer
+	server := lspServer{docs: map[string]*proofIndex{}, writer: &out}
+	server.serve(strings.NewReader(input))
+
+	reader := bufio.NewReader(&out)
+	body, err := readLspMessage(reader)
+	if err != nil || !strings.Contains(string(body), `"id":1`) || !strings.Contains(string(body), `"capabilities"`) {
+		t.Fatalf("initialize returned %s, %v", body, err)
+	}
+
+	// Diagnostics are published once, after the last change
+	published := map[string][]lspDiagnostic{}
+	for {
+		body, err := readLspMessage(reader)
+		if err != nil {
+			break
+		}
+		var msg struct {
+			Method string `json:"method"`
+			Params struct {
+				URI         string          `json:"uri"`
+				Diagnostics []lspDiagnostic `json:"diagnostics"`
+			} `json:"params"`
+		}
+		if err := json.Unmarshal(body, &msg); err != nil || msg.Method != "textDocument/publishDiagnostics" {
+			t.Fatalf("unexpected message %s", body)
+		}
+		if _, ok := published[msg.Params.URI]; ok {
+			t.Errorf("diagnostics of %s published more than once", msg.Params.URI)
+		}
+		published[msg.Params.URI] = msg.Params.Diagnostics
+	}
+
+	want := lspDiagnostic{
+		Range:    lspRange{Start: lspPosition{3, 0}, End: lspPosition{3, 15}},
+		Severity: LSP_SEVERITY_WARNING,
+		Source:   "psgen",
+		Message:  "property P is vacuous, its preconditions a and ~a contradict each other",
+	}
+	if diagnostics := published["file:///proofs/v.proof"]; len(diagnostics) != 1 || diagnostics[0] != want {
+		t.Errorf("v.proof diagnostics %+v, want %+v", diagnostics, want)
+	}
+	// The warning is found in a lemma of another document, so is shown at the lemma using it
+	want.Range = lspRange{Start: lspPosition{0, 0}, End: lspPosition{0, 7}}
+	if diagnostics := published["file:///proofs/u.proof"]; len(diagnostics) != 1 || diagnostics[0] != want {
+		t.Errorf("u.proof diagnostics %+v, want %+v", diagnostics, want)
+	}
+}
@@ -0,0 +1,138 @@
+package main
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"maps"
+	"os"
+	"slices"
+	"strconv"
+	"strings"
+)
+
+// Calls f with every identifier in a stream, including those in brackets
+func streamNames(stream TokenStream, f func(string)) {
+	for _, tok := range stream {
+		switch tok := tok.(type) {
+		case *NameToken:
+			f(tok.content)
+		case *BracketedToken:
+			streamNames(tok.content, f)
+		}
+	}
+}
+
+func normalizedStream(stream TokenStream) string {
+	return strings.Join(strings.Fields(streamToString(stream)), " ")
+}
+
+// Everything which determines what proving a property means, ignoring any assumptions
+func (prop *Property) obligation(wires map[string]TokenStream) string {
+	str := prop.name + "\n"
+	streams := append(slices.Clone(prop.preConditions), prop.postCondition)
+	for _, pre := range prop.preConditions {
+		str += "pre " + normalizedStream(pre) + "\n"
+	}
+	str += "post " + normalizedStream(prop.postCondition) + "\n"
+	str += "step " + prop.step + " " + strconv.Itoa(prop.wait) + "\n"
+	if prop.cover {
+		str += "cover\n"
+	}
+
+	// The wires used, and the wires they use in turn
+	used := map[string]bool{}
+	for len(streams) > 0 {
+		stream := streams[len(streams)-1]
+		streams = streams[:len(streams)-1]
+		streamNames(stream, func(name string) {
+			if value, ok := wires[name]; ok && !used[name] {
+				used[name] = true
+				streams = append(streams, value)
+			}
+		})
+	}
+	for _, name := range slices.Sorted(maps.Keys(used)) {
+		str += "wire " + name + " " + normalizedStream(wires[name]) + "\n"
+	}
+	return str
+}
+
+// A hash of each property's proof obligation, together with the hashes of every property it assumes
+func (seq *FlatProofSequence) propertyHashes() map[string]string {
+	wires := map[string]TokenStream{}
+	for _, wire := range seq.wires {
+		wires[wire.name] = wire.value
+	}
+
+	hashes := map[string]string{}
+	for i, step := range seq.props {
+		for _, prop := range step {
+			assumed := []string{}
+			for _, other := range seq.propAssumes(i, prop) {
+				assumed = append(assumed, hashes[other.name])
+			}
+			slices.Sort(assumed)
+			sum := sha256.Sum256([]byte(prop.obligation(wires) + "assume\n" + strings.Join(assumed, "\n")))
+			hashes[prop.name] = hex.EncodeToString(sum[:])
+		}
+	}
+	return hashes
+}
+
+// One line of hash, step and name per property
+func (seq *FlatProofSequence) toManifest() string {
+	hashes := seq.propertyHashes()
+	manifest := ""
+	for i, step := range seq.props {
+		for _, prop := range step {
+			manifest += hashes[prop.name] + " " + strconv.Itoa(i) + " " + prop.name + "\n"
+		}
+	}
+	return manifest
+}
+
+// The hash of each property in a manifest, or nil if there is no manifest, as on a first run
+func readManifest(path string) (map[string]string, error) {
+	if path == "" {
+		return nil, nil
+	}
+	data, err := os.ReadFile(path)
+	if os.IsNotExist(err) {
+		fmt.Fprintln(os.Stderr, fmt.Errorf("warning: no manifest %s, treating every property as changed", path))
+		return nil, nil
+	} else if err != nil {
+		return nil, err
+	}
+	hashes := map[string]string{}
+	for _, line := range strings.Split(string(data), "\n") {
+		fields := strings.Fields(line)
+		if len(fields) == 3 {
+			hashes[fields[2]] = fields[0]
+		}
+	}
+	return hashes, nil
+}
+
+// The properties whose obligations differ from those in a previous manifest, all of them if there was none
+func (seq *FlatProofSequence) changedProperties(previous map[string]string) map[string]bool {
+	changed := map[string]bool{}
+	for name, hash := range seq.propertyHashes() {
+		if previous == nil || previous[name] != hash {
+			changed[name] = true
+		}
+	}
+	return changed
+}
+
+func (seq *FlatProofSequence) toChangedList(changed map[string]bool) string {
+	list := ""
+	for _, step := range seq.props {
+		for _, prop := range step {
+			if changed[prop.name] {
+				list += prop.name + "\n"
+			}
+		}
+	}
+	return list
+}
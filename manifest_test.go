@@ -0,0 +1,78 @@
+package main
+
+import (
+	"maps"
+	"slices"
+	"strings"
+	"testing"
+)
+
+const manifestProof = `
+lemma l
+    A: have (a)
+    B: have (b)
+    /
+    S: block
+        cond (s)
+        C: have (c)
+    /
+    D: have (d)
+    E: have (e) using B
+`
+
+func TestChangedProperties(t *testing.T) {
+	base := testSequence(t, manifestProof, "l")
+	previous := base.propertyHashes()
+	for _, test := range []struct {
+		name    string
+		from    string
+		to      string
+		changed []string
+	}{
+		{"nothing", "", "", []string{}},
+		{"whitespace", "have (d)", "have ( d )", []string{}},
+		{"postcondition", "have (d)", "have (d2)", []string{"D"}},
+		{"precondition", "cond (s)", "cond (t)", []string{"D", "S_C"}},
+		// Changes to a property change those assuming it in later steps, but not the rest of its own step
+		{"assumed property", "have (a)", "have (a2)", []string{"A", "D", "S_C"}},
+		{"property assumed by using", "have (b)", "have (b2)", []string{"B", "D", "E", "S_C"}},
+		{"step", "    B: have (b)\n", "    /\n    B: have (b)\n", []string{"B", "D", "E", "S_C"}},
+	} {
+		seq := testSequence(t, strings.Replace(manifestProof, test.from, test.to, 1), "l")
+		if changed := slices.Sorted(maps.Keys(seq.changedProperties(previous))); !slices.Equal(changed, test.changed) {
+			t.Errorf("%s: changed %v, want %v", test.name, changed, test.changed)
+		}
+	}
+
+	// The kind of implication and the wait before the postcondition are part of the obligation
+	for _, change := range []func(prop *Property){
+		func(prop *Property) { prop.step = "|=>" },
+		func(prop *Property) { prop.wait = 1 },
+	} {
+		seq := testSequence(t, manifestProof, "l")
+		change(seq.props[0][1])
+		if changed := slices.Sorted(maps.Keys(seq.changedProperties(previous))); !slices.Equal(changed, []string{"B", "D", "E", "S_C"}) {
+			t.Errorf("changing B changed %v, want [B D E S_C]", changed)
+		}
+	}
+
+	if changed := base.changedProperties(nil); len(changed) != 5 {
+		t.Errorf("without a manifest changed %v, want every property", changed)
+	}
+}
+
+func TestChangedTasks(t *testing.T) {
+	base := testSequence(t, manifestProof, "l")
+	previous := base.propertyHashes()
+	seq := testSequence(t, strings.Replace(manifestProof, "cond (s)", "cond (t)", 1), "l")
+	tasks := seq.toTasksOf(seq.changedProperties(previous))
+
+	// The unchanged A and B are not proved, but are still assumed
+	want := "task -create Step1 -copy_assumes -copy {*.S_C *.A *.B}\n" +
+		"assume -from_assert {Step1::*.A Step1::*.B}\n" +
+		"task -create Step2 -copy_assumes -copy {*.D *.S_C *.A *.B}\n" +
+		"assume -from_assert {Step2::*.S_C Step2::*.A Step2::*.B}\n"
+	if tasks != want {
+		t.Errorf("tasks:\n%s\nwant:\n%s", tasks, want)
+	}
+}
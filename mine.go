@@ -0,0 +1,339 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+	"math/bits"
+	"slices"
+	"strings"
+)
+
+var mineTemplates = []string{"eq", "implies", "onehot", "range"}
+
+// A candidate invariant, which held in every sample
+type MinedInvariant struct {
+	name string
+	expr string
+}
+
+// The values of candidate signals in each sampled cycle
+type Samples struct {
+	signals []string
+	values  [][]svValue // By cycle, then by signal
+}
+
+// Samples the candidate signals of a trace in every cycle where when holds and properties are not disabled, leaving
+// out cycles where any candidate reads an x or z bit. Values are only mined up to 64 bits wide.
+func (samples *Samples) add(ev *Evaluator, when *evalExpr) {
+	exprs := []*evalExpr{}
+	for _, signal := range samples.signals {
+		exprs = append(exprs, parseEvalExpr(signal))
+	}
+	for t := range ev.cycles() {
+		if ev.disabled(t, t) || (when != nil && !ev.eval(when, t).truthy()) {
+			continue
+		}
+		values := []svValue{}
+		ev.readXZ = false
+		for i, expr := range exprs {
+			value := ev.eval(expr, t)
+			if value.width > 64 {
+				panic(fmt.Errorf("candidate %s is %d bits wide, wider than the 64 bits mined", samples.signals[i], value.width))
+			}
+			values = append(values, value)
+		}
+		if !ev.readXZ {
+			samples.values = append(samples.values, values)
+		}
+	}
+}
+
+func (samples *Samples) all(f func(values []svValue) bool) bool {
+	return !slices.ContainsFunc(samples.values, func(values []svValue) bool { return !f(values) })
+}
+
+// A name for an invariant from the signals it is about
+func minedName(kind string, signals ...string) string {
+	name := kind
+	for _, signal := range signals {
+		parts := strings.FieldsFunc(signal, func(r rune) bool { return r >= 128 || !isIdentifierChar(byte(r)) })
+		name += "_" + strings.Join(parts, "_")
+	}
+	return strings.ToLower(name)
+}
+
+// Invariants of the given templates which held in every sample. Signals which are constant are reported as such,
+// rather than in the other templates, and implications are only reported where their antecedent held at least once.
+func (samples *Samples) mine(templates []string) []MinedInvariant {
+	invs := []MinedInvariant{}
+	if len(samples.values) == 0 {
+		return invs
+	}
+	n := len(samples.signals)
+	first := samples.values[0]
+	constant := make([]bool, n)
+	width := make([]int, n)
+	for i := range n {
+		constant[i] = samples.all(func(values []svValue) bool { return values[i] == first[i] })
+		width[i] = first[i].width
+	}
+	bit := func(i int) bool {
+		return width[i] == 1 && !constant[i]
+	}
+	group := []int{}
+	for i := range n {
+		if bit(i) {
+			group = append(group, i)
+		}
+	}
+	count := func(values []svValue) int {
+		ones := 0
+		for _, i := range group {
+			ones += int(values[i].bits)
+		}
+		return ones
+	}
+	// Pairwise exclusions are left out when onehot reports the whole group
+	exclusive := len(group) > 2 && slices.Contains(templates, "onehot") && samples.all(func(values []svValue) bool { return count(values) <= 1 })
+
+	if slices.Contains(templates, "eq") {
+		for i, signal := range samples.signals {
+			if constant[i] {
+				invs = append(invs, MinedInvariant{minedName("const", signal), fmt.Sprintf("%s == %s", signal, first[i])})
+			}
+		}
+		for i := range n {
+			for j := i + 1; j < n; j++ {
+				if constant[i] || constant[j] || width[i] != width[j] {
+					continue
+				}
+				if samples.all(func(values []svValue) bool { return values[i] == values[j] }) {
+					invs = append(invs, MinedInvariant{minedName("eq", samples.signals[i], samples.signals[j]), samples.signals[i] + " == " + samples.signals[j]})
+				} else if width[i] == 1 && samples.all(func(values []svValue) bool { return values[i] != values[j] }) {
+					invs = append(invs, MinedInvariant{minedName("neq", samples.signals[i], samples.signals[j]), samples.signals[i] + " != " + samples.signals[j]})
+				}
+			}
+		}
+	}
+
+	if slices.Contains(templates, "implies") {
+		for i := range n {
+			for j := range n {
+				if i == j || !bit(i) || !bit(j) {
+					continue
+				}
+				a, b := samples.signals[i], samples.signals[j]
+				// Either both always agree or always differ, which eq reports, or neither does
+				if samples.all(func(values []svValue) bool { return values[i] == values[j] }) || samples.all(func(values []svValue) bool { return values[i] != values[j] }) {
+					continue
+				}
+				if samples.all(func(values []svValue) bool { return !values[i].truthy() || values[j].truthy() }) {
+					invs = append(invs, MinedInvariant{minedName("imp", a, b), fmt.Sprintf("~%s || %s", a, b)})
+				}
+				if i < j && !exclusive && samples.all(func(values []svValue) bool { return !values[i].truthy() || !values[j].truthy() }) {
+					invs = append(invs, MinedInvariant{minedName("excl", a, b), fmt.Sprintf("~%s || ~%s", a, b)})
+				}
+			}
+		}
+	}
+
+	if slices.Contains(templates, "onehot") {
+		names := []string{}
+		for _, i := range group {
+			names = append(names, samples.signals[i])
+		}
+		if exclusive {
+			if samples.all(func(values []svValue) bool { return count(values) == 1 }) {
+				invs = append(invs, MinedInvariant{minedName("onehot", names...), "$onehot({" + strings.Join(names, ", ") + "})"})
+			} else {
+				invs = append(invs, MinedInvariant{minedName("onehot0", names...), "$onehot0({" + strings.Join(names, ", ") + "})"})
+			}
+		}
+		for i, signal := range samples.signals {
+			if width[i] < 2 || constant[i] {
+				continue
+			}
+			ones := func(values []svValue) int { return bits.OnesCount64(values[i].bits) }
+			if samples.all(func(values []svValue) bool { return ones(values) == 1 }) {
+				invs = append(invs, MinedInvariant{minedName("onehot", signal), "$onehot(" + signal + ")"})
+			} else if samples.all(func(values []svValue) bool { return ones(values) <= 1 }) {
+				invs = append(invs, MinedInvariant{minedName("onehot0", signal), "$onehot0(" + signal + ")"})
+			}
+		}
+	}
+
+	if slices.Contains(templates, "range") {
+		for i, signal := range samples.signals {
+			if width[i] < 2 || constant[i] {
+				continue
+			}
+			lo, hi := first[i].bits, first[i].bits
+			for _, values := range samples.values {
+				lo, hi = min(lo, values[i].bits), max(hi, values[i].bits)
+			}
+			switch {
+			case lo == 0 && hi == widthMask(width[i]):
+			case lo == 0:
+				invs = append(invs, MinedInvariant{minedName("range", signal), fmt.Sprintf("%s <= %s", signal, sized(hi, width[i]))})
+			case hi == widthMask(width[i]):
+				invs = append(invs, MinedInvariant{minedName("range", signal), fmt.Sprintf("%s >= %s", signal, sized(lo, width[i]))})
+			default:
+				invs = append(invs, MinedInvariant{minedName("range", signal), fmt.Sprintf("%s >= %s && %s <= %s", signal, sized(lo, width[i]), signal, sized(hi, width[i]))})
+			}
+		}
+	}
+	return invs
+}
+
+func (inv *MinedInvariant) toProof(as string) string {
+	if as == "inv" {
+		return "inv " + inv.name + " (" + inv.expr + ")"
+	}
+	return "have (" + inv.expr + ")"
+}
+
+func mineMain(args []string) {
+	flags := flag.NewFlagSet("mine", flag.ExitOnError)
+	paths := []string{}
+	flags.Func("path", "paths to source files, whose states and graph nodes -state and -node refer to", func(s string) error {
+		paths = append(paths, s)
+		return nil
+	})
+	includes := []string{}
+	includeFlag(flags, &includes)
+	defines := map[string]string{}
+	definesFlag(flags, defines)
+	svIncludes := []string{}
+	svIncludeFlag(flags, &svIncludes)
+	rootLemma := flags.String("root", "", "name of root lemma, whose states and graph nodes may be used, or empty for only global states")
+	signals := []string{}
+	flags.Func("signals", "comma separated candidate signals, or expressions, to mine invariants over", func(s string) error {
+		for _, signal := range strings.Split(s, ",") {
+			if signal = strings.TrimSpace(signal); signal != "" {
+				signals = append(signals, signal)
+			}
+		}
+		return nil
+	})
+	templates := slices.Clone(mineTemplates)
+	flags.Func("templates", "comma separated templates of invariants to look for, from eq, implies, onehot and range (default all of them)", func(s string) error {
+		templates = strings.Split(s, ",")
+		for _, template := range templates {
+			if !slices.Contains(mineTemplates, template) {
+				return fmt.Errorf("unknown template %s", template)
+			}
+		}
+		return nil
+	})
+	when := flags.String("when", "", "only sample cycles where this condition holds")
+	state := flags.String("state", "", "only sample cycles where this state holds")
+	node := flags.String("node", "", "only sample cycles where the condition of this graph node holds, as named by its wire")
+	as := flags.String("as", "have", "write invariants as have or inv lines")
+	clock := flags.String("clock", "clk_i", "signal of the traces whose rising edges are the cycles sampled")
+	disable := flags.String("disable", "~rst_ni", "condition in which cycles are not sampled, such as reset, or empty for none")
+	flags.Parse(args)
+
+	if len(signals) == 0 {
+		fmt.Println(fmt.Errorf("error: must specify candidate signals"))
+		return
+	}
+	if flags.NArg() == 0 {
+		fmt.Println(fmt.Errorf("error: must specify at least one VCD trace"))
+		return
+	}
+	if *as != "have" && *as != "inv" {
+		fmt.Println(fmt.Errorf("error: -as must be have or inv"))
+		return
+	}
+	if (*state != "" || *node != "") && len(paths) == 0 {
+		fmt.Println(fmt.Errorf("error: -state and -node need the proof given with -path"))
+		return
+	}
+
+	wires := []Wiring{}
+	conds := []TokenStream{}
+	if *when != "" {
+		_, stream := tokenize(*when)
+		conds = append(conds, stream)
+	}
+	if len(paths) > 0 {
+		scope, err := loadScope(paths, includes, defines, svIncludes)
+		if err != nil {
+			fmt.Println(err)
+			return
+		}
+		if *rootLemma != "" {
+			lemma, root, err := scope.lemmaScope(*rootLemma)
+			if err != nil {
+				fmt.Println(fmt.Errorf("error: %v", err))
+				return
+			}
+			wires = genSequence(&scope, *rootLemma).wires
+			root.push(&lemma.seq.scope)
+			scope = root
+		}
+		if *state != "" {
+			err := func() (err error) {
+				defer func() {
+					if r := recover(); r != nil {
+						err = fmt.Errorf("error: %v", r)
+					}
+				}()
+				conds = append(conds, scope.getState(*state))
+				return nil
+			}()
+			if err != nil {
+				fmt.Println(err)
+				return
+			}
+		}
+		if *node != "" {
+			if *rootLemma == "" {
+				fmt.Println(fmt.Errorf("error: -node needs the lemma of the graph given with -root"))
+				return
+			}
+			if !slices.ContainsFunc(wires, func(wire Wiring) bool { return wire.name == *node && wire.node != nil }) {
+				fmt.Println(fmt.Errorf("error: no graph node %s in lemma %s", *node, *rootLemma))
+				return
+			}
+			conds = append(conds, TokenStream{&NameToken{content: *node}})
+		}
+	}
+
+	samples := Samples{signals: signals, values: [][]svValue{}}
+	for _, path := range flags.Args() {
+		trace, err := loadTrace(path, *clock)
+		if err != nil {
+			fmt.Println(fmt.Errorf("error: %v", err))
+			return
+		}
+		ev := NewEvaluator(trace, wires)
+		ev.setDisable(*disable)
+		err = func() (err error) {
+			defer func() {
+				if r := recover(); r != nil {
+					err = fmt.Errorf("error: %s: %v", path, r)
+				}
+			}()
+			var cond *evalExpr
+			if len(conds) > 0 {
+				cond = parseEvalExpr(streamToString(conjoin(conds)))
+			}
+			samples.add(ev, cond)
+			return nil
+		}()
+		if err != nil {
+			fmt.Println(err)
+			return
+		}
+	}
+
+	if len(samples.values) == 0 {
+		fmt.Println(fmt.Errorf("error: no cycle of the traces was sampled"))
+		return
+	}
+	fmt.Printf("# Held in all %d cycles sampled from %d traces\n", len(samples.values), flags.NArg())
+	for _, inv := range samples.mine(templates) {
+		fmt.Println(inv.toProof(*as))
+	}
+}
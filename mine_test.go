@@ -0,0 +1,127 @@
+package main
+
+import (
+	"fmt"
+	"slices"
+	"strings"
+	"testing"
+)
+
+// Samples of the given signals, each cycle given as the values of the signals in order
+func testSamples(signals []string, widths []int, cycles ...[]uint64) Samples {
+	samples := Samples{signals: signals, values: [][]svValue{}}
+	for _, cycle := range cycles {
+		values := []svValue{}
+		for i, bits := range cycle {
+			values = append(values, sized(bits, widths[i]))
+		}
+		samples.values = append(samples.values, values)
+	}
+	return samples
+}
+
+func TestMineTemplates(t *testing.T) {
+	for _, test := range []struct {
+		name      string
+		templates []string
+		samples   Samples
+		want      []string
+	}{
+		{"constant and equal", []string{"eq"}, testSamples([]string{"a", "b", "c", "k"}, []int{1, 1, 1, 2},
+			[]uint64{0, 0, 1, 2}, []uint64{1, 1, 0, 2}, []uint64{1, 1, 0, 2}),
+			[]string{"const_k: k == 2'h2", "eq_a_b: a == b", "neq_a_c: a != c", "neq_b_c: b != c"}},
+		{"implies", []string{"implies"}, testSamples([]string{"req", "gnt", "err"}, []int{1, 1, 1},
+			[]uint64{0, 0, 0}, []uint64{1, 0, 0}, []uint64{1, 1, 0}, []uint64{0, 0, 1}),
+			[]string{"excl_req_err: ~req || ~err", "imp_gnt_req: ~gnt || req", "excl_gnt_err: ~gnt || ~err"}},
+		// With onehot, the exclusions are reported together
+		{"onehot bits", []string{"implies", "onehot"}, testSamples([]string{"x", "y", "z"}, []int{1, 1, 1},
+			[]uint64{1, 0, 0}, []uint64{0, 1, 0}, []uint64{0, 0, 1}),
+			[]string{"onehot_x_y_z: $onehot({x, y, z})"}},
+		{"onehot0 bits", []string{"onehot"}, testSamples([]string{"x", "y", "z"}, []int{1, 1, 1},
+			[]uint64{1, 0, 0}, []uint64{0, 0, 0}, []uint64{0, 1, 0}, []uint64{0, 0, 1}),
+			[]string{"onehot0_x_y_z: $onehot0({x, y, z})"}},
+		{"onehot vectors", []string{"onehot"}, testSamples([]string{"state", "grant", "count"}, []int{4, 4, 4},
+			[]uint64{1, 0, 3}, []uint64{4, 2, 1}, []uint64{8, 0, 0}),
+			[]string{"onehot_state: $onehot(state)", "onehot0_grant: $onehot0(grant)"}},
+		{"range", []string{"range"}, testSamples([]string{"lo", "hi", "mid", "any"}, []int{4, 4, 4, 2},
+			[]uint64{0, 15, 3, 0}, []uint64{9, 4, 7, 3}, []uint64{2, 6, 5, 1}),
+			[]string{"range_lo: lo <= 4'h9", "range_hi: hi >= 4'h4", "range_mid: mid >= 4'h3 && mid <= 4'h7"}},
+		{"no samples", mineTemplates, testSamples([]string{"a"}, []int{1}), []string{}},
+	} {
+		got := []string{}
+		for _, inv := range test.samples.mine(test.templates) {
+			got = append(got, inv.name+": "+inv.expr)
+		}
+		if !slices.Equal(got, test.want) {
+			t.Errorf("%s: mined %q, want %q", test.name, got, test.want)
+		}
+	}
+}
+
+const mineVcd = `
+$scope module tb $end
+$var wire 1 ! clk_i $end
+$var wire 1 " a $end
+$var wire 1 # b $end
+$var wire 128 $ w $end
+$upscope $end
+$enddefinitions $end
+#0
+0!
+1"
+1#
+b0 $
+#5
+1!
+#10
+0!
+x#
+#15
+1!
+#20
+0!
+0"
+0#
+#25
+1!
+`
+
+func TestMineUnknown(t *testing.T) {
+	trace, err := parseVcd("mine.vcd", mineVcd, "clk_i")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if slot, _ := trace.slot("b"); !slices.Equal([]bool{trace.unknown[0][slot], trace.unknown[1][slot], trace.unknown[2][slot]}, []bool{false, true, false}) {
+		t.Errorf("b is unknown in %v, want only the second cycle", trace.unknown)
+	}
+
+	// The cycle where b is x is left out, whether it is read directly or through a wire, and the cycle after it when
+	// read by $past
+	_, wire := tokenize("a && b")
+	for _, test := range []struct {
+		signals []string
+		a       []uint64 // The value of a in the cycles sampled
+	}{
+		{[]string{"a", "b"}, []uint64{1, 0}},
+		{[]string{"a", "ab"}, []uint64{1, 0}},
+		{[]string{"a", "$past(b)"}, []uint64{1, 1}},
+	} {
+		samples := Samples{signals: test.signals, values: [][]svValue{}}
+		samples.add(NewEvaluator(trace, []Wiring{{name: "ab", value: wire}}), nil)
+		a := []uint64{}
+		for _, values := range samples.values {
+			a = append(a, values[0].bits)
+		}
+		if !slices.Equal(a, test.a) {
+			t.Errorf("sampled %v with a as %v, want %v", test.signals, a, test.a)
+		}
+	}
+
+	defer func() {
+		if r := recover(); r == nil || !strings.Contains(fmt.Sprint(r), "candidate w is 128 bits wide") {
+			t.Errorf("sampling a 128 bit candidate gave %v, want it rejected", r)
+		}
+	}()
+	samples := Samples{signals: []string{"w"}, values: [][]svValue{}}
+	samples.add(NewEvaluator(trace, []Wiring{}), nil)
+}
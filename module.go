@@ -0,0 +1,183 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+	"os"
+	"path/filepath"
+	"slices"
+	"strings"
+)
+
+// An `import "path" as alias` at the top of a document
+type Import struct {
+	path   string
+	alias  string
+	source Source
+}
+
+// A parsed source file, with the modules it imports by alias
+type ProofModule struct {
+	path    string
+	doc     ProofDocument
+	imports map[string]*ProofModule
+	env     *Environment
+}
+
+// Loads source files and everything they import, parsing each file only once
+type ModuleLoader struct {
+	includes []string
+	defines  map[string]string
+	macros   SvMacros // Expanded within every file loaded, if there are any
+	modules  map[string]*ProofModule
+	loading  []string // The files currently being loaded, innermost last, to report import cycles
+}
+
+func NewModuleLoader(includes []string, defines map[string]string, macros SvMacros) *ModuleLoader {
+	return &ModuleLoader{
+		includes: includes,
+		defines:  defines,
+		macros:   macros,
+		modules:  map[string]*ProofModule{},
+		loading:  []string{},
+	}
+}
+
+func includeFlag(flags *flag.FlagSet, includes *[]string) {
+	flags.Func("I", "directories to search for imported files, after the directory of the importing file", func(s string) error {
+		*includes = append(*includes, s)
+		return nil
+	})
+}
+
+// Finds an imported file relative to the importing file, then in each include directory
+func (loader *ModuleLoader) resolve(path string, from string) (string, bool) {
+	if filepath.IsAbs(path) {
+		_, err := os.Stat(path)
+		return path, err == nil
+	}
+	dirs := append([]string{filepath.Dir(from)}, loader.includes...)
+	for _, dir := range dirs {
+		candidate := filepath.Join(dir, path)
+		if _, err := os.Stat(candidate); err == nil {
+			return candidate, true
+		}
+	}
+	return "", false
+}
+
+func (loader *ModuleLoader) load(path string) (*ProofModule, error) {
+	key, err := filepath.Abs(path)
+	if err != nil {
+		return nil, err
+	}
+	if module, ok := loader.modules[key]; ok {
+		return module, nil
+	}
+	if i := slices.Index(loader.loading, key); i != -1 {
+		cycle := []string{}
+		for _, file := range append(loader.loading[i:], key) {
+			rel, err := filepath.Rel(filepath.Dir(loader.loading[0]), file)
+			if err != nil {
+				rel = file
+			}
+			cycle = append(cycle, rel)
+		}
+		return nil, fmt.Errorf("import cycle: %s", strings.Join(cycle, " -> "))
+	}
+
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	_, blocks := parseBlocks(preprocess(strings.Split(string(data), "\n"), loader.defines), -1)
+	setBlocksFile(blocks, path)
+	if len(loader.macros) > 0 {
+		expandBlockMacros(blocks, loader.macros)
+	}
+	module := &ProofModule{
+		path:    path,
+		doc:     blocksToProofDocument(blocks),
+		imports: map[string]*ProofModule{},
+	}
+	module.env = NewEnvironment(&module.doc)
+	module.env.imports = module.imports
+
+	loader.loading = append(loader.loading, key)
+	defer func() { loader.loading = loader.loading[:len(loader.loading)-1] }()
+	for _, imp := range module.doc.imports {
+		imported, err := loader.loadImport(imp, path)
+		if err != nil {
+			return nil, err
+		}
+		if prev, ok := module.imports[imp.alias]; ok && prev != imported {
+			return nil, fmt.Errorf("%s: %s already imports %s", imp.source, imp.alias, prev.path)
+		}
+		module.imports[imp.alias] = imported
+	}
+
+	loader.modules[key] = module
+	return module, nil
+}
+
+// Loads the file imported by a file
+func (loader *ModuleLoader) loadImport(imp Import, from string) (*ProofModule, error) {
+	resolved, ok := loader.resolve(imp.path, from)
+	if !ok {
+		return nil, fmt.Errorf("%s: cannot find import %s", imp.source, imp.path)
+	}
+	return loader.load(resolved)
+}
+
+// Every file loaded, sorted
+func (loader *ModuleLoader) files() []string {
+	files := []string{}
+	for _, module := range loader.modules {
+		files = append(files, module.path)
+	}
+	slices.Sort(files)
+	return files
+}
+
+// Resolves a possibly qualified name to the module it is in, nil for the current one, and its name within it
+func (scope *Scope) resolve(name string) (*ProofModule, string, error) {
+	var module *ProofModule
+	imports := scope.env.imports
+	local := name
+	for {
+		alias, rest, ok := strings.Cut(local, ".")
+		if !ok {
+			return module, local, nil
+		}
+		next, ok := imports[alias]
+		if !ok {
+			return nil, "", fmt.Errorf("no import named %s for %s", alias, name)
+		}
+		module, imports, local = next, next.imports, rest
+	}
+}
+
+// The same conditions and states, but in the environment of the given module
+func (scope *Scope) enter(module *ProofModule) Scope {
+	return Scope{
+		env:   module.env,
+		stack: scope.stack,
+	}
+}
+
+// The lemma a possibly qualified name refers to, and a fresh scope of the module it is in to generate it in
+func (scope *Scope) lemmaScope(name string) (Lemma, Scope, error) {
+	module, local, err := scope.resolve(name)
+	if err != nil {
+		return Lemma{}, Scope{}, err
+	}
+	fresh := scope.fresh()
+	if module != nil {
+		fresh = fresh.enter(module)
+	}
+	lemma, ok := fresh.env.lemmas[local]
+	if !ok {
+		return Lemma{}, Scope{}, fmt.Errorf("lemma %s does not exist", name)
+	}
+	return lemma, fresh, nil
+}
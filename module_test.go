@@ -0,0 +1,116 @@
+package main
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+// Writes files under a temporary directory by their relative paths, returning the directory
+func testFiles(t *testing.T, files map[string]string) string {
+	t.Helper()
+	dir := t.TempDir()
+	for name, text := range files {
+		path := filepath.Join(dir, name)
+		if err := os.MkdirAll(filepath.Dir(path), 0775); err != nil {
+			t.Fatal(err)
+		}
+		if err := os.WriteFile(path, []byte(text), 0664); err != nil {
+			t.Fatal(err)
+		}
+	}
+	return dir
+}
+
+func TestModuleImports(t *testing.T) {
+	dir := testFiles(t, map[string]string{
+		"top.proof":         "import \"sub/a.proof\" as a\nimport \"lib.proof\" as lib\nlemma top\n  lemma a.la\n  use lib.d\n",
+		"sub/a.proof":       "import \"lib.proof\" as lib\nlemma la\n  A: have (lib_a)\n  lemma lib.ll\n",
+		"sub/lib.proof":     "lemma ll\n  L: have (near)\n",
+		"include/lib.proof": "lemma ll\n  L: have (far)\ndef d\n  D: have (d)\n",
+	})
+
+	// sub/a.proof finds the lib.proof next to it first, while top.proof only finds the one on the include path
+	loader := NewModuleLoader([]string{filepath.Join(dir, "include")}, map[string]string{}, SvMacros{})
+	top, err := loader.load(filepath.Join(dir, "top.proof"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got := top.imports["a"].imports["lib"].path; got != filepath.Join(dir, "sub", "lib.proof") {
+		t.Errorf("a.proof imported %s, want the lib.proof beside it", got)
+	}
+	if got := top.imports["lib"].path; got != filepath.Join(dir, "include", "lib.proof") {
+		t.Errorf("top.proof imported %s, want the lib.proof on the include path", got)
+	}
+	if files := loader.files(); len(files) != 4 {
+		t.Errorf("loaded %v, want each file once", files)
+	}
+
+	scope, err := loadScope([]string{filepath.Join(dir, "top.proof")}, []string{filepath.Join(dir, "include")}, map[string]string{}, []string{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	seq := genSequence(&scope, "top")
+	got := []string{}
+	for _, prop := range seq.props[0] {
+		got = append(got, prop.name+": "+streamToString(prop.postCondition))
+	}
+	if want := "A: lib_a, L: near, D: d"; strings.Join(got, ", ") != want {
+		t.Errorf("generated %s, want %s", strings.Join(got, ", "), want)
+	}
+
+	if _, err := NewModuleLoader([]string{}, map[string]string{}, SvMacros{}).load(filepath.Join(dir, "top.proof")); err == nil || !strings.Contains(err.Error(), "cannot find import lib.proof") {
+		t.Errorf("loading without the include path gave %v", err)
+	}
+}
+
+func TestModuleCycle(t *testing.T) {
+	dir := testFiles(t, map[string]string{
+		"a.proof":     "import \"b.proof\" as b\nlemma a\n  A: have (a)\n",
+		"b.proof":     "import \"sub/c.proof\" as c\nlemma b\n  B: have (b)\n",
+		"sub/c.proof": "import \"../a.proof\" as a\nlemma c\n  C: have (c)\n",
+		"self.proof":  "import \"self.proof\" as self\n",
+	})
+	loader := NewModuleLoader([]string{}, map[string]string{}, SvMacros{})
+	if _, err := loader.load(filepath.Join(dir, "a.proof")); err == nil || err.Error() != "import cycle: a.proof -> b.proof -> sub/c.proof -> a.proof" {
+		t.Errorf("loading a cycle gave %v", err)
+	}
+	if _, err := loader.load(filepath.Join(dir, "self.proof")); err == nil || err.Error() != "import cycle: self.proof -> self.proof" {
+		t.Errorf("loading a file importing itself gave %v", err)
+	}
+}
+
+func TestResolveUnknownImport(t *testing.T) {
+	dir := testFiles(t, map[string]string{
+		"lib.proof": "lemma ll\n  L: have (l)\ndef d\n  D: have (d)\n",
+		"top.proof": "import \"lib.proof\" as lib\nlemma top\n  lemma lib.ll\n  lemma nope.x\nlemma usenope\n  use lib.nope.d\n",
+	})
+	scope, err := loadScope([]string{filepath.Join(dir, "top.proof")}, []string{}, map[string]string{}, []string{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, _, err := scope.lemmaScope("nope.top"); err == nil || err.Error() != "no import named nope for nope.top" {
+		t.Errorf("root lemma in an unknown import gave %v", err)
+	}
+	if _, _, err := scope.lemmaScope("lib.missing"); err == nil || err.Error() != "lemma lib.missing does not exist" {
+		t.Errorf("missing lemma in an import gave %v", err)
+	}
+
+	// Errors within a lemma are at the line of the command
+	for root, want := range map[string]string{
+		"top":     "line 4: no import named nope for nope.x",
+		"usenope": "line 6: no import named nope for lib.nope.d",
+	} {
+		func() {
+			defer func() {
+				r := recover()
+				if _, ok := r.(SourceError); !ok || fmt.Sprint(r) != want {
+					t.Errorf("generating %s gave %v, want %s", root, r, want)
+				}
+			}()
+			genSequence(&scope, root)
+		}()
+	}
+}
@@ -0,0 +1,137 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+	"strings"
+)
+
+func definesFlag(flags *flag.FlagSet, defines map[string]string) {
+	flags.Func("D", "NAME or NAME=value, defining NAME for ifdef and `NAME in SystemVerilog, over any define in the source", func(s string) error {
+		name, value, _ := strings.Cut(s, "=")
+		if !isIdentifier(name) {
+			return fmt.Errorf("not a name: %s", name)
+		}
+		defines[name] = value
+		return nil
+	})
+}
+
+func isIdentifierChar(chr byte) bool {
+	return chr == '_' || chr == '$' || (chr >= 'a' && chr <= 'z') || (chr >= 'A' && chr <= 'Z') || (chr >= '0' && chr <= '9')
+}
+
+func isIdentifier(name string) bool {
+	if name == "" || (name[0] >= '0' && name[0] <= '9') {
+		return false
+	}
+	for i := range len(name) {
+		if !isIdentifierChar(name[i]) {
+			return false
+		}
+	}
+	return true
+}
+
+// Replaces `NAME with the value of NAME wherever it is defined, leaving other macros to SystemVerilog
+func substituteDefines(line string, defines map[string]string) string {
+	if !strings.Contains(line, "`") {
+		return line
+	}
+	str := ""
+	for i := 0; i < len(line); i++ {
+		if line[i] != '`' {
+			str += line[i : i+1]
+			continue
+		}
+		end := i + 1
+		for end < len(line) && isIdentifierChar(line[end]) {
+			end += 1
+		}
+		if value, ok := defines[line[i+1:end]]; ok {
+			str += value
+			i = end - 1
+		} else {
+			str += "`"
+		}
+	}
+	return str
+}
+
+// An ifdef or ifndef whose endif has not been reached yet
+type conditional struct {
+	line      int
+	active    bool // Whether the lines in the current branch are kept
+	enclosing bool // Whether the lines around the conditional are kept
+	inElse    bool
+}
+
+// Applies ifdef NAME, ifndef NAME, else, endif and define NAME value lines, which are blanked along with the lines
+// they leave out so that line numbers are unchanged. Defines given are kept over those in the source, which only
+// apply to later lines of the same file.
+func preprocess(lines []string, defines map[string]string) []string {
+	local := map[string]string{}
+	for name, value := range defines {
+		local[name] = value
+	}
+	stack := []*conditional{}
+	active := func() bool {
+		return len(stack) == 0 || stack[len(stack)-1].active
+	}
+
+	result := make([]string, len(lines))
+	for l, line := range lines {
+		fields := strings.Fields(strings.SplitN(line, "# ", 2)[0])
+		directive := ""
+		if len(fields) > 0 {
+			directive = fields[0]
+		}
+
+		switch {
+		case (directive == "ifdef" || directive == "ifndef") && len(fields) == 2:
+			_, defined := local[fields[1]]
+			stack = append(stack, &conditional{
+				line:      l + 1,
+				active:    active() && defined == (directive == "ifdef"),
+				enclosing: active(),
+			})
+		case directive == "else" && len(fields) == 1:
+			if len(stack) == 0 || stack[len(stack)-1].inElse {
+				panic(SourceError{line: l + 1, err: fmt.Errorf("else without ifdef")})
+			}
+			top := stack[len(stack)-1]
+			top.active = top.enclosing && !top.active
+			top.inElse = true
+		case directive == "endif" && len(fields) == 1:
+			if len(stack) == 0 {
+				panic(SourceError{line: l + 1, err: fmt.Errorf("endif without ifdef")})
+			}
+			stack = stack[:len(stack)-1]
+		case directive == "define":
+			if active() {
+				cmd := parseCommandAt(strings.TrimSpace(strings.SplitN(line, "# ", 2)[0]), l+1, 0)
+				func() {
+					defer cmd.locate()
+					name, value := cmd.wordArg(0), ""
+					// A verbatim value keeps its brackets, so that it substitutes as a single expression
+					if len(cmd.inlineArgs) == 2 {
+						value = cmd.arg(1).toSource()
+					} else {
+						cmd.fixArgs(1)
+					}
+					if _, ok := defines[name]; !ok {
+						local[name] = value
+					}
+				}()
+			}
+		default:
+			if active() {
+				result[l] = substituteDefines(line, local)
+			}
+		}
+	}
+	if len(stack) != 0 {
+		panic(SourceError{line: stack[len(stack)-1].line, err: fmt.Errorf("ifdef without endif")})
+	}
+	return result
+}
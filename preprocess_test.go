@@ -0,0 +1,94 @@
+package main
+
+import (
+	"fmt"
+	"slices"
+	"strings"
+	"testing"
+)
+
+const preprocessSource = `lemma l
+ifdef A
+    PA: have (a)
+    ifndef B
+        PNotB: have (` + "`W" + `)
+    else
+        PB: have (b)
+    endif
+else
+    define W (x + y)
+    PNotA: have (` + "`W" + `)
+endif
+    P: have (` + "`W && `Other" + `) # ifdef A
+`
+
+func TestPreprocess(t *testing.T) {
+	lines := strings.Split(preprocessSource, "\n")
+	for _, test := range []struct {
+		defines map[string]string
+		kept    map[int]string // Lines kept by their index, every other line being blanked
+	}{
+		{map[string]string{}, map[int]string{
+			0: "lemma l", 10: "    PNotA: have ((x + y))", 12: "    P: have ((x + y) && `Other) # ifdef A"}},
+		{map[string]string{"A": ""}, map[int]string{
+			0: "lemma l", 2: "    PA: have (a)", 4: "        PNotB: have (`W)", 12: "    P: have (`W && `Other) # ifdef A"}},
+		{map[string]string{"A": "", "B": ""}, map[int]string{
+			0: "lemma l", 2: "    PA: have (a)", 6: "        PB: have (b)", 12: "    P: have (`W && `Other) # ifdef A"}},
+		// Defines given override those in the source
+		{map[string]string{"W": "w"}, map[int]string{
+			0: "lemma l", 10: "    PNotA: have (w)", 12: "    P: have (w && `Other) # ifdef A"}},
+		// A nested conditional is left out with the branch it is in, whatever its own condition
+		{map[string]string{"B": ""}, map[int]string{
+			0: "lemma l", 10: "    PNotA: have ((x + y))", 12: "    P: have ((x + y) && `Other) # ifdef A"}},
+	} {
+		result := preprocess(lines, test.defines)
+		if len(result) != len(lines) {
+			t.Errorf("with %v preprocessed %d lines into %d", test.defines, len(lines), len(result))
+			continue
+		}
+		for l, line := range result {
+			if line != test.kept[l] {
+				t.Errorf("with %v line %d is %q, want %q", test.defines, l+1, line, test.kept[l])
+			}
+		}
+	}
+}
+
+func TestPreprocessErrors(t *testing.T) {
+	for _, test := range []struct {
+		source string
+		err    string
+	}{
+		{"ifdef A\nelse\nelse\nendif", "line 3: else without ifdef"},
+		{"lemma l\nendif", "line 2: endif without ifdef"},
+		{"ifdef A\nifndef B\nendif", "line 1: ifdef without endif"},
+		{"ifdef A\nendif\nifndef B\n", "line 3: ifdef without endif"},
+	} {
+		func() {
+			defer func() {
+				if r := recover(); fmt.Sprint(r) != test.err {
+					t.Errorf("preprocessing %q gave %v, want %s", test.source, r, test.err)
+				}
+			}()
+			preprocess(strings.Split(test.source, "\n"), map[string]string{})
+		}()
+	}
+}
+
+func TestSubstituteDefines(t *testing.T) {
+	defines := map[string]string{"A": "1", "AB": "(a | b)"}
+	for line, want := range map[string]string{
+		"x":               "x",
+		"`A + `AB":        "1 + (a | b)",
+		"`ABC && `A":      "`ABC && 1",
+		"`A`A":            "11",
+		"$past(`AB) == `": "$past((a | b)) == `",
+	} {
+		if got := substituteDefines(line, defines); got != want {
+			t.Errorf("substituted %q into %q, want %q", line, got, want)
+		}
+	}
+	if !slices.Equal(preprocess([]string{"define A 2", "`A"}, defines), []string{"", "1"}) {
+		t.Errorf("define in the source overrode one given")
+	}
+}
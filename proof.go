@@ -2,31 +2,71 @@ package main
 
 import (
 	"fmt"
-	"os"
+	"maps"
 	"slices"
 	"strconv"
 	"strings"
 )
 
+// Everything declared at the top level of a document, visible from anywhere within it
+type Environment struct {
+	lemmas   map[string]Lemma
+	defs     map[string]SequencedProofSteps
+	states   map[string]GlobalState
+	imports  map[string]*ProofModule
+	shadowed map[string]bool // Global states already warned about being shadowed
+	warned   map[string]bool // Warnings already given, for those found again on every use of a lemma
+	warnings []Warning       // Every warning given, in order
+	quiet    bool            // Collect warnings without printing them, for the language server to show instead
+	design   *SvDesign       // The design under test, if given, whose declarations give the widths of signals
+}
+
+// A warning found while generating properties, at the source it was found at if known
+type Warning struct {
+	source Source
+	err    error
+}
+
+func NewEnvironment(doc *ProofDocument) *Environment {
+	return &Environment{
+		lemmas:   doc.lemmas,
+		defs:     doc.defs,
+		states:   doc.states,
+		imports:  map[string]*ProofModule{},
+		shadowed: map[string]bool{},
+		warned:   map[string]bool{},
+	}
+}
+
+// The environment of a document, along with the conditions and states of the lemmas, defs and blocks being generated
 type Scope struct {
-	lemmas map[string]Lemma
-	stack  []*LocalScope
-	defs   map[string]SequencedProofSteps
+	env     *Environment
+	stack   []*LocalScope
+	assumed []TokenStream // Conditions of the split cases being helped, which are only added once their helpers are done
 }
 
-func (scope *Scope) cloneRoot() Scope {
-	v := Scope{
-		lemmas: map[string]Lemma{},
-		stack:  []*LocalScope{},
-		defs:   map[string]SequencedProofSteps{},
+func NewScope(doc *ProofDocument) Scope {
+	return Scope{
+		env:   NewEnvironment(doc),
+		stack: make([]*LocalScope, 0),
 	}
-	for k, lemma := range scope.lemmas {
-		v.lemmas[k] = lemma
+}
+
+// The same environment without any conditions or states, as lemmas do not inherit them
+func (scope *Scope) fresh() Scope {
+	return Scope{
+		env:   scope.env,
+		stack: []*LocalScope{},
 	}
-	return v
 }
 
 func (scope *Scope) push(local *LocalScope) {
+	for _, name := range slices.Sorted(maps.Keys(local.states)) {
+		if state, ok := scope.env.states[name]; ok && !scope.env.shadowed[name] {
+			scope.env.shadowed[name] = true
+			scope.warnOnce(Source{}, fmt.Errorf("warning: state %s shadows the global state defined at %s", name, state.source))
+		}
+	}
 	scope.stack = append(scope.stack, local)
 }
 
@@ -43,6 +83,16 @@ func (scope *Scope) getState(name string) TokenStream {
 			return state
 		}
 	}
+	if state, ok := scope.env.states[name]; ok {
+		return state.value
+	}
+	if strings.Contains(name, ".") {
+		if module, name, err := scope.resolve(name); err == nil && module != nil {
+			if state, ok := module.doc.states[name]; ok {
+				return state.value
+			}
+		}
+	}
 
 	panic(fmt.Errorf("could not find state %s", name))
 }
@@ -55,10 +105,31 @@ func (scope *Scope) getPreConditions() []TokenStream {
 	return pres
 }
 
+// A condition of a scope, and the command it came from
+type Origin struct {
+	condition TokenStream
+	command   ProvenanceStep
+}
+
+// The conditions of getPreConditions, along with where they came from
+func (scope *Scope) getOrigins() []Origin {
+	origins := []Origin{}
+	for _, scope := range scope.stack {
+		for i, cond := range scope.conditions {
+			command := ProvenanceStep{kind: "cond"}
+			if i < len(scope.origins) {
+				command = scope.origins[i]
+			}
+			origins = append(origins, Origin{condition: cond, command: command})
+		}
+	}
+	return origins
+}
+
 type Provable interface {
 	walkProps(func(*Property))
 	copy() Provable
-	flatten(*FlatProofSequence, int) int
+	flatten(*FlatProofSequence, FlatPosition) []int
 }
 
 func prefix(prop Provable, prefix string) {
@@ -86,23 +157,105 @@ func condition(prop Provable, cond TokenStream) {
 }
 
 type FlatProofSequence struct {
-	wires []Wiring
-	props [][]*Property
+	wires    []Wiring
+	props    [][]*Property
+	after    [][]int        // The steps each step is directly proved after, always earlier steps
+	keys     map[string]int // The step at each position, so that properties at the same position share a step
+	branches int
+}
+
+func NewFlatProofSequence() FlatProofSequence {
+	return FlatProofSequence{
+		wires: []Wiring{},
+		props: make([][]*Property, 0),
+		after: [][]int{},
+		keys:  map[string]int{},
+	}
+}
+
+// Where a provable is flattened to, after the given steps within a branch of a proof DAG
+type FlatPosition struct {
+	after  []int
+	branch int
+}
+
+// A new branch, whose first step is not shared with any other branch after the same steps
+func (seq *FlatProofSequence) newBranch() int {
+	seq.branches += 1
+	return seq.branches
+}
+
+// Every step a step is proved after, directly or indirectly, in ascending order
+func (seq *FlatProofSequence) assumes(n int) []int {
+	found := map[int]bool{}
+	stack := slices.Clone(seq.after[n])
+	for len(stack) > 0 {
+		i := stack[len(stack)-1]
+		stack = stack[:len(stack)-1]
+		if !found[i] {
+			found[i] = true
+			stack = append(stack, seq.after[i]...)
+		}
+	}
+	return slices.Sorted(maps.Keys(found))
+}
+
+// The given steps without duplicates or those already assumed by another
+func (seq *FlatProofSequence) reduce(steps []int) []int {
+	assumed := map[int]bool{}
+	for _, n := range steps {
+		for _, i := range seq.assumes(n) {
+			assumed[i] = true
+		}
+	}
+	reduced := []int{}
+	for _, n := range steps {
+		if !assumed[n] && !slices.Contains(reduced, n) {
+			reduced = append(reduced, n)
+		}
+	}
+	slices.Sort(reduced)
+	return reduced
+}
+
+// Whether every step is proved after all of the steps before it
+func (seq *FlatProofSequence) linear() bool {
+	for i, after := range seq.after {
+		if (i == 0 && len(after) != 0) || (i != 0 && !slices.Equal(after, []int{i - 1})) {
+			return false
+		}
+	}
+	return true
 }
 
-func (seq *FlatProofSequence) checkNames() {
+// The step for properties at a position, creating it if needed
+func (seq *FlatProofSequence) stepAt(pos FlatPosition) int {
+	after := seq.reduce(pos.after)
+	key := fmt.Sprint(pos.branch, after)
+	if n, ok := seq.keys[key]; ok {
+		return n
+	}
+	seq.props = append(seq.props, make([]*Property, 0))
+	seq.after = append(seq.after, after)
+	seq.keys[key] = len(seq.props) - 1
+	return len(seq.props) - 1
+}
+
+func (seq *FlatProofSequence) checkNames(scope *Scope) {
 	names := []string{}
 	unnamed := 0
 	for _, group := range seq.props {
 		for _, prop := range group {
 			if prop.name == "" {
 				unnamed += 1
-				fmt.Fprintln(os.Stderr, fmt.Errorf("warning: unnamed property with post condition %s. Giving it name Unnamed_%d", prop.postCondition, unnamed))
+				scope.warnOnce(prop.source, fmt.Errorf("warning: unnamed property with post condition %s. Giving it name Unnamed_%d", prop.postCondition, unnamed))
 				prop.name = "Unnamed_" + strconv.Itoa(unnamed)
+				prop.labels = []string{prop.name}
 			} else if slices.Contains(names, prop.name) {
 				unnamed += 1
-				fmt.Fprintln(os.Stderr, fmt.Errorf("warning: multiple properties with name %s, renaming to %s_%d", prop.name, prop.name, unnamed))
+				scope.warnOnce(prop.source, fmt.Errorf("warning: multiple properties with name %s, renaming to %s_%d", prop.name, prop.name, unnamed))
 				prop.name += "_" + strconv.Itoa(unnamed)
+				prop.labels = append(prop.labels, strconv.Itoa(unnamed))
 			} else {
 				names = append(names, prop.name)
 			}
@@ -110,28 +263,39 @@ func (seq *FlatProofSequence) checkNames() {
 	}
 }
 
-func (seq *FlatProofSequence) addTo(n int, prop *Property) {
-	for n >= len(seq.props) {
-		seq.props = append(seq.props, make([]*Property, 0))
-	}
-	seq.props[n] = append(seq.props[n], prop)
-}
-
 type Property struct {
 	name          string
 	preConditions []TokenStream
 	postCondition TokenStream
 	step          string
 	wait          int
+	source        Source
+	helpers       []ProvenanceStep // The helpers which produced this property, outermost first
+	origins       []Origin         // The conditions of the cond and in commands it is under, outermost first
+	lemma         string           // The innermost lemma this property was proved in
+	using         *UsingClause     // Restricts the earlier properties this assumes, nil to assume all of them
+	usingScopes   []string         // Labels prefixed onto this property since its using clause, innermost first
+	labels        []string         // The labels its name is made of, outermost first
+	assumed       bool             // Kept only as an assumption of selected properties
+	shard         int              // The shard of its step it is proved in
+	cover         bool             // A cover property, which is never assumed
 }
 
-func NewPropertyFrom(name string, statement TokenStream, scope *Scope) Property {
+func NewPropertyFrom(name string, statement TokenStream, scope *Scope, source Source) Property {
+	labels := []string{}
+	if name != "" {
+		labels = append(labels, name)
+	}
 	return Property{
 		name:          name,
 		postCondition: statement,
 		preConditions: scope.getPreConditions(),
+		origins:       scope.getOrigins(),
 		step:          "|->",
 		wait:          0,
+		source:        source,
+		helpers:       []ProvenanceStep{},
+		labels:        labels,
 	}
 }
 
@@ -140,6 +304,10 @@ func (prop *Property) walkProps(f func(*Property)) {
 }
 
 func (prop *Property) prefix(prefix string) {
+	if prop.using != nil {
+		prop.usingScopes = append(prop.usingScopes, prefix)
+	}
+	prop.labels = append([]string{prefix}, prop.labels...)
 	if prop.name == "" {
 		prop.name = prefix
 	} else {
@@ -148,6 +316,7 @@ func (prop *Property) prefix(prefix string) {
 }
 
 func (prop *Property) suffix(suffix string) {
+	prop.labels = append(prop.labels, suffix)
 	if prop.name == "" {
 		prop.name = suffix
 	} else {
@@ -164,7 +333,7 @@ func (prop *Property) subs(name string, subs TokenStream) {
 
 func (prop *Property) condition(cond TokenStream) {
 	for _, pre := range prop.preConditions {
-		if slices.Equal(pre, cond) {
+		if streamsEqual(pre, cond) {
 			return
 		}
 	}
@@ -178,17 +347,34 @@ func (prop *Property) copy() Provable {
 		postCondition: prop.postCondition,
 		step:          prop.step,
 		wait:          prop.wait,
+		source:        prop.source,
+		helpers:       slices.Clone(prop.helpers),
+		origins:       prop.origins,
+		lemma:         prop.lemma,
+		using:         prop.using,
+		usingScopes:   slices.Clone(prop.usingScopes),
+		labels:        slices.Clone(prop.labels),
+		assumed:       prop.assumed,
+		shard:         prop.shard,
+		cover:         prop.cover,
 	}
 }
 
-func (prop *Property) flatten(seq *FlatProofSequence, n int) int {
-	seq.addTo(n, prop)
-	return n
+func (prop *Property) flatten(seq *FlatProofSequence, pos FlatPosition) []int {
+	n := seq.stepAt(pos)
+	seq.props[n] = append(seq.props[n], prop)
+	return []int{n}
 }
 
 type Wiring struct {
 	name  string
 	value TokenStream
+	node  *GraphNodeWire // Set if it is the condition of a graph_induction node
+}
+
+type GraphNodeWire struct {
+	graph string   // The prefix of the wires of the graph it is in
+	steps []string // The wires of the nodes it may step to
 }
 
 // An unordered set of properties
@@ -205,7 +391,11 @@ func NewProvableGroup() ProvableGroup {
 }
 
 func (group *ProvableGroup) appendWire(name string, value TokenStream) {
-	group.wires = append(group.wires, Wiring{name, value})
+	group.wires = append(group.wires, Wiring{name: name, value: value})
+}
+
+func (group *ProvableGroup) appendNodeWire(name string, value TokenStream, node *GraphNodeWire) {
+	group.wires = append(group.wires, Wiring{name: name, value: value, node: node})
 }
 
 func (group *ProvableGroup) walkProps(f func(*Property)) {
@@ -226,15 +416,16 @@ func (group *ProvableGroup) append(prop Provable) {
 	}
 }
 
-func (group *ProvableGroup) flatten(seq *FlatProofSequence, prev int) int {
+func (group *ProvableGroup) flatten(seq *FlatProofSequence, pos FlatPosition) []int {
 	seq.wires = append(seq.wires, group.wires...)
-	max := prev
+	if len(group.props) == 0 {
+		return pos.after
+	}
+	ends := []int{}
 	for _, prop := range group.props {
-		if n := prop.flatten(seq, prev); n > max {
-			max = n
-		}
+		ends = append(ends, prop.flatten(seq, pos)...)
 	}
-	return max
+	return seq.reduce(ends)
 }
 
 func (group *ProvableGroup) copy() Provable {
@@ -276,12 +467,68 @@ func (seq *ProvableSeq) copy() Provable {
 	return &new
 }
 
-func (seq *ProvableSeq) flatten(fseq *FlatProofSequence, prev int) int {
-	max := prev
+func (seq *ProvableSeq) flatten(fseq *FlatProofSequence, pos FlatPosition) []int {
+	after := pos.after
 	for _, prop := range seq.seq {
-		max = prop.flatten(fseq, max) + 1
+		after = prop.flatten(fseq, FlatPosition{after: after, branch: pos.branch})
+	}
+	return after
+}
+
+// Steps of properties each proved after some of the earlier steps, rather than all of them
+type ProvableDag struct {
+	steps []Provable // nil for an empty step
+	after [][]int
+}
+
+func (dag *ProvableDag) walkProps(f func(*Property)) {
+	for _, step := range dag.steps {
+		if step != nil {
+			step.walkProps(f)
+		}
+	}
+}
+
+func (dag *ProvableDag) copy() Provable {
+	new := ProvableDag{
+		steps: make([]Provable, len(dag.steps)),
+		after: dag.after,
+	}
+	for i, step := range dag.steps {
+		if step != nil {
+			new.steps[i] = step.copy()
+		}
+	}
+	return &new
+}
+
+func (dag *ProvableDag) flatten(fseq *FlatProofSequence, pos FlatPosition) []int {
+	ends := make([][]int, len(dag.steps))
+	followed := make([]bool, len(dag.steps))
+	for i, step := range dag.steps {
+		after := pos.after
+		if len(dag.after[i]) > 0 {
+			after = []int{}
+			for _, j := range dag.after[i] {
+				after = append(after, ends[j]...)
+				followed[j] = true
+			}
+		}
+		if step == nil {
+			ends[i] = after
+		} else {
+			ends[i] = step.flatten(fseq, FlatPosition{after: after, branch: fseq.newBranch()})
+		}
 	}
-	return max - 1
+
+	// Anything after the DAG is after every step which nothing else in it is after
+	last := []int{}
+	for i := range dag.steps {
+		if !followed[i] {
+			last = append(last, ends[i]...)
+		}
+	}
+	return fseq.reduce(last)
 }
 
 type GenProperty interface {
@@ -302,6 +549,7 @@ func (cmd *SequenceProofHelper) helpProperty(scope *Scope, prop Provable) Provab
 func (cmd *KInductionProofHelper) helpProperty(scope *Scope, prop Provable) Provable {
 	group := NewProvableGroup()
 	copy := prop.copy()
+	provenance(copy, ProvenanceStep{kind: "k_induction", detail: strconv.Itoa(cmd.k), source: cmd.source})
 	copy.walkProps(func(prop *Property) {
 		prop.prefix(strconv.Itoa(cmd.k) + "Ind")
 
@@ -336,20 +584,29 @@ func (cmd *SplitProofHelper) helpProperty(scope *Scope, prop Provable) Provable
 	group := NewProvableGroup()
 
 	for i, cas := range cmd.cases {
+		name := cas.label
+		if name == "" {
+			name = "Case" + strconv.Itoa(i)
+		}
+
 		new := prop.copy()
-		new = cas.helper.helpProperty(scope, new)
+		provenance(new, ProvenanceStep{kind: "split_case", detail: name, source: cas.source})
+		caseScope := *scope
+		caseScope.assumed = append(slices.Clone(scope.assumed), cas.condition.getStream(scope))
+		new = cas.helper.helpProperty(&caseScope, new)
 		condition(new, cas.condition.getStream(scope))
-		if cas.label != "" {
-			suffix(new, cas.label)
-		} else {
-			suffix(new, "Case"+strconv.Itoa(i))
-		}
+		suffix(new, name)
 		group.append(new)
 	}
 
 	if !cmd.check {
 		return &group
 	}
+	conds := []TokenStream{}
+	for _, cas := range cmd.cases {
+		conds = append(conds, cas.condition.getStream(scope))
+	}
+	scope.checkCovers(conds, "split cases", cmd.source)
 	return &ProvableSeq{
 		seq: []Provable{&group, prop},
 	}
@@ -366,7 +623,20 @@ func (cmd *SplitBoolProofHelper) helpProperty(scope *Scope, prop Provable) Prova
 	for i < 1<<len(cmd.pivots) {
 		new := prop.copy()
 
+		assignment := []string{}
 		for j, pivot := range cmd.pivots {
+			value := "0"
+			if i&(1<<j) != 0 {
+				value = "1"
+			}
+			assignment = append(assignment, pivot.describe()+"="+value)
+		}
+		provenance(new, ProvenanceStep{kind: "split_bool", detail: strings.Join(assignment, " "), source: cmd.source})
+
+		for j, pivot := range cmd.pivots {
+			if i == 0 {
+				scope.checkComplement(pivot.getStream(scope), negate(pivot.getStream(scope)), cmd.source)
+			}
 			if i&(1<<j) != 0 {
 				condition(new, pivot.getStream(scope))
 
@@ -394,15 +664,15 @@ func (cmd *SplitBoolProofHelper) helpProperty(scope *Scope, prop Provable) Prova
 }
 
 func (cmd *LemmaProofCommand) genProperty(scope *Scope) Provable {
-	lemma, ok := scope.lemmas[cmd.name]
-	if !ok {
-		panic(fmt.Errorf("lemma does not exist: %s", cmd.name))
+	lemma, fresh, err := scope.lemmaScope(cmd.name)
+	if err != nil {
+		panic(cmd.source.locate(err))
 	}
-	fresh := scope.cloneRoot()
 	prop := lemma.genProperty(&fresh)
 	if cmd.label != "" {
 		prefix(prop, cmd.label)
 	}
+	useOnly(prop, cmd.using, cmd.source)
 	return prop
 }
 
@@ -411,6 +681,7 @@ func (cmd *BlockProofCommand) genProperty(scope *Scope) Provable {
 	if cmd.label != "" {
 		prefix(prop, cmd.label)
 	}
+	useOnly(prop, cmd.using, cmd.source)
 	return prop
 }
 
@@ -431,8 +702,10 @@ func (cmd *EachProofCommand) genProperty(scope *Scope) Provable {
 }
 
 func (cmd *HaveProofCommand) genProperty(scope *Scope) Provable {
-	prop := NewPropertyFrom(cmd.label, cmd.condition, scope)
-	return cmd.helper.helpProperty(scope, &prop)
+	prop := NewPropertyFrom(cmd.label, cmd.condition, scope, cmd.source)
+	helped := cmd.helper.helpProperty(scope, &prop)
+	useOnly(helped, cmd.using, cmd.source)
+	return helped
 }
 
 func (cmd *InStatesSubProofCommand) genProperty(scope *Scope) Provable {
@@ -441,6 +714,7 @@ func (cmd *InStatesSubProofCommand) genProperty(scope *Scope) Provable {
 		scope.push(&LocalScope{
 			states:     map[string]TokenStream{},
 			conditions: []TokenStream{cond.getStream(scope)},
+			origins:    []ProvenanceStep{{kind: "in", detail: cond.describe(), source: cmd.source}},
 		})
 		prop := cmd.seq.genProperty(scope)
 		if cond.label != "" {
@@ -456,12 +730,26 @@ func (cmd *InStatesSubProofCommand) genProperty(scope *Scope) Provable {
 }
 
 func (cmd *UseProofCommand) genProperty(scope *Scope) Provable {
-	prop_seq, ok := scope.defs[cmd.name]
+	module, name, err := scope.resolve(cmd.name)
+	if err != nil {
+		panic(cmd.source.locate(err))
+	}
+	defScope := *scope
+	if module != nil {
+		// A def from another module keeps the conditions and states it is used in, but has the names of its module
+		defScope = scope.enter(module)
+	}
+	prop_seq, ok := defScope.env.defs[name]
 	if !ok {
-		panic(fmt.Errorf("undefined def %s", cmd.name))
+		panic(cmd.source.locate(fmt.Errorf("undefined def %s", cmd.name)))
 	}
 
-	return cmd.helper.helpProperty(scope, prop_seq.genProperty(scope))
+	return cmd.helper.helpProperty(scope, prop_seq.genProperty(&defScope))
+}
+
+// Node names in a fixed order, so that generated output is deterministic
+func (cmd *GraphInductionProofHelper) nodeNames() []string {
+	return slices.Sorted(maps.Keys(cmd.nodes))
 }
 
 func (cmd *GraphInductionProofHelper) genCommonProperty(scope *Scope) Provable {
@@ -475,8 +763,13 @@ func (cmd *GraphInductionProofHelper) genCommonProperty(scope *Scope) Provable {
 
 	group.appendWire(namePrefix+"pre", conjoin(scope.getPreConditions()))
 
-	for name, node := range cmd.nodes {
-		group.appendWire(namePrefix+name, node.condition.getStream(scope))
+	for _, name := range cmd.nodeNames() {
+		node := cmd.nodes[name]
+		steps := []string{}
+		for _, dst := range node.stepTransitions {
+			steps = append(steps, namePrefix+dst)
+		}
+		group.appendNodeWire(namePrefix+name, node.condition.getStream(scope), &GraphNodeWire{graph: namePrefix, steps: steps})
 		if node.invariant.verbatim {
 			group.appendWire(namePrefix+name+"_inv", node.invariant.stream)
 		} else {
@@ -513,23 +806,26 @@ func (cmd *GraphInductionProofHelper) genCommonProperty(scope *Scope) Provable {
 		group.appendWire(namePrefix+"initial", cmd.entryCondition)
 		// Base cases:
 		// Check that the entry condition implies one of the entry nodes are active
-		prop := NewPropertyFrom("Initial", unionNodeConds(cmd.entryNodes), scope)
+		prop := NewPropertyFrom("Initial", unionNodeConds(cmd.entryNodes), scope, cmd.source)
 		prop.condition(cond("initial"))
 		entryGroup.appendProp(prop)
 
 		// Check that whichever entry node we are in, that node's invariant is satisfied
 		for _, node := range cmd.entryNodes {
-			prop := NewPropertyFrom("Initial_"+camelCase(node), invariant(node), scope)
+			prop := NewPropertyFrom("Initial", invariant(node), scope, cmd.source)
+			prop.suffix(camelCase(node))
 			prop.condition(cond(node))
 			prop.condition(cond("initial"))
 			entryGroup.appendProp(prop)
 		}
 
+		provenance(&entryGroup, ProvenanceStep{kind: "graph_entry", source: cmd.entrySource})
 		group.append(cmd.entryHelper.helpProperty(scope, &entryGroup))
 	}
 
 	// Inductive steps:
-	for name, node := range cmd.nodes {
+	for _, name := range cmd.nodeNames() {
+		node := cmd.nodes[name]
 		subGroup := NewProvableGroup()
 
 		if len(node.stepTransitions) != 0 || len(node.epsTransitions) != 0 {
@@ -546,7 +842,8 @@ func (cmd *GraphInductionProofHelper) genCommonProperty(scope *Scope) Provable {
 					paren(disjoin(negPre)),
 				}
 
-				prop := NewPropertyFrom(camelCase(name)+"_Step", currs, scope)
+				prop := NewPropertyFrom(camelCase(name), currs, scope, cmd.source)
+				prop.suffix("Step")
 				prop.condition(invariant(name))
 				prop.condition(cond(name))
 				subGroup.appendProp(prop)
@@ -554,7 +851,9 @@ func (cmd *GraphInductionProofHelper) genCommonProperty(scope *Scope) Provable {
 
 			for _, dst := range node.stepTransitions {
 				// If last cycle I was active and this cycle you are active, then my invariant being true last cycle implies your invariant is true this cycle
-				prop := NewPropertyFrom(camelCase(name)+"_"+camelCase(dst)+"_Inv", invariant(dst), scope)
+				prop := NewPropertyFrom(camelCase(name), invariant(dst), scope, cmd.source)
+				prop.suffix(camelCase(dst))
+				prop.suffix("Inv")
 				prop.condition(past(cond(name), 1))
 				prop.condition(cond(dst))
 				prop.condition(past(invariant(name), 1))
@@ -563,7 +862,9 @@ func (cmd *GraphInductionProofHelper) genCommonProperty(scope *Scope) Provable {
 
 			for _, dst := range node.epsTransitions {
 				// If this cycle I am active and this cycle you are active, then my invariant being true now implies your invariant is true now
-				prop := NewPropertyFrom(camelCase(name)+"_"+camelCase(dst)+"_Inv", invariant(dst), scope)
+				prop := NewPropertyFrom(camelCase(name), invariant(dst), scope, cmd.source)
+				prop.suffix(camelCase(dst))
+				prop.suffix("Inv")
 				prop.condition(cond(name))
 				prop.condition(cond(dst))
 				prop.condition(invariant(name))
@@ -571,15 +872,29 @@ func (cmd *GraphInductionProofHelper) genCommonProperty(scope *Scope) Provable {
 			}
 		}
 
+		provenance(&subGroup, ProvenanceStep{kind: "graph_node", detail: name, source: node.source})
 		group.append(node.helper.helpProperty(scope, &subGroup))
 	}
 
+	if cmd.cover {
+		group.append(cmd.genCovers(scope))
+	}
+
 	sequence := []Provable{}
 
 	if cmd.complete || cmd.onehot {
 		allNodes := []TokenStream{}
-		for name := range cmd.nodes {
+		nodeConds := []TokenStream{}
+		for _, name := range cmd.nodeNames() {
 			allNodes = append(allNodes, cond(name))
+			node := cmd.nodes[name]
+			nodeConds = append(nodeConds, node.condition.getStream(scope))
+		}
+		if cmd.complete {
+			scope.checkCovers(nodeConds, "graph nodes", cmd.source)
+		}
+		if cmd.onehot {
+			scope.checkDisjoint(cmd.nodeNames(), nodeConds, "graph nodes", cmd.source)
 		}
 		var cond TokenStream
 		if cmd.onehot && cmd.complete {
@@ -589,7 +904,7 @@ func (cmd *GraphInductionProofHelper) genCommonProperty(scope *Scope) Provable {
 		} else if cmd.onehot {
 			cond = onehot0(allNodes)
 		}
-		completeness := NewPropertyFrom("Complete", cond, scope)
+		completeness := NewPropertyFrom("Complete", cond, scope, cmd.source)
 		sequence = append(sequence, &completeness)
 	}
 
@@ -599,10 +914,12 @@ func (cmd *GraphInductionProofHelper) genCommonProperty(scope *Scope) Provable {
 	if cmd.backward {
 		subGroup := NewProvableGroup()
 
-		for name, node := range cmd.nodes {
+		for _, name := range cmd.nodeNames() {
+			node := cmd.nodes[name]
 			epsIncomingNodes := []string{}
 			stepIncomingNodes := []string{}
-			for otherName, other := range cmd.nodes {
+			for _, otherName := range cmd.nodeNames() {
+				other := cmd.nodes[otherName]
 				if slices.Contains(other.stepTransitions, name) {
 					stepIncomingNodes = append(stepIncomingNodes, otherName)
 				}
@@ -624,8 +941,10 @@ func (cmd *GraphInductionProofHelper) genCommonProperty(scope *Scope) Provable {
 			}
 
 			// If my condition is true now, then in the previous cycle one of the conditions of one of the incoming nodes is true
-			prop := NewPropertyFrom(camelCase(name)+"_Rev", backwardStr, scope)
+			prop := NewPropertyFrom(camelCase(name), backwardStr, scope, cmd.source)
+			prop.suffix("Rev")
 			prop.condition(cond(name))
+			provenance(&prop, ProvenanceStep{kind: "graph_node", detail: name + " reverse", source: node.source})
 			subGroup.append(node.helper.helpProperty(scope, &prop))
 		}
 		sequence = append(sequence, &subGroup)
@@ -633,9 +952,10 @@ func (cmd *GraphInductionProofHelper) genCommonProperty(scope *Scope) Provable {
 
 	// Invariant checks
 	checks := NewProvableGroup()
-	for name := range cmd.nodes {
-		prop := NewPropertyFrom(camelCase(name), invariant(name), scope)
+	for _, name := range cmd.nodeNames() {
+		prop := NewPropertyFrom(camelCase(name), invariant(name), scope, cmd.source)
 		prop.condition(cond(name))
+		provenance(&prop, ProvenanceStep{kind: "graph_node", detail: name + " invariant", source: cmd.nodes[name].source})
 		checks.appendProp(prop)
 	}
 	sequence = append(sequence, &checks)
@@ -648,41 +968,110 @@ func (cmd *GraphInductionProofHelper) genCommonProperty(scope *Scope) Provable {
 	return seq
 }
 
+// Covers of each node, transition and entry of the graph, as one which is never reached usually has a wrong condition
+func (cmd *GraphInductionProofHelper) genCovers(scope *Scope) Provable {
+	group := NewProvableGroup()
+
+	namePrefix := ""
+	if cmd.label != "" {
+		namePrefix = strings.ToLower(cmd.label) + "_"
+	}
+	cond := func(node string) TokenStream {
+		return TokenStream{&NameToken{content: namePrefix + node}}
+	}
+	cover := func(names []string, pre TokenStream, step string, post TokenStream, from ProvenanceStep) {
+		prop := NewPropertyFrom("", post, scope, cmd.source)
+		for _, name := range names {
+			prop.suffix(camelCase(name))
+		}
+		prop.suffix("Cov")
+		if pre != nil {
+			prop.condition(pre)
+		}
+		prop.step = step
+		prop.cover = true
+		provenance(&prop, from)
+		group.appendProp(prop)
+	}
+
+	for _, node := range cmd.entryNodes {
+		cover([]string{"initial", node}, cond("initial"), "|->", cond(node), ProvenanceStep{kind: "graph_entry", source: cmd.entrySource})
+	}
+	for _, name := range cmd.nodeNames() {
+		node := cmd.nodes[name]
+		from := ProvenanceStep{kind: "graph_node", detail: name, source: node.source}
+		cover([]string{name}, nil, "|->", cond(name), from)
+		for _, dst := range node.stepTransitions {
+			cover([]string{name, dst}, cond(name), "|=>", cond(dst), from)
+		}
+		for _, dst := range node.epsTransitions {
+			names := []string{name, dst}
+			if slices.Contains(node.stepTransitions, dst) {
+				names = append(names, "now")
+			}
+			cover(names, cond(name), "|->", cond(dst), from)
+		}
+	}
+	return &group
+}
+
 func (cmd *GraphInductionProofCommand) genProperty(scope *Scope) Provable {
 	return cmd.proof.genCommonProperty(scope)
 }
 
-func (seq *SequencedProofSteps) genProperty(scope *Scope) Provable {
-	scope.push(&seq.scope)
-	prop := ProvableSeq{
-		seq: make([]Provable, 0),
+// The properties of a single step, or nil if it is empty
+func genStep(step []ProofCommand, scope *Scope) Provable {
+	if len(step) == 0 {
+		return nil
 	}
 
-	for _, step := range seq.sequence {
-		if len(step) == 0 {
-			continue
-		}
+	if len(step) == 1 {
+		return step[0].genProperty(scope)
+	}
 
-		if len(step) == 1 {
-			prop.append(step[0].genProperty(scope))
-			continue
-		}
+	group := ProvableGroup{
+		props: make([]Provable, 0),
+	}
+	for _, cmd := range step {
+		group.append(cmd.genProperty(scope))
+	}
+	return &group
+}
 
-		group := ProvableGroup{
-			props: make([]Provable, 0),
+func (seq *SequencedProofSteps) genProperty(scope *Scope) Provable {
+	scope.push(&seq.scope)
+	defer scope.pop()
+
+	if !seq.linear() {
+		dag := ProvableDag{
+			steps: []Provable{},
+			after: [][]int{},
 		}
-		for _, cmd := range step {
-			group.append(cmd.genProperty(scope))
+		for i, step := range seq.sequence {
+			dag.steps = append(dag.steps, genStep(step, scope))
+			dag.after = append(dag.after, seq.stepAfter(i))
 		}
-		prop.append(&group)
+		return &dag
 	}
 
-	scope.pop()
+	prop := ProvableSeq{
+		seq: make([]Provable, 0),
+	}
+	for _, step := range seq.sequence {
+		if step := genStep(step, scope); step != nil {
+			prop.append(step)
+		}
+	}
 	return &prop
 }
 
 func (lemma *Lemma) genProperty(scope *Scope) Provable {
 	prop := lemma.seq.genProperty(scope)
+	prop.walkProps(func(prop *Property) {
+		if prop.lemma == "" {
+			prop.lemma = lemma.name
+		}
+	})
 	if lemma.label != "" {
 		prefix(prop, lemma.label)
 	}
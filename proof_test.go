@@ -0,0 +1,86 @@
+package main
+
+import (
+	"fmt"
+	"strings"
+	"testing"
+)
+
+func loadNested(t *testing.T, root string) FlatProofSequence {
+	t.Helper()
+	scope, err := loadScope([]string{"examples/nested.proof"}, []string{}, map[string]string{}, []string{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	return genSequence(&scope, root)
+}
+
+func TestNestedImports(t *testing.T) {
+	for _, test := range []struct {
+		root  string
+		props [][]string
+		sva   []string
+	}{
+		{
+			root:  "outer",
+			props: [][]string{{"Middle_Inner_NoErr"}, {"Middle_Core_Regs", "Middle_Core_PC"}, {"Regs"}},
+			sva: []string{
+				"Middle_Inner_NoErr: assert property (valid |-> ~err);",
+				"Middle_Core_Regs: assert property (finishing_executed |-> regs_match);",
+				"Middle_Core_PC: assert property (finishing_executed |-> pc_match);",
+				"Regs: assert property (outer_only && finishing_executed |-> regs_match);",
+			},
+		},
+		{
+			root:  "core.writeback",
+			props: [][]string{{"Regs", "PC"}},
+			sva: []string{
+				"Regs: assert property (finishing_executed |-> regs_match);",
+				"PC: assert property (finishing_executed |-> pc_match);",
+			},
+		},
+	} {
+		seq := loadNested(t, test.root)
+		props := [][]string{}
+		for _, step := range seq.props {
+			names := []string{}
+			for _, prop := range step {
+				names = append(names, prop.name)
+			}
+			props = append(props, names)
+		}
+		if fmt.Sprint(props) != fmt.Sprint(test.props) {
+			t.Errorf("%s: properties %v, want %v", test.root, props, test.props)
+		}
+		sva := seq.toSva(-1, false, false, 100)
+		for _, line := range test.sva {
+			if !strings.Contains(sva, line) {
+				t.Errorf("%s: SystemVerilog missing %q:\n%s", test.root, line, sva)
+			}
+		}
+	}
+}
+
+// Lemmas using defs once panicked when imported, as their scope had none of the document's defs
+func TestImportedLemmaUsesDef(t *testing.T) {
+	seq := testSequence(t, `
+def check
+  Check: have (c)
+
+lemma used
+  use check
+
+lemma top
+  Used: lemma used
+`, "top")
+	if len(seq.props) != 1 || len(seq.props[0]) != 1 || seq.props[0][0].name != "Used_Check" {
+		t.Errorf("properties %v, want Used_Check", seq.props)
+	}
+
+	defer func() {
+		if r := recover(); r == nil || !strings.Contains(fmt.Sprint(r), "undefined def missing") {
+			t.Errorf("expected undefined def panic, got %v", r)
+		}
+	}()
+	testSequence(t, "lemma used\n  use missing\n\nlemma top\n  Used: lemma used\n", "top")
+}
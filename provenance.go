@@ -0,0 +1,131 @@
+package main
+
+import (
+	"encoding/json"
+	"strconv"
+	"strings"
+)
+
+// Where a command came from
+type Source struct {
+	file string
+	line int // 1-indexed, 0 if unknown
+}
+
+func (src Source) String() string {
+	if src.line == 0 {
+		return ""
+	}
+	if src.file == "" {
+		return "line " + strconv.Itoa(src.line)
+	}
+	return src.file + ":" + strconv.Itoa(src.line)
+}
+
+// An error at the source as a SourceError, or as it is if the source is unknown
+func (src Source) locate(err error) error {
+	if src.line == 0 {
+		return err
+	}
+	return SourceError{line: src.line, err: err}
+}
+
+func (cmd *Command) source() Source {
+	return Source{file: cmd.file, line: cmd.line}
+}
+
+func setBlocksFile(blocks []Block, file string) {
+	for i := range blocks {
+		blocks[i].first.file = file
+		setBlocksFile(blocks[i].body, file)
+	}
+}
+
+// One of the helpers which produced a property from the command it originated from
+type ProvenanceStep struct {
+	kind   string // split_case, split_bool, graph_node, k_induction and so on
+	detail string
+	source Source
+}
+
+func (step *ProvenanceStep) String() string {
+	str := step.kind
+	if step.detail != "" {
+		str += " " + step.detail
+	}
+	if src := step.source.String(); src != "" {
+		str += " (" + src + ")"
+	}
+	return str
+}
+
+func provenance(prop Provable, step ProvenanceStep) {
+	prop.walkProps(func(prop *Property) {
+		prop.helpers = append(prop.helpers, step)
+	})
+}
+
+// A single line description of where a property came from, or empty if unknown
+func (prop *Property) provenanceComment() string {
+	src := prop.source.String()
+	if src == "" && len(prop.helpers) == 0 {
+		return ""
+	}
+	str := "// From " + src
+	if len(prop.helpers) > 0 {
+		helpers := []string{}
+		for _, step := range prop.helpers {
+			helpers = append(helpers, step.String())
+		}
+		str += " via " + strings.Join(helpers, ", ")
+	}
+	return str + "\n"
+}
+
+type provenanceMapStep struct {
+	Kind   string `json:"kind"`
+	Detail string `json:"detail,omitempty"`
+	File   string `json:"file,omitempty"`
+	Line   int    `json:"line,omitempty"`
+}
+
+type provenanceMapEntry struct {
+	Name    string              `json:"name"`
+	Step    int                 `json:"step"`
+	Lemma   string              `json:"lemma,omitempty"`
+	File    string              `json:"file,omitempty"`
+	Line    int                 `json:"line,omitempty"`
+	Helpers []provenanceMapStep `json:"helpers"`
+}
+
+// A JSON list of every property, as named in the generated SystemVerilog, and where it came from
+func (seq *FlatProofSequence) toProvenanceMap(stepPrefix bool) string {
+	entries := []provenanceMapEntry{}
+	for i, step := range seq.props {
+		for _, prop := range step {
+			entry := provenanceMapEntry{
+				Name:    prop.svaName(stepPrefix, i),
+				Step:    i,
+				Lemma:   prop.lemma,
+				File:    prop.source.file,
+				Line:    prop.source.line,
+				Helpers: []provenanceMapStep{},
+			}
+			for _, helper := range prop.helpers {
+				entry.Helpers = append(entry.Helpers, provenanceMapStep{
+					Kind:   helper.kind,
+					Detail: helper.detail,
+					File:   helper.source.file,
+					Line:   helper.source.line,
+				})
+			}
+			entries = append(entries, entry)
+		}
+	}
+
+	data, err := json.MarshalIndent(entries, "", "  ")
+	if err != nil {
+		panic(err)
+	}
+	return string(data) + "\n"
+}
@@ -0,0 +1,80 @@
+package main
+
+import (
+	"encoding/json"
+	"slices"
+	"strings"
+	"testing"
+)
+
+const provenanceProof = `lemma l
+  Top: have (t)
+    split_bool Bit:(b)
+  G: graph_induction
+    inv ok (x)
+    entry (start) -> idle
+    node idle ok (~run) => busy
+    node busy ok (run) => idle
+`
+
+func TestProvenanceComment(t *testing.T) {
+	seq := testSequence(t, provenanceProof, "l")
+	file := seq.props[0][0].source.file
+	comments := map[string]string{}
+	for _, step := range seq.props {
+		for _, prop := range step {
+			comments[prop.name] = strings.ReplaceAll(prop.provenanceComment(), file, "p.proof")
+		}
+	}
+	for name, want := range map[string]string{
+		"Top_NotBit":      "// From p.proof:2 via split_bool Bit=0 (p.proof:3)\n",
+		"Top_Bit":         "// From p.proof:2 via split_bool Bit=1 (p.proof:3)\n",
+		"G_Initial":       "// From p.proof:4 via graph_entry (p.proof:6)\n",
+		"G_Busy_Idle_Inv": "// From p.proof:4 via graph_node busy (p.proof:8)\n",
+		"G_Idle":          "// From p.proof:4 via graph_node idle invariant (p.proof:7)\n",
+	} {
+		if comments[name] != want {
+			t.Errorf("%s has comment %q, want %q", name, comments[name], want)
+		}
+	}
+	if sva := seq.toSva(-1, false, false, 100); !strings.Contains(sva, seq.props[0][0].provenanceComment()+seq.props[0][0].name+": assert") {
+		t.Errorf("comment is not above its property:\n%s", sva)
+	}
+
+	if comment := (&Property{}).provenanceComment(); comment != "" {
+		t.Errorf("property from nowhere has comment %q", comment)
+	}
+}
+
+func TestProvenanceMap(t *testing.T) {
+	seq := testSequence(t, provenanceProof, "l")
+	file := seq.props[0][0].source.file
+	entries := []provenanceMapEntry{}
+	if err := json.Unmarshal([]byte(seq.toProvenanceMap(true)), &entries); err != nil {
+		t.Fatal(err)
+	}
+
+	names := []string{}
+	for _, entry := range entries {
+		names = append(names, entry.Name)
+	}
+	if !slices.Contains(names, "Step0_Top_Bit") || !slices.Contains(names, "Step1_G_Busy") {
+		t.Errorf("map names properties %v, want them with their step prefix", names)
+	}
+
+	for _, want := range []provenanceMapEntry{
+		{Name: "Step0_Top_NotBit", Step: 0, Lemma: "l", File: file, Line: 2,
+			Helpers: []provenanceMapStep{{Kind: "split_bool", Detail: "Bit=0", File: file, Line: 3}}},
+		{Name: "Step1_G_Busy", Step: 1, Lemma: "l", File: file, Line: 4,
+			Helpers: []provenanceMapStep{{Kind: "graph_node", Detail: "busy invariant", File: file, Line: 8}}},
+	} {
+		i := slices.Index(names, want.Name)
+		if i == -1 {
+			continue
+		}
+		got := entries[i]
+		if got.Name != want.Name || got.Step != want.Step || got.Lemma != want.Lemma || got.File != want.File || got.Line != want.Line || !slices.Equal(got.Helpers, want.Helpers) {
+			t.Errorf("map entry %+v, want %+v", got, want)
+		}
+	}
+}
@@ -0,0 +1,510 @@
+package main
+
+import (
+	"encoding/csv"
+	"flag"
+	"fmt"
+	"os"
+	"path/filepath"
+	"slices"
+	"strconv"
+	"strings"
+)
+
+const (
+	StatusProven       = "proven"
+	StatusCex          = "cex"
+	StatusUndetermined = "undetermined"
+	StatusVacuous      = "vacuous"
+	StatusMissing      = "missing"
+	StatusCovered      = "covered"
+	StatusUncovered    = "uncovered"
+)
+
+var statuses = []string{StatusProven, StatusCex, StatusUndetermined, StatusVacuous, StatusMissing}
+
+var coverStatuses = []string{StatusCovered, StatusUncovered, StatusUndetermined, StatusMissing}
+
+// The outcome of a formal tool checking a single property
+type PropertyResult struct {
+	name   string
+	status string
+	time   string
+}
+
+// Maps the many spellings of results used by formal tools onto our statuses
+func normalizeStatus(status string) string {
+	switch strings.ToLower(strings.TrimSpace(status)) {
+	case "proven", "proved", "pass", "passed", "valid":
+		return StatusProven
+	case "cex", "ar_cex", "fail", "failed", "falsified":
+		return StatusCex
+	case "vacuous", "unreachable":
+		return StatusVacuous
+	case "covered", "reachable":
+		return StatusCovered
+	default:
+		return StatusUndetermined
+	}
+}
+
+// The status of a cover property from the result of checking it. A cover which passes is covered, and one which is
+// unreachable is uncovered.
+func coverStatus(status string) string {
+	switch status {
+	case StatusProven, StatusCovered:
+		return StatusCovered
+	case StatusVacuous:
+		return StatusUncovered
+	default:
+		return status
+	}
+}
+
+// Drops any hierarchy a tool has added in front of a property name, such as <embedded>::top.u_check.
+func baseName(name string) string {
+	name = strings.TrimSpace(name)
+	if i := strings.LastIndex(name, "::"); i != -1 {
+		name = name[i+2:]
+	}
+	if i := strings.LastIndex(name, "."); i != -1 {
+		name = name[i+1:]
+	}
+	return name
+}
+
+func readCsv(data string) [][]string {
+	reader := csv.NewReader(strings.NewReader(data))
+	reader.FieldsPerRecord = -1
+	reader.LazyQuotes = true
+	reader.TrimLeadingSpace = true
+	reader.Comment = '#'
+	records, err := reader.ReadAll()
+	if err != nil {
+		panic(err)
+	}
+	return records
+}
+
+// A CSV report with a header row, as written by Jasper's report -csv
+func jasperResults(records [][]string) []PropertyResult {
+	nameCol, statusCol, timeCol := -1, -1, -1
+	for i, col := range records[0] {
+		col = strings.ToLower(strings.TrimSpace(col))
+		if nameCol == -1 && (col == "name" || col == "property" || col == "property name") {
+			nameCol = i
+		} else if statusCol == -1 && (col == "status" || col == "result") {
+			statusCol = i
+		} else if timeCol == -1 && strings.Contains(col, "time") {
+			timeCol = i
+		}
+	}
+	if nameCol == -1 || statusCol == -1 {
+		panic(fmt.Errorf("report has no name and status columns"))
+	}
+
+	results := []PropertyResult{}
+	for _, record := range records[1:] {
+		if len(record) <= max(nameCol, statusCol) {
+			continue
+		}
+		result := PropertyResult{name: baseName(record[nameCol]), status: normalizeStatus(record[statusCol])}
+		if timeCol != -1 && timeCol < len(record) {
+			result.time = strings.TrimSpace(record[timeCol])
+		}
+		results = append(results, result)
+	}
+	return results
+}
+
+// Lines of name,status,time with no header, the time being optional
+func simpleResults(records [][]string) []PropertyResult {
+	results := []PropertyResult{}
+	for _, record := range records {
+		if len(record) < 2 {
+			continue
+		}
+		result := PropertyResult{name: baseName(record[0]), status: normalizeStatus(record[1])}
+		if len(record) > 2 {
+			result.time = strings.TrimSpace(record[2])
+		}
+		results = append(results, result)
+	}
+	return results
+}
+
+// The status file of an sby task which checks a single property, named after the task's directory
+func sbyResults(path string, data string) []PropertyResult {
+	fields := strings.Fields(data)
+	if len(fields) == 0 {
+		panic(fmt.Errorf("empty sby status file"))
+	}
+	name := filepath.Base(filepath.Dir(path))
+	return []PropertyResult{{name: baseName(name), status: normalizeStatus(fields[0])}}
+}
+
+func isJasperHeader(record []string) bool {
+	for _, col := range record {
+		col = strings.ToLower(strings.TrimSpace(col))
+		if col == "status" || col == "result" {
+			return true
+		}
+	}
+	return false
+}
+
+// Reads the results of a formal run, in the given format or guessing it if "auto"
+func readResults(path string, format string) (results []PropertyResult, err error) {
+	defer func() {
+		if r := recover(); r != nil {
+			err = fmt.Errorf("%s: %v", path, r)
+		}
+	}()
+
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+
+	if format == "auto" {
+		if filepath.Base(path) == "status" {
+			format = "sby"
+		} else if records := readCsv(string(data)); len(records) > 0 && isJasperHeader(records[0]) {
+			format = "jasper"
+		} else {
+			format = "csv"
+		}
+	}
+
+	switch format {
+	case "jasper":
+		records := readCsv(string(data))
+		if len(records) == 0 {
+			return []PropertyResult{}, nil
+		}
+		return jasperResults(records), nil
+	case "sby":
+		return sbyResults(path, string(data)), nil
+	case "csv":
+		return simpleResults(readCsv(string(data))), nil
+	default:
+		return nil, fmt.Errorf("unknown results format %s", format)
+	}
+}
+
+// Reads every results file, later results overriding earlier ones
+func loadResults(paths []string, format string) ([]PropertyResult, error) {
+	results := []PropertyResult{}
+	for _, path := range paths {
+		fileResults, err := readResults(path, format)
+		if err != nil {
+			return nil, err
+		}
+		results = append(results, fileResults...)
+	}
+	return results, nil
+}
+
+func resultsFlag(flags *flag.FlagSet, paths *[]string) {
+	flags.Func("results", "paths or globs of result files, later results override earlier ones", func(s string) error {
+		matches, err := filepath.Glob(s)
+		if err != nil || len(matches) == 0 {
+			matches = []string{s}
+		}
+		*paths = append(*paths, matches...)
+		return nil
+	})
+}
+
+type ReportProperty struct {
+	prop   *Property
+	step   int
+	name   string
+	status string
+	time   string
+}
+
+type ReportLemma struct {
+	name  string
+	props []ReportProperty
+}
+
+type ReportStep struct {
+	lemmas []*ReportLemma
+	after  []int
+	// Properties of earlier steps which this step assumes but which have not been proven
+	unsound []ReportProperty
+}
+
+type ProofReport struct {
+	root   string
+	linear bool
+	steps  []*ReportStep
+	counts map[string]int
+	// Statuses of cover properties, which are counted apart as they do not make a proof sound or not
+	coverCounts map[string]int
+	uncovered   []ReportProperty
+	// Results which did not match any property, which usually means they are from a stale run
+	unmatched []string
+}
+
+func buildReport(seq *FlatProofSequence, root string, results []PropertyResult, stepPrefix bool) ProofReport {
+	byName := map[string]PropertyResult{}
+	for _, result := range results {
+		byName[result.name] = result
+	}
+
+	report := ProofReport{
+		root:        root,
+		linear:      seq.linear(),
+		steps:       []*ReportStep{},
+		counts:      map[string]int{},
+		coverCounts: map[string]int{},
+		uncovered:   []ReportProperty{},
+		unmatched:   []string{},
+	}
+	matched := map[string]bool{}
+	unproven := map[*Property]ReportProperty{}
+	for i, step := range seq.props {
+		reportStep := &ReportStep{
+			lemmas:  []*ReportLemma{},
+			after:   seq.after[i],
+			unsound: []ReportProperty{},
+		}
+		// Everything asserted in a step is assumed by the steps after it, unless a using clause restricts that
+		assumed := map[*Property]bool{}
+		for _, prop := range step {
+			for _, other := range seq.propAssumes(i, prop) {
+				assumed[other] = true
+			}
+		}
+		for _, j := range seq.assumes(i) {
+			for _, other := range seq.props[j] {
+				if entry, ok := unproven[other]; ok && assumed[other] {
+					reportStep.unsound = append(reportStep.unsound, entry)
+				}
+			}
+		}
+		for _, prop := range step {
+			name := prop.svaName(stepPrefix, i)
+			result, ok := byName[name]
+			if !ok {
+				// Allow results from runs with a different -step-prefix setting
+				name = prop.svaName(!stepPrefix, i)
+				result, ok = byName[name]
+			}
+			entry := ReportProperty{prop: prop, step: i, name: prop.svaName(stepPrefix, i), status: StatusMissing}
+			if ok {
+				matched[name] = true
+				entry.status = result.status
+				entry.time = result.time
+			}
+			if prop.cover {
+				entry.status = coverStatus(entry.status)
+				report.coverCounts[entry.status] += 1
+				if entry.status == StatusUncovered {
+					report.uncovered = append(report.uncovered, entry)
+				}
+			} else {
+				report.counts[entry.status] += 1
+			}
+
+			idx := slices.IndexFunc(reportStep.lemmas, func(lemma *ReportLemma) bool { return lemma.name == prop.lemma })
+			if idx == -1 {
+				reportStep.lemmas = append(reportStep.lemmas, &ReportLemma{name: prop.lemma, props: []ReportProperty{}})
+				idx = len(reportStep.lemmas) - 1
+			}
+			reportStep.lemmas[idx].props = append(reportStep.lemmas[idx].props, entry)
+		}
+
+		for _, lemma := range reportStep.lemmas {
+			for _, entry := range lemma.props {
+				if !entry.prop.cover && entry.status != StatusProven && entry.status != StatusVacuous {
+					unproven[entry.prop] = entry
+				}
+			}
+		}
+		report.steps = append(report.steps, reportStep)
+	}
+
+	for _, result := range results {
+		if !matched[result.name] && !slices.Contains(report.unmatched, result.name) {
+			report.unmatched = append(report.unmatched, result.name)
+		}
+	}
+	return report
+}
+
+// A description of the steps a step is after, or empty if every step is after the one before
+func (report *ProofReport) stepAfter(step *ReportStep) string {
+	if report.linear {
+		return ""
+	}
+	steps := []string{}
+	for _, j := range step.after {
+		steps = append(steps, strconv.Itoa(j))
+	}
+	if len(steps) == 0 {
+		return "independent"
+	}
+	return "after " + strings.Join(steps, ", ")
+}
+
+func (step *ReportStep) unsoundSummary() string {
+	steps := []string{}
+	for _, entry := range step.unsound {
+		if n := strconv.Itoa(entry.step); !slices.Contains(steps, n) {
+			steps = append(steps, n)
+		}
+	}
+	plural := ""
+	if len(steps) > 1 {
+		plural = "s"
+	}
+	return fmt.Sprintf("assumes %d unproven properties from step%s %s", len(step.unsound), plural, strings.Join(steps, ", "))
+}
+
+// Whether every property has been proven, without relying on any unproven assumption
+func (report *ProofReport) sound() bool {
+	return report.counts[StatusCex]+report.counts[StatusUndetermined]+report.counts[StatusMissing] == 0
+}
+
+func (report *ProofReport) summary() string {
+	parts := []string{}
+	for _, status := range statuses {
+		if report.counts[status] > 0 {
+			parts = append(parts, fmt.Sprintf("%d %s", report.counts[status], status))
+		}
+	}
+	if len(parts) == 0 {
+		return "no properties"
+	}
+	return strings.Join(parts, ", ")
+}
+
+func (report *ProofReport) coverSummary() string {
+	parts := []string{}
+	for _, status := range coverStatuses {
+		if report.coverCounts[status] > 0 {
+			parts = append(parts, fmt.Sprintf("%d %s", report.coverCounts[status], status))
+		}
+	}
+	return strings.Join(parts, ", ")
+}
+
+func (report *ProofReport) toText() string {
+	text := report.root + ": " + report.summary() + "\n"
+	if covers := report.coverSummary(); covers != "" {
+		text += "  Covers: " + covers + "\n"
+	}
+	for i, step := range report.steps {
+		text += fmt.Sprintf("  Step %d", i)
+		if after := report.stepAfter(step); after != "" {
+			text += " (" + after + ")"
+		}
+		text += "\n"
+		if len(step.unsound) > 0 {
+			text += "    ! unsound: " + step.unsoundSummary() + "\n"
+		}
+		for _, lemma := range step.lemmas {
+			text += "    " + lemma.name + "\n"
+			for _, entry := range lemma.props {
+				text += fmt.Sprintf("      %-12s %s", entry.status, entry.name)
+				if entry.time != "" {
+					text += " " + entry.time
+				}
+				if src := entry.prop.source.String(); src != "" {
+					text += " (" + src + ")"
+				}
+				text += "\n"
+			}
+		}
+	}
+	if len(report.uncovered) > 0 {
+		text += "  Uncovered:\n"
+		text += report.uncoveredText("    ")
+	}
+	if len(report.unmatched) > 0 {
+		text += "  Unmatched results: " + strings.Join(report.unmatched, ", ") + "\n"
+	}
+	return text
+}
+
+// The cover properties found to be unreachable, one per line
+func (report *ProofReport) uncoveredText(indent string) string {
+	text := ""
+	for _, entry := range report.uncovered {
+		text += indent + entry.name
+		if src := entry.prop.source.String(); src != "" {
+			text += " (" + src + ")"
+		}
+		text += "\n"
+	}
+	return text
+}
+
+func reportMain(args []string) {
+	flags := flag.NewFlagSet("report", flag.ExitOnError)
+	paths := []string{}
+	flags.Func("path", "paths to source files", func(s string) error {
+		paths = append(paths, s)
+		return nil
+	})
+	includes := []string{}
+	includeFlag(flags, &includes)
+	defines := map[string]string{}
+	definesFlag(flags, defines)
+	svIncludes := []string{}
+	svIncludeFlag(flags, &svIncludes)
+	resultPaths := []string{}
+	resultsFlag(flags, &resultPaths)
+	rootLemma := flags.String("root", "", "name of root lemma")
+	format := flags.String("format", "auto", "format of the result files: jasper, sby, csv or auto")
+	stepPrefix := flags.Bool("step-prefix", false, "properties were generated with -step-prefix")
+	out := flags.String("o", "", "path to write the report to, or empty to print it")
+	uncovered := flags.Bool("uncovered", false, "only list the cover properties which are unreachable")
+	flags.Parse(args)
+
+	if len(paths) == 0 {
+		fmt.Println(fmt.Errorf("error: must specify at least one path"))
+		return
+	}
+	if *rootLemma == "" {
+		fmt.Println(fmt.Errorf("error: must specify a root lemma"))
+		return
+	}
+	if len(resultPaths) == 0 {
+		fmt.Println(fmt.Errorf("error: must specify at least one results file"))
+		return
+	}
+
+	results, err := loadResults(resultPaths, *format)
+	if err != nil {
+		fmt.Println(fmt.Errorf("error: %v", err))
+		return
+	}
+
+	scope, err := loadScope(paths, includes, defines, svIncludes)
+	if err != nil {
+		fmt.Println(err)
+		return
+	}
+	seq := genSequence(&scope, *rootLemma)
+	report := buildReport(&seq, *rootLemma, results, *stepPrefix)
+
+	text := report.toText()
+	if *uncovered {
+		text = report.uncoveredText("")
+	}
+	if *out != "" {
+		os.WriteFile(*out, []byte(text), 0664)
+	} else {
+		fmt.Print(text)
+	}
+	if !report.sound() {
+		fmt.Fprintln(os.Stderr, fmt.Errorf("warning: proof of %s is incomplete: %s", *rootLemma, report.summary()))
+	}
+	if len(report.uncovered) > 0 {
+		fmt.Fprintln(os.Stderr, fmt.Errorf("warning: %d covers of %s are unreachable", len(report.uncovered), *rootLemma))
+	}
+}
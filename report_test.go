@@ -0,0 +1,118 @@
+package main
+
+import (
+	"os"
+	"path/filepath"
+	"slices"
+	"testing"
+)
+
+func TestNormalizeStatus(t *testing.T) {
+	for status, want := range map[string]string{
+		"Proven":      StatusProven,
+		" pass ":      StatusProven,
+		"ar_cex":      StatusCex,
+		"FAIL":        StatusCex,
+		"unreachable": StatusVacuous,
+		"reachable":   StatusCovered,
+		"unknown":     StatusUndetermined,
+		"":            StatusUndetermined,
+	} {
+		if got := normalizeStatus(status); got != want {
+			t.Errorf("status %q normalized to %s, want %s", status, got, want)
+		}
+	}
+}
+
+// Writes a results file under a temporary directory, returning its path
+func testResultsFile(t *testing.T, dir string, name string, data string) string {
+	t.Helper()
+	path := filepath.Join(dir, name)
+	if err := os.MkdirAll(filepath.Dir(path), 0775); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(path, []byte(data), 0664); err != nil {
+		t.Fatal(err)
+	}
+	return path
+}
+
+func TestReadResults(t *testing.T) {
+	dir := t.TempDir()
+	jasper := testResultsFile(t, dir, "report.csv", "# written by jasper\n"+
+		"ID,Name,Result,Engine,Time\n"+
+		"1,<embedded>::top.u_check.P,Proven,N,0.5\n"+
+		"2,<embedded>::top.u_check.Q,cex,Ht,1.2\n")
+	sby := testResultsFile(t, dir, "R/status", "PASS 3\n")
+	simple := testResultsFile(t, dir, "results.txt", "Step3_S, undetermined\nOld, pass, 2\n")
+
+	for _, test := range []struct {
+		path   string
+		format string
+		want   []PropertyResult
+	}{
+		{jasper, "auto", []PropertyResult{{"P", StatusProven, "0.5"}, {"Q", StatusCex, "1.2"}}},
+		{sby, "auto", []PropertyResult{{"R", StatusProven, ""}}},
+		{simple, "auto", []PropertyResult{{"Step3_S", StatusUndetermined, ""}, {"Old", StatusProven, "2"}}},
+		{jasper, "jasper", []PropertyResult{{"P", StatusProven, "0.5"}, {"Q", StatusCex, "1.2"}}},
+	} {
+		results, err := readResults(test.path, test.format)
+		if err != nil || !slices.Equal(results, test.want) {
+			t.Errorf("read %s as %s: %v, %v, want %v", test.path, test.format, results, err, test.want)
+		}
+	}
+
+	if _, err := readResults(simple, "jasper"); err == nil {
+		t.Errorf("read a file without a header as jasper")
+	}
+	if _, err := readResults(simple, "xml"); err == nil {
+		t.Errorf("read an unknown format")
+	}
+}
+
+func TestBuildReport(t *testing.T) {
+	seq := testSequence(t, diamondProof, "dag_example")
+	// Q fails in the left branch, which top assumes but right does not. S only matches with -step-prefix.
+	results := []PropertyResult{
+		{"P", StatusProven, "0.5"},
+		{"Q", StatusCex, ""},
+		{"R", StatusProven, ""},
+		{"Step3_S", StatusProven, ""},
+		{"Old", StatusProven, ""},
+	}
+	report := buildReport(&seq, "dag_example", results, false)
+
+	statuses := []string{}
+	for _, step := range report.steps {
+		for _, lemma := range step.lemmas {
+			for _, entry := range lemma.props {
+				statuses = append(statuses, entry.name+" "+entry.status)
+			}
+		}
+	}
+	if want := []string{"P proven", "Q cex", "R proven", "S proven"}; !slices.Equal(statuses, want) {
+		t.Errorf("statuses %v, want %v", statuses, want)
+	}
+
+	unsound := []int{}
+	for i, step := range report.steps {
+		if len(step.unsound) > 0 {
+			unsound = append(unsound, i)
+		}
+	}
+	if !slices.Equal(unsound, []int{3}) || report.steps[3].unsoundSummary() != "assumes 1 unproven properties from step 1" {
+		t.Errorf("unsound steps %v, want only step 3 assuming Q", unsound)
+	}
+	if report.sound() || report.summary() != "3 proven, 1 cex" {
+		t.Errorf("report is %s, want it unsound with 3 proven and 1 cex", report.summary())
+	}
+	if !slices.Equal(report.unmatched, []string{"Old"}) {
+		t.Errorf("unmatched %v, want [Old]", report.unmatched)
+	}
+
+	// A step which is missing results is unsound for the steps after it too
+	report = buildReport(&seq, "dag_example", results[:1], true)
+	if report.counts[StatusMissing] != 3 || len(report.steps[3].unsound) != 2 || len(report.steps[1].unsound) != 0 {
+		t.Errorf("with only P proven got %s and unsound steps %v", report.summary(), report.steps)
+	}
+}
@@ -0,0 +1,380 @@
+package main
+
+import (
+	"fmt"
+	"math"
+	"os"
+	"slices"
+	"strconv"
+	"strings"
+)
+
+const (
+	BDD_FALSE = 0
+	BDD_TRUE  = 1
+)
+
+type bddNode struct {
+	level int // The variable branched on, math.MaxInt for the constants
+	low   int
+	high  int
+}
+
+// Reduced ordered binary decision diagrams over boolean variables, sharing nodes between every function built
+type BDD struct {
+	nodes  []bddNode
+	unique map[bddNode]int
+	memo   map[[3]int]int
+	vars   []string // The name of each variable, by level
+	levels map[string]int
+	design *SvDesign // Declares which signals are wider than a bit, or nil to take every signal as a single bit
+}
+
+func NewBDD() *BDD {
+	return &BDD{
+		nodes:  []bddNode{{level: math.MaxInt}, {level: math.MaxInt}},
+		unique: map[bddNode]int{},
+		memo:   map[[3]int]int{},
+		vars:   []string{},
+		levels: map[string]int{},
+	}
+}
+
+func (bdd *BDD) node(level int, low int, high int) int {
+	if low == high {
+		return low
+	}
+	key := bddNode{level, low, high}
+	if n, ok := bdd.unique[key]; ok {
+		return n
+	}
+	bdd.nodes = append(bdd.nodes, key)
+	bdd.unique[key] = len(bdd.nodes) - 1
+	return len(bdd.nodes) - 1
+}
+
+func (bdd *BDD) variable(name string) int {
+	level, ok := bdd.levels[name]
+	if !ok {
+		level = len(bdd.vars)
+		bdd.vars = append(bdd.vars, name)
+		bdd.levels[name] = level
+	}
+	return bdd.node(level, BDD_FALSE, BDD_TRUE)
+}
+
+// The cofactors of f when the variable at level is false and true
+func (bdd *BDD) cofactors(f int, level int) (int, int) {
+	if bdd.nodes[f].level != level {
+		return f, f
+	}
+	return bdd.nodes[f].low, bdd.nodes[f].high
+}
+
+// If f then g else h
+func (bdd *BDD) ite(f int, g int, h int) int {
+	switch {
+	case f == BDD_TRUE:
+		return g
+	case f == BDD_FALSE:
+		return h
+	case g == h:
+		return g
+	case g == BDD_TRUE && h == BDD_FALSE:
+		return f
+	}
+	key := [3]int{f, g, h}
+	if n, ok := bdd.memo[key]; ok {
+		return n
+	}
+	level := min(bdd.nodes[f].level, bdd.nodes[g].level, bdd.nodes[h].level)
+	f0, f1 := bdd.cofactors(f, level)
+	g0, g1 := bdd.cofactors(g, level)
+	h0, h1 := bdd.cofactors(h, level)
+	n := bdd.node(level, bdd.ite(f0, g0, h0), bdd.ite(f1, g1, h1))
+	bdd.memo[key] = n
+	return n
+}
+
+func (bdd *BDD) not(f int) int {
+	return bdd.ite(f, BDD_FALSE, BDD_TRUE)
+}
+
+func (bdd *BDD) and(fs ...int) int {
+	result := BDD_TRUE
+	for _, f := range fs {
+		result = bdd.ite(result, f, BDD_FALSE)
+	}
+	return result
+}
+
+func (bdd *BDD) or(fs ...int) int {
+	result := BDD_FALSE
+	for _, f := range fs {
+		result = bdd.ite(result, BDD_TRUE, f)
+	}
+	return result
+}
+
+func (bdd *BDD) xor(f int, g int) int {
+	return bdd.ite(f, bdd.not(g), g)
+}
+
+// When f, which must not be false, holds, as an assignment of name=value pairs satisfying it. Variables it does not
+// depend on are left out.
+func (bdd *BDD) when(f int) string {
+	assignment := []string{}
+	for f != BDD_TRUE {
+		node := bdd.nodes[f]
+		value := "1"
+		f = node.high
+		if node.high == BDD_FALSE {
+			value = "0"
+			f = node.low
+		}
+		assignment = append(assignment, bdd.vars[node.level]+"="+value)
+	}
+	if len(assignment) == 0 {
+		return "always"
+	}
+	return "when " + strings.Join(assignment, " ")
+}
+
+// Splits tokens at every binary use of an operator outside of brackets, that is one following an operand
+func splitBinary(tokens TokenStream, operator string) []TokenStream {
+	parts := []TokenStream{{}}
+	for i, tok := range tokens {
+		if isNameOperator(tok, operator) && i > 0 {
+			if _, ok := tokens[i-1].(*OperatorToken); !ok {
+				parts = append(parts, TokenStream{})
+				continue
+			}
+		}
+		parts[len(parts)-1] = append(parts[len(parts)-1], tok)
+	}
+	return parts
+}
+
+// Whether tokens are a single signal, such as a name, an indexed name or a macro
+func isSignal(tokens TokenStream) bool {
+	if len(tokens) > 0 && isNameOperator(tokens[0], "`") {
+		tokens = tokens[1:]
+	}
+	if len(tokens) == 0 {
+		return false
+	}
+	if _, ok := tokens[0].(*NameToken); !ok {
+		return false
+	}
+	for _, tok := range tokens[1:] {
+		if brack, ok := tok.(*BracketedToken); !ok || brack.openBracket != '[' {
+			return false
+		}
+	}
+	return true
+}
+
+// Whether an expression is a single bit, so that its bitwise operators are also boolean ones
+func (bdd *BDD) isBit(tokens TokenStream) bool {
+	tokens = withoutWhitespace(tokens)
+	if len(tokens) == 0 {
+		return false
+	}
+	if brack, ok := tokens[0].(*BracketedToken); ok && len(tokens) == 1 && brack.openBracket == '(' {
+		return bdd.isBit(brack.content)
+	}
+	// From the loosest binding operator to the tightest
+	for _, op := range []string{"||", "&&"} {
+		if len(splitBinary(tokens, op)) > 1 {
+			return true
+		}
+	}
+	for _, op := range []string{"|", "^", "&"} {
+		if parts := splitBinary(tokens, op); len(parts) > 1 {
+			return !slices.ContainsFunc(parts, func(part TokenStream) bool { return !bdd.isBit(part) })
+		}
+	}
+	if slices.ContainsFunc(tokens, func(tok Token) bool {
+		return isNameOperator(tok, "=") || isNameOperator(tok, "<") || isNameOperator(tok, ">")
+	}) {
+		return true
+	}
+	if isNameOperator(tokens[0], "!") {
+		return true
+	}
+	if isNameOperator(tokens[0], "~") {
+		return bdd.isBit(tokens[1:])
+	}
+	if num, ok := tokens[0].(*NumToken); ok && len(tokens) == 1 {
+		return strings.HasPrefix(num.num, "1'")
+	}
+	if isSignal(tokens) {
+		if index, ok := tokens[len(tokens)-1].(*BracketedToken); ok {
+			// A single bit of a vector, rather than a range
+			return !slices.ContainsFunc(index.content, func(tok Token) bool { return isNameOperator(tok, ":") })
+		}
+		name, ok := tokens[0].(*NameToken)
+		return !ok || bdd.design == nil || bdd.design.singleBit(name.content)
+	}
+	return false
+}
+
+// Builds the function of an expression, treating each signal as a single bit unless the design declares it wider.
+// Anything else which is not a boolean operator, such as a comparison or a bitwise operator on a vector, is an opaque
+// variable, which makes the expression not purely boolean.
+func (bdd *BDD) build(stream TokenStream) (int, bool) {
+	tokens := withoutWhitespace(stream)
+	opaque := func() (int, bool) {
+		return bdd.variable(normalizedStream(tokens)), false
+	}
+	for _, tok := range tokens {
+		if name, ok := tok.(*NameToken); ok && slices.Contains(svExpressionKeywords, name.content) {
+			return opaque()
+		}
+		if op, ok := tok.(*OperatorToken); ok && (strings.ContainsAny(op.operator, "?#") || strings.Contains(op.operator, "->") || op.operator == "|=>") {
+			return opaque()
+		}
+	}
+	if len(tokens) == 0 {
+		return opaque()
+	}
+
+	// From the loosest binding operator to the tightest
+	for _, op := range []string{"||", "&&", "|", "^", "&"} {
+		parts := splitBinary(tokens, op)
+		if len(parts) == 1 {
+			continue
+		}
+		// Only the bitwise and and xor of single bits are the boolean ones, while or of any width is
+		if (op == "&" || op == "^") && !bdd.isBit(tokens) {
+			return opaque()
+		}
+		fs := []int{}
+		boolean := true
+		for _, part := range parts {
+			f, ok := bdd.build(part)
+			fs = append(fs, f)
+			boolean = boolean && ok
+		}
+		switch op {
+		case "||", "|":
+			return bdd.or(fs...), boolean
+		case "&&", "&":
+			return bdd.and(fs...), boolean
+		default:
+			f := fs[0]
+			for _, g := range fs[1:] {
+				f = bdd.xor(f, g)
+			}
+			return f, boolean
+		}
+	}
+
+	if isNameOperator(tokens[0], "!") || isNameOperator(tokens[0], "~") {
+		// ~ of an unsized constant inverts all 32 of its bits, so is never zero
+		if num, ok := tokens[len(tokens)-1].(*NumToken); ok && len(tokens) == 2 && tokens[0].(*OperatorToken).operator == "~" && !strings.Contains(num.num, "'") {
+			return BDD_TRUE, true
+		}
+		if tokens[0].(*OperatorToken).operator == "~" && !bdd.isBit(tokens[1:]) {
+			return opaque()
+		}
+		f, ok := bdd.build(tokens[1:])
+		return bdd.not(f), ok
+	}
+	if brack, ok := tokens[0].(*BracketedToken); ok && len(tokens) == 1 && brack.openBracket == '(' {
+		return bdd.build(brack.content)
+	}
+	if num, ok := tokens[0].(*NumToken); ok && len(tokens) == 1 {
+		value := num.num
+		if _, sized, ok := strings.Cut(num.num, "'"); ok {
+			if !strings.HasPrefix(sized, "b") && !strings.HasPrefix(sized, "d") {
+				return opaque()
+			}
+			value = sized[1:]
+		}
+		n, err := strconv.ParseInt(strings.ReplaceAll(value, "_", ""), 10, 64)
+		if err != nil {
+			return opaque()
+		}
+		if n == 0 {
+			return BDD_FALSE, true
+		}
+		return BDD_TRUE, true
+	}
+	if isSignal(tokens) {
+		return bdd.variable(normalizedStream(tokens)), true
+	}
+	return opaque()
+}
+
+// A BDD for the checks of a scope, knowing the widths of the signals of any design under test
+func (scope *Scope) newBDD() *BDD {
+	bdd := NewBDD()
+	bdd.design = scope.env.design
+	return bdd
+}
+
+// Warns once per message within an environment, recording where the warning was found
+func (scope *Scope) warnOnce(source Source, err error) {
+	if !scope.env.warned[err.Error()] {
+		scope.env.warned[err.Error()] = true
+		scope.env.warnings = append(scope.env.warnings, Warning{source: source, err: err})
+		if !scope.env.quiet {
+			fmt.Fprintln(os.Stderr, err)
+		}
+	}
+}
+
+// The conditions properties are under at this point, including those of the split cases being helped
+func (scope *Scope) assumptions() []TokenStream {
+	return append(scope.getPreConditions(), scope.assumed...)
+}
+
+// Checks that, under the current conditions, one of some purely boolean conditions always holds
+func (scope *Scope) checkCovers(conds []TokenStream, what string, source Source) {
+	bdd := scope.newBDD()
+	pre, _ := bdd.build(conjoin(scope.assumptions()))
+	fs := []int{}
+	for _, cond := range conds {
+		f, boolean := bdd.build(cond)
+		if !boolean {
+			return
+		}
+		fs = append(fs, f)
+	}
+	if uncovered := bdd.and(pre, bdd.not(bdd.or(fs...))); uncovered != BDD_FALSE {
+		scope.warnOnce(source, fmt.Errorf("warning: %s: %s do not cover every case, none hold %s", source, what, bdd.when(uncovered)))
+	}
+}
+
+// Checks that, under the current conditions, no two of some purely boolean conditions hold at once
+func (scope *Scope) checkDisjoint(names []string, conds []TokenStream, what string, source Source) {
+	bdd := scope.newBDD()
+	pre, _ := bdd.build(conjoin(scope.assumptions()))
+	fs := make([]int, len(conds))
+	boolean := make([]bool, len(conds))
+	for i, cond := range conds {
+		fs[i], boolean[i] = bdd.build(cond)
+	}
+	for i := range conds {
+		for j := i + 1; j < len(conds); j++ {
+			if !boolean[i] || !boolean[j] {
+				continue
+			}
+			if both := bdd.and(pre, fs[i], fs[j]); both != BDD_FALSE {
+				scope.warnOnce(source, fmt.Errorf("warning: %s: %s %s and %s both hold %s", source, what, names[i], names[j], bdd.when(both)))
+			}
+		}
+	}
+}
+
+// Checks that a negated condition is the complement of the condition
+func (scope *Scope) checkComplement(cond TokenStream, negated TokenStream, source Source) {
+	bdd := scope.newBDD()
+	f, _ := bdd.build(cond)
+	g, _ := bdd.build(negated)
+	if same := bdd.not(bdd.xor(f, g)); same != BDD_FALSE {
+		scope.warnOnce(source, fmt.Errorf("warning: %s: %s is not the negation of %s, both have the same value %s",
+			source, streamToString(trimWhitespace(negated)), streamToString(trimWhitespace(cond)), bdd.when(same)))
+	}
+}
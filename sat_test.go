@@ -0,0 +1,189 @@
+package main
+
+import (
+	"maps"
+	"slices"
+	"strings"
+	"testing"
+)
+
+func testStream(text string) TokenStream {
+	_, stream := tokenize(text)
+	return stream
+}
+
+func TestBDD(t *testing.T) {
+	bdd := NewBDD()
+	a, b, c := bdd.variable("a"), bdd.variable("b"), bdd.variable("c")
+	if bdd.variable("a") != a {
+		t.Errorf("a variable is not shared")
+	}
+	for _, test := range []struct {
+		name string
+		f    int
+		g    int
+	}{
+		{"ite(a, b, b)", bdd.ite(a, b, b), b},
+		{"ite(a, 1, 0)", bdd.ite(a, BDD_TRUE, BDD_FALSE), a},
+		{"ite(a, b, c)", bdd.ite(a, b, c), bdd.or(bdd.and(a, b), bdd.and(bdd.not(a), c))},
+		{"a && ~a", bdd.and(a, bdd.not(a)), BDD_FALSE},
+		{"a || ~a", bdd.or(a, bdd.not(a)), BDD_TRUE},
+		{"~~a", bdd.not(bdd.not(a)), a},
+		{"and()", bdd.and(), BDD_TRUE},
+		{"or()", bdd.or(), BDD_FALSE},
+		{"de Morgan", bdd.not(bdd.and(a, b)), bdd.or(bdd.not(a), bdd.not(b))},
+		{"a ^ a", bdd.xor(a, a), BDD_FALSE},
+		{"a ^ b ^ b", bdd.xor(bdd.xor(a, b), b), a},
+		{"order of and", bdd.and(c, b, a), bdd.and(a, b, c)},
+	} {
+		if test.f != test.g {
+			t.Errorf("%s is node %d, want %d", test.name, test.f, test.g)
+		}
+	}
+
+	for _, test := range []struct {
+		f    int
+		when string
+	}{
+		{BDD_TRUE, "always"},
+		{a, "when a=1"},
+		{bdd.not(a), "when a=0"},
+		{bdd.and(a, bdd.not(c)), "when a=1 c=0"},
+		{bdd.or(b, c), "when b=1"},
+	} {
+		if when := bdd.when(test.f); when != test.when {
+			t.Errorf("when(%d) = %q, want %q", test.f, when, test.when)
+		}
+	}
+}
+
+func TestBDDBuild(t *testing.T) {
+	design := &SvDesign{modules: map[string]*SvModule{}, globals: map[string]bool{}}
+	design.addSource("module top(input logic a, b, input logic [1:0] state); logic [3:0] v; endmodule")
+	design.top = design.modules["top"]
+
+	for _, test := range []struct {
+		expr    string
+		design  bool
+		same    string // An expression of the same function, or empty for none
+		boolean bool
+	}{
+		{"a && b", false, "~(~a || ~b)", true},
+		{"a & b", false, "b && a", true},
+		{"a ^ b", false, "a && ~b || ~a && b", true},
+		{"!(a | b)", false, "~a & ~b", true},
+		{"(a)", false, "a", true},
+		{"a && 1", false, "a", true},
+		{"a || 4'b0", false, "a", true},
+		{"~0", false, "1", true},
+		{"state == 2'b01", false, "", false},
+		{"a == b", false, "", false},
+		{"a ##1 b", false, "", false},
+		{"a |-> b", false, "", false},
+		{"state[0] & a", true, "a && state[0]", true},
+		{"~state", false, "!state", true},
+		{"~state", true, "", false},
+		{"!state", true, "!(state)", true},
+		{"state & a", true, "", false},
+		{"state | a", true, "a || state", true},
+		{"~(state == 2'b01)", true, "!(state == 2'b01)", false},
+		{"~v[3:2]", true, "", false},
+	} {
+		bdd := NewBDD()
+		if test.design {
+			bdd.design = design
+		}
+		f, boolean := bdd.build(testStream(test.expr))
+		if boolean != test.boolean {
+			t.Errorf("%s is boolean %v, want %v", test.expr, boolean, test.boolean)
+		}
+		if test.same == "" {
+			continue
+		}
+		if g, _ := bdd.build(testStream(test.same)); f != g {
+			t.Errorf("%s is not the same as %s", test.expr, test.same)
+		}
+	}
+}
+
+// The warnings given by a check, which is given a scope under some conditions
+func testChecks(t *testing.T, design *SvDesign, conds []string, check func(scope *Scope)) []string {
+	t.Helper()
+	doc := NewProofDocument()
+	scope := NewScope(&doc)
+	scope.env.design = design
+	local := &LocalScope{states: map[string]TokenStream{}}
+	for _, cond := range conds {
+		local.conditions = append(local.conditions, testStream(cond))
+	}
+	scope.push(local)
+	check(&scope)
+	return slices.Sorted(maps.Keys(scope.env.warned))
+}
+
+func TestChecks(t *testing.T) {
+	design := &SvDesign{modules: map[string]*SvModule{}, globals: map[string]bool{}}
+	design.addSource("module top(input logic a, b, c, input logic [1:0] state); endmodule")
+	design.top = design.modules["top"]
+	source := Source{file: "t.proof", line: 3}
+	streams := func(exprs ...string) []TokenStream {
+		conds := []TokenStream{}
+		for _, expr := range exprs {
+			conds = append(conds, testStream(expr))
+		}
+		return conds
+	}
+
+	for _, test := range []struct {
+		name   string
+		design *SvDesign
+		conds  []string
+		check  func(scope *Scope)
+		want   []string
+	}{
+		{"covered", nil, nil, func(scope *Scope) {
+			scope.checkCovers(streams("a", "~a && b", "~a && ~b"), "split cases", source)
+		}, []string{}},
+		{"uncovered", nil, nil, func(scope *Scope) {
+			scope.checkCovers(streams("a && b", "~a"), "split cases", source)
+		}, []string{"warning: t.proof:3: split cases do not cover every case, none hold when a=1 b=0"}},
+		{"covered under conditions", nil, []string{"a"}, func(scope *Scope) {
+			scope.checkCovers(streams("a && b", "~b"), "split cases", source)
+		}, []string{}},
+		{"opaque cases are not checked", nil, nil, func(scope *Scope) {
+			scope.checkCovers(streams("state == 2'b01", "a"), "split cases", source)
+		}, []string{}},
+		{"disjoint", nil, nil, func(scope *Scope) {
+			scope.checkDisjoint([]string{"x", "y", "z"}, streams("a && b", "a && ~b", "~a"), "graph nodes", source)
+		}, []string{}},
+		{"not disjoint", nil, nil, func(scope *Scope) {
+			scope.checkDisjoint([]string{"x", "y", "z"}, streams("a", "b", "~a && ~b"), "graph nodes", source)
+		}, []string{"warning: t.proof:3: graph nodes x and y both hold when a=1 b=1"}},
+		{"disjoint under conditions", nil, []string{"~b"}, func(scope *Scope) {
+			scope.checkDisjoint([]string{"x", "y"}, streams("a", "b"), "graph nodes", source)
+		}, []string{}},
+		{"opaque nodes are not checked", nil, nil, func(scope *Scope) {
+			scope.checkDisjoint([]string{"x", "y"}, streams("state == 2'b01", "state == 2'b10"), "graph nodes", source)
+		}, []string{}},
+		{"vectors are not taken as bits", design, nil, func(scope *Scope) {
+			scope.checkDisjoint([]string{"x", "y"}, streams("state & a", "~state & a"), "graph nodes", source)
+		}, []string{}},
+		{"complement", nil, nil, func(scope *Scope) {
+			scope.checkComplement(testStream("a && b"), negate(testStream("a && b")), source)
+		}, []string{}},
+		{"not complement", nil, nil, func(scope *Scope) {
+			scope.checkComplement(testStream("a && b"), testStream("~a && ~b"), source)
+		}, []string{"warning: t.proof:3: ~a && ~b is not the negation of a && b, both have the same value when a=1 b=0"}},
+		{"complement of a comparison", nil, nil, func(scope *Scope) {
+			scope.checkComplement(testStream("state == 2'b01"), negate(testStream("state == 2'b01")), source)
+		}, []string{}},
+		{"complement of a vector", design, nil, func(scope *Scope) {
+			scope.checkComplement(testStream("state"), negate(testStream("state")), source)
+		}, []string{"warning: t.proof:3: ~state is not the negation of state, both have the same value when state=1 ~state=1"}},
+	} {
+		warnings := testChecks(t, test.design, test.conds, test.check)
+		if !slices.Equal(warnings, test.want) {
+			t.Errorf("%s: warned %q, want %q", test.name, strings.Join(warnings, "\n"), test.want)
+		}
+	}
+}
@@ -0,0 +1,107 @@
+package main
+
+import (
+	"fmt"
+	"os"
+	"regexp"
+	"strings"
+)
+
+// Converts a glob to a regular expression, where * and ? do not match across a / and ** matches anything
+func globToRegexp(glob string) string {
+	re := ""
+	for i := 0; i < len(glob); i++ {
+		switch {
+		case strings.HasPrefix(glob[i:], "/**"):
+			re += "(/.*)?"
+			i += 2
+		case strings.HasPrefix(glob[i:], "**"):
+			re += ".*"
+			i += 1
+		case glob[i] == '*':
+			re += "[^/]*"
+		case glob[i] == '?':
+			re += "[^/]"
+		default:
+			re += regexp.QuoteMeta(glob[i : i+1])
+		}
+	}
+	return "^" + re + "$"
+}
+
+// A pattern is a glob, or a regular expression if it starts with re:
+func compilePattern(pattern string) (*regexp.Regexp, error) {
+	if re, ok := strings.CutPrefix(pattern, "re:"); ok {
+		return regexp.Compile(re)
+	}
+	return regexp.Compile(globToRegexp(pattern))
+}
+
+func patternFlag(patterns *[]*regexp.Regexp) func(string) error {
+	return func(s string) error {
+		re, err := compilePattern(s)
+		if err != nil {
+			return err
+		}
+		*patterns = append(*patterns, re)
+		return nil
+	}
+}
+
+// The labels of a property separated by /, which unlike its name keeps the labels apart
+func (prop *Property) labelPath() string {
+	return strings.Join(prop.labels, "/")
+}
+
+func (prop *Property) matchesAny(patterns []*regexp.Regexp) bool {
+	for _, re := range patterns {
+		if re.MatchString(prop.name) || re.MatchString(prop.labelPath()) {
+			return true
+		}
+	}
+	return false
+}
+
+// A sequence of the properties matching any of only (or all if there are none) and none of exclude,
+// along with the properties they assume, which are kept as assumptions
+func (seq *FlatProofSequence) selectProps(only []*regexp.Regexp, exclude []*regexp.Regexp) FlatProofSequence {
+	selected := map[*Property]bool{}
+	for _, step := range seq.props {
+		for _, prop := range step {
+			if (len(only) == 0 || prop.matchesAny(only)) && !prop.matchesAny(exclude) {
+				selected[prop] = true
+			}
+		}
+	}
+	if len(selected) == 0 {
+		fmt.Fprintln(os.Stderr, fmt.Errorf("warning: no properties are selected"))
+	}
+
+	assumed := map[*Property]bool{}
+	for i, step := range seq.props {
+		for _, prop := range step {
+			if selected[prop] {
+				for _, other := range seq.propAssumes(i, prop) {
+					assumed[other] = !selected[other]
+				}
+			}
+		}
+	}
+
+	result := *seq
+	result.props = [][]*Property{}
+	for _, step := range seq.props {
+		props := []*Property{}
+		for _, prop := range step {
+			if selected[prop] {
+				props = append(props, prop)
+			} else if assumed[prop] {
+				kept := prop.copy().(*Property)
+				kept.assumed = true
+				props = append(props, kept)
+			}
+		}
+		result.props = append(result.props, props)
+	}
+	return result
+}
@@ -0,0 +1,99 @@
+package main
+
+import (
+	"regexp"
+	"slices"
+	"strings"
+	"testing"
+)
+
+func TestGlobToRegexp(t *testing.T) {
+	for _, test := range []struct {
+		glob    string
+		matches []string
+		misses  []string
+	}{
+		{"Blk/*", []string{"Blk/X", "Blk/"}, []string{"Blk/X/Y", "Blk", "Blk2/X"}},
+		{"Blk/**", []string{"Blk", "Blk/X", "Blk/X/Y"}, []string{"Blk2/X"}},
+		{"**/X", []string{"Blk/X", "A/B/X", "/X"}, []string{"X", "Blk/XY"}},
+		{"Blk?_X", []string{"Blk2_X"}, []string{"Blk_X", "Blk/_X"}},
+		{"a.b+c", []string{"a.b+c"}, []string{"axb+c", "a.bbc"}},
+	} {
+		re := regexp.MustCompile(globToRegexp(test.glob))
+		for _, name := range test.matches {
+			if !re.MatchString(name) {
+				t.Errorf("%s does not match %s", test.glob, name)
+			}
+		}
+		for _, name := range test.misses {
+			if re.MatchString(name) {
+				t.Errorf("%s matches %s", test.glob, name)
+			}
+		}
+	}
+	if _, err := compilePattern("re:(unclosed"); err == nil {
+		t.Errorf("compiled a bad regular expression")
+	}
+}
+
+const selectProof = `
+lemma l
+  A: have (a)
+  Blk: block
+    X: have (x)
+    Y: have (y)
+  /
+  Blk2: block
+    X: have (x2)
+  C: have (c) using A
+`
+
+func TestSelectProps(t *testing.T) {
+	base := testSequence(t, selectProof, "l")
+	for _, test := range []struct {
+		only    []string
+		exclude []string
+		want    string // Each step's properties, those kept as assumptions in brackets
+	}{
+		{nil, nil, "A Blk_X Blk_Y / Blk2_X C"},
+		// Label paths keep the labels apart, while names join them
+		{[]string{"Blk2/*"}, nil, "(A) (Blk_X) (Blk_Y) / Blk2_X"},
+		{[]string{"Blk*_X"}, nil, "(A) Blk_X (Blk_Y) / Blk2_X"},
+		{[]string{"re:^Blk2?_X$"}, nil, "(A) Blk_X (Blk_Y) / Blk2_X"},
+		// Only the properties assumed through using are kept
+		{[]string{"C"}, nil, "(A) / C"},
+		// Excluded properties are still assumed by the selected properties after them
+		{nil, []string{"Blk/**"}, "A (Blk_X) (Blk_Y) / Blk2_X C"},
+		{[]string{"**"}, []string{"re:."}, " / "},
+	} {
+		only, exclude := []*regexp.Regexp{}, []*regexp.Regexp{}
+		for _, pattern := range test.only {
+			patternFlag(&only)(pattern)
+		}
+		for _, pattern := range test.exclude {
+			patternFlag(&exclude)(pattern)
+		}
+		seq := base.selectProps(only, exclude)
+		steps := []string{}
+		for _, step := range seq.props {
+			names := []string{}
+			for _, prop := range step {
+				if prop.assumed {
+					names = append(names, "("+prop.name+")")
+				} else {
+					names = append(names, prop.name)
+				}
+			}
+			steps = append(steps, strings.Join(names, " "))
+		}
+		if got := strings.Join(steps, " / "); got != test.want {
+			t.Errorf("with -only %v -exclude %v got %q, want %q", test.only, test.exclude, got, test.want)
+		}
+	}
+
+	// Selection copies the properties it keeps as assumptions, leaving the full sequence as it was
+	base.selectProps([]*regexp.Regexp{regexp.MustCompile("^C$")}, nil)
+	if slices.ContainsFunc(base.props[0], func(prop *Property) bool { return prop.assumed }) {
+		t.Errorf("selecting changed the full sequence")
+	}
+}
@@ -0,0 +1,134 @@
+package main
+
+import (
+	"slices"
+	"strconv"
+	"strings"
+)
+
+// Parses a runtime from a results file in seconds, either a number with an optional unit or h:m:s
+func resultSeconds(time string) (float64, bool) {
+	time = strings.TrimSpace(time)
+	if time == "" {
+		return 0, false
+	}
+	seconds := 0.0
+	for _, part := range strings.Split(time, ":") {
+		value, err := strconv.ParseFloat(strings.TrimRight(strings.TrimSpace(part), "smh "), 64)
+		if err != nil {
+			return 0, false
+		}
+		seconds = seconds*60 + value
+	}
+	return seconds, true
+}
+
+// The size of a property's conditions, as a stand-in for how hard it is to prove
+func (prop *Property) size() float64 {
+	size := len(prop.postCondition)
+	for _, pre := range prop.preConditions {
+		size += len(pre)
+	}
+	return float64(size)
+}
+
+// The runtime of each property in previous results by name, matching names generated with or without -step-prefix
+func (seq *FlatProofSequence) runtimes(results []PropertyResult) map[*Property]float64 {
+	times := map[string]float64{}
+	for _, result := range results {
+		if seconds, ok := resultSeconds(result.time); ok {
+			times[result.name] = seconds
+		}
+	}
+
+	runtimes := map[*Property]float64{}
+	for i, step := range seq.props {
+		for _, prop := range step {
+			if seconds, ok := times[prop.svaName(false, i)]; ok {
+				runtimes[prop] = seconds
+			} else if seconds, ok := times[prop.svaName(true, i)]; ok {
+				runtimes[prop] = seconds
+			}
+		}
+	}
+	return runtimes
+}
+
+// Splits each step's asserted properties into up to k shards of similar total weight, which is their runtime if known.
+// Otherwise it is their size, scaled to runtimes by the properties with both when there are any
+func (seq *FlatProofSequence) shard(k int, runtimes map[*Property]float64) {
+	scale := 1.0
+	if len(runtimes) > 0 {
+		sizes, times := 0.0, 0.0
+		for prop, seconds := range runtimes {
+			sizes += prop.size()
+			times += seconds
+		}
+		if sizes > 0 {
+			scale = times / sizes
+		}
+	}
+	weight := func(prop *Property) float64 {
+		if seconds, ok := runtimes[prop]; ok {
+			return seconds
+		}
+		return prop.size() * scale
+	}
+
+	for _, step := range seq.props {
+		props := []*Property{}
+		for _, prop := range step {
+			if !prop.assumed {
+				props = append(props, prop)
+			}
+		}
+
+		// Heaviest first, each onto the lightest shard so far
+		slices.SortStableFunc(props, func(a *Property, b *Property) int {
+			wa, wb := weight(a), weight(b)
+			if wa > wb {
+				return -1
+			} else if wa < wb {
+				return 1
+			}
+			return 0
+		})
+		loads := make([]float64, min(k, len(props)))
+		for _, prop := range props {
+			lightest := 0
+			for s := range loads {
+				if loads[s] < loads[lightest] {
+					lightest = s
+				}
+			}
+			prop.shard = lightest
+			loads[lightest] += weight(prop)
+		}
+	}
+}
+
+// The number of shards a step's properties are split into
+func (seq *FlatProofSequence) shardCount(i int) int {
+	count := 1
+	for _, prop := range seq.props[i] {
+		count = max(count, prop.shard+1)
+	}
+	return count
+}
+
+// A step's properties in each of its shards, in their original order
+func (seq *FlatProofSequence) shardsOf(i int, props []*Property) [][]*Property {
+	shards := make([][]*Property, seq.shardCount(i))
+	for _, prop := range props {
+		shards[prop.shard] = append(shards[prop.shard], prop)
+	}
+	return shards
+}
+
+// The name of a shard of a step's task, plain if the step is not sharded
+func (seq *FlatProofSequence) shardName(name string, i int, shard int) string {
+	if seq.shardCount(i) == 1 {
+		return name
+	}
+	return name + "_Shard" + strconv.Itoa(shard)
+}
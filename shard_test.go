@@ -0,0 +1,108 @@
+package main
+
+import (
+	"slices"
+	"strings"
+	"testing"
+)
+
+const shardProof = `
+lemma l
+  A: have (a)
+  B: have (b)
+  /
+  C: have (c1 && c2 && c3 && c4 && c5)
+  D: have (d)
+  E: have (e)
+  F: have (f1 && f2)
+  G: have (g)
+  H: have (h)
+`
+
+func TestResultSeconds(t *testing.T) {
+	for time, want := range map[string]float64{"12": 12, "2.5s": 2.5, "1:30": 90, "1:00:01": 3601} {
+		if seconds, ok := resultSeconds(time); !ok || seconds != want {
+			t.Errorf("%q is %v seconds, want %v", time, seconds, want)
+		}
+	}
+	for _, time := range []string{"", "fast"} {
+		if _, ok := resultSeconds(time); ok {
+			t.Errorf("%q parsed as a runtime", time)
+		}
+	}
+}
+
+func TestShardBalance(t *testing.T) {
+	// By size C outweighs the rest, so goes alone. Given runtimes D does instead, and the others are scaled to them.
+	for _, test := range []struct {
+		results []PropertyResult
+		want    [][]string
+	}{
+		{nil, [][]string{{"C"}, {"D", "E", "F", "G", "H"}}},
+		{[]PropertyResult{{"D", StatusProven, "100"}, {"C", StatusProven, "1"}}, [][]string{{"D"}, {"C", "E", "F", "G", "H"}}},
+		{[]PropertyResult{{"Step1_D", StatusProven, "100"}, {"C", StatusProven, "1"}}, [][]string{{"D"}, {"C", "E", "F", "G", "H"}}},
+	} {
+		seq := testSequence(t, shardProof, "l")
+		seq.shard(2, seq.runtimes(test.results))
+		got := [][]string{}
+		for _, shard := range seq.shardsOf(1, seq.props[1]) {
+			names := []string{}
+			for _, prop := range shard {
+				names = append(names, prop.name)
+			}
+			got = append(got, names)
+		}
+		if !slices.EqualFunc(got, test.want, slices.Equal) {
+			t.Errorf("with results %v shards are %v, want %v", test.results, got, test.want)
+		}
+	}
+
+	// Never more shards than properties
+	seq := testSequence(t, shardProof, "l")
+	seq.shard(4, nil)
+	if seq.shardCount(0) != 2 || seq.shardCount(1) != 4 {
+		t.Errorf("4 shards of steps of 2 and 6 properties gave %d and %d shards", seq.shardCount(0), seq.shardCount(1))
+	}
+	if seq.shardName("Step0", 0, 1) != "Step0_Shard1" {
+		t.Errorf("shard named %s, want Step0_Shard1", seq.shardName("Step0", 0, 1))
+	}
+
+	// The shards other than C's differ by no more than the weight of the lightest property
+	seq = testSequence(t, shardProof, "l")
+	seq.shard(3, nil)
+	loads := []float64{}
+	for _, shard := range seq.shardsOf(1, seq.props[1]) {
+		load := 0.0
+		for _, prop := range shard {
+			load += prop.size()
+		}
+		loads = append(loads, load)
+	}
+	if slices.Max(loads[1:])-slices.Min(loads[1:]) > 1 {
+		t.Errorf("shards of the light properties weigh %v, want them balanced", loads)
+	}
+}
+
+func TestShardTasks(t *testing.T) {
+	seq := testSequence(t, shardProof, "l")
+	seq.shard(3, nil)
+	tasks := seq.toTasks()
+
+	// Every shard of a step assumes the whole of the steps before it
+	for _, name := range []string{"Step0_Shard0", "Step0_Shard1", "Step1_Shard0", "Step1_Shard1", "Step1_Shard2"} {
+		if !strings.Contains(tasks, "task -create "+name+" ") {
+			t.Errorf("no task %s in:\n%s", name, tasks)
+		}
+	}
+	assumes := taskAssumptions(tasks)
+	for _, prop := range seq.props[1] {
+		if !slices.Equal(assumes[prop.name], []string{"A", "B"}) {
+			t.Errorf("%s assumes %v, want [A B]:\n%s", prop.name, assumes[prop.name], tasks)
+		}
+	}
+	for _, prop := range seq.props[0] {
+		if len(assumes[prop.name]) != 0 {
+			t.Errorf("%s assumes %v, want nothing", prop.name, assumes[prop.name])
+		}
+	}
+}
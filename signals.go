@@ -0,0 +1,588 @@
+package main
+
+import (
+	"fmt"
+	"maps"
+	"os"
+	"slices"
+	"strings"
+)
+
+// The names declared within a SystemVerilog module or interface
+type SvModule struct {
+	name      string
+	signals   map[string]bool   // Ports, variables, nets and parameters
+	bits      map[string]bool   // Those declared as a single bit
+	instances map[string]string // The module of each instance
+	blocks    map[string]bool   // Labelled generate blocks, whose contents are not checked
+}
+
+func NewSvModule(name string) *SvModule {
+	return &SvModule{
+		name:      name,
+		signals:   map[string]bool{},
+		bits:      map[string]bool{},
+		instances: map[string]string{},
+		blocks:    map[string]bool{},
+	}
+}
+
+// The modules of a design, and the names visible anywhere within it such as enum constants and package parameters
+type SvDesign struct {
+	modules map[string]*SvModule
+	globals map[string]bool
+	top     *SvModule
+}
+
+var svDeclarationKeywords = []string{
+	"input", "output", "inout", "ref", "logic", "wire", "reg", "bit", "byte", "int", "integer", "shortint", "longint",
+	"var", "tri", "wand", "wor", "parameter", "localparam", "genvar", "signed", "unsigned", "const", "static",
+	"automatic", "real", "time", "string", "enum",
+}
+
+// Words of declarations of a single bit, when nothing else such as a dimension is given
+var svBitKeywords = []string{
+	"input", "output", "inout", "ref", "logic", "wire", "reg", "bit", "var", "tri", "wand", "wor", "signed", "unsigned",
+	"const", "static", "automatic",
+}
+
+// Words which are not declarations or instances when they start a statement
+var svStatementKeywords = []string{
+	"assign", "always", "always_ff", "always_comb", "always_latch", "initial", "final", "if", "else", "for", "foreach",
+	"while", "do", "repeat", "case", "casez", "casex", "unique", "unique0", "priority", "function", "task", "return",
+	"assert", "assume", "cover", "restrict", "property", "sequence", "import", "export", "typedef", "default",
+	"modport", "clocking", "bind", "break", "continue",
+}
+
+// Words ending blocks, which also end any statement before them
+var svEndKeywords = []string{
+	"end", "endcase", "endfunction", "endtask", "endgenerate", "endclocking", "endproperty", "endsequence", "endgroup",
+	"endchecker", "endspecify",
+}
+
+// Words of SystemVerilog expressions and properties which are not signals
+var svExpressionKeywords = []string{
+	"or", "and", "not", "iff", "throughout", "within", "intersect", "until", "s_until", "until_with", "s_until_with",
+	"implies", "first_match", "inside", "dist", "posedge", "negedge", "edge", "if", "else", "case", "strong", "weak",
+	"nexttime", "s_nexttime", "always", "s_always", "eventually", "s_eventually", "accept_on", "reject_on",
+	"sync_accept_on", "sync_reject_on", "disable", "null",
+}
+
+// Splits SystemVerilog into identifiers, numbers, strings, macro uses, :: and single characters. Compiler directives
+// are dropped, along with comments.
+func svLex(text string) []string {
+	lines := []string{}
+	continued := false
+	for _, line := range strings.Split(stripSvCommentText(text), "\n") {
+		if continued || strings.HasPrefix(strings.TrimSpace(line), "`") {
+			continued = strings.HasSuffix(strings.TrimSpace(line), "\\")
+			continue
+		}
+		lines = append(lines, line)
+	}
+	text = strings.Join(lines, "\n")
+
+	tokens := []string{}
+	for i := 0; i < len(text); {
+		c := text[i]
+		start := i
+		switch {
+		case isWhitespace(c):
+			i += 1
+			continue
+		case c == '"':
+			i += 1
+			for i < len(text) && text[i] != '"' {
+				if text[i] == '\\' {
+					i += 1
+				}
+				i += 1
+			}
+			i += 1
+		case c == '`' || isIdentStart(c):
+			i += 1
+			for i < len(text) && (isIdentStart(text[i]) || isNum(text[i])) {
+				i += 1
+			}
+		case isNum(c) || c == '\'':
+			i += 1
+			for i < len(text) && (isHexStep(text[i]) || strings.IndexByte("'sSdDbBoOhHxXzZ?", text[i]) != -1) {
+				i += 1
+			}
+		case strings.HasPrefix(text[i:], "::"):
+			i += 2
+		default:
+			i += 1
+		}
+		tokens = append(tokens, text[start:min(i, len(text))])
+	}
+	return tokens
+}
+
+func isSvIdent(tok string) bool {
+	return tok != "" && isIdentStart(tok[0])
+}
+
+// The index after the bracket closing the one at i
+func svClose(tokens []string, i int) int {
+	depth := 0
+	for ; i < len(tokens); i++ {
+		switch tokens[i] {
+		case "(", "[", "{":
+			depth += 1
+		case ")", "]", "}":
+			depth -= 1
+			if depth == 0 {
+				return i + 1
+			}
+		}
+	}
+	return i
+}
+
+// Splits tokens at a separator outside of any brackets
+func svSplit(tokens []string, sep string) [][]string {
+	parts := [][]string{{}}
+	for i := 0; i < len(tokens); i++ {
+		if tokens[i] == "(" || tokens[i] == "[" || tokens[i] == "{" {
+			end := svClose(tokens, i)
+			parts[len(parts)-1] = append(parts[len(parts)-1], tokens[i:end]...)
+			i = end - 1
+		} else if tokens[i] == sep {
+			parts = append(parts, []string{})
+		} else {
+			parts[len(parts)-1] = append(parts[len(parts)-1], tokens[i])
+		}
+	}
+	return parts
+}
+
+// The name declared by each part of a declaration, the last identifier outside brackets before any initial value, and
+// whether it is a single bit. A part with nothing before its name has the type of the part before it.
+func svDeclaredNames(tokens []string) ([]string, []bool) {
+	names := []string{}
+	bits := []bool{}
+	bit := false
+	for _, part := range svSplit(tokens, ",") {
+		name, at := "", -1
+		dimensions := []int{}
+		for i := 0; i < len(part) && part[i] != "="; i++ {
+			if part[i] == "(" || part[i] == "[" || part[i] == "{" {
+				if part[i] == "[" {
+					dimensions = append(dimensions, i)
+				}
+				i = svClose(part, i) - 1
+			} else if isSvIdent(part[i]) && !slices.Contains(svDeclarationKeywords, part[i]) {
+				name, at = part[i], i
+			}
+		}
+		if name == "" {
+			continue
+		}
+		if at > 0 {
+			bit = !slices.ContainsFunc(part[:at], func(tok string) bool { return !slices.Contains(svBitKeywords, tok) })
+		}
+		names = append(names, name)
+		// Unpacked dimensions after the name only apply to this part
+		bits = append(bits, bit && !slices.ContainsFunc(dimensions, func(i int) bool { return i > at }))
+	}
+	return names, bits
+}
+
+// Adds the constants of every enum in some tokens to the design's global names
+func (design *SvDesign) addEnumConstants(tokens []string) {
+	for i, tok := range tokens {
+		if tok != "enum" {
+			continue
+		}
+		open := slices.Index(tokens[i:], "{")
+		if open == -1 {
+			continue
+		}
+		end := svClose(tokens, i+open)
+		for _, names := range svSplit(tokens[i+open+1:end-1], ",") {
+			if len(names) > 0 && isSvIdent(names[0]) {
+				design.globals[names[0]] = true
+			}
+		}
+	}
+}
+
+// Handles a statement within a module, or within a package if module is nil
+func (design *SvDesign) addStatement(module *SvModule, stmt []string) {
+	// Labels, as in `label: for ...` or `begin : label`
+	for len(stmt) >= 2 && isSvIdent(stmt[0]) && stmt[1] == ":" {
+		if module != nil {
+			module.blocks[stmt[0]] = true
+		}
+		stmt = stmt[2:]
+	}
+	if len(stmt) == 0 || slices.Contains(svStatementKeywords, stmt[0]) || !isSvIdent(stmt[0]) {
+		return
+	}
+
+	add := func(names []string, bits []bool) {
+		for i, name := range names {
+			if module == nil {
+				design.globals[name] = true
+			} else {
+				module.signals[name] = true
+				module.bits[name] = bits[i]
+			}
+		}
+	}
+	if slices.Contains(svDeclarationKeywords, stmt[0]) {
+		add(svDeclaredNames(stmt))
+		return
+	}
+
+	// Otherwise it starts with a type or module name, possibly from a package, and possibly parameterised
+	i := 1
+	if i+1 < len(stmt) && stmt[i] == "::" {
+		i += 2
+	}
+	if i+1 < len(stmt) && stmt[i] == "#" && stmt[i+1] == "(" {
+		i = svClose(stmt, i+1)
+	}
+	for i < len(stmt) && stmt[i] == "[" {
+		i = svClose(stmt, i)
+	}
+	if i >= len(stmt) || !isSvIdent(stmt[i]) {
+		return
+	}
+
+	// An instance has its connections in brackets after its name and any array dimensions
+	j := i + 1
+	for j < len(stmt) && stmt[j] == "[" {
+		j = svClose(stmt, j)
+	}
+	if j < len(stmt) && stmt[j] == "(" && module != nil {
+		for _, part := range svSplit(stmt[i:], ",") {
+			if len(part) > 0 && isSvIdent(part[0]) {
+				module.instances[part[0]] = stmt[0]
+			}
+		}
+		return
+	}
+	// Of a type such as a struct or enum, which is taken to be wider than a bit
+	names, _ := svDeclaredNames(stmt[i:])
+	add(names, make([]bool, len(names)))
+}
+
+// Adds the modules, interfaces and packages of a SystemVerilog file to a design
+func (design *SvDesign) addSource(text string) {
+	tokens := svLex(text)
+	design.addEnumConstants(tokens)
+
+	var module *SvModule
+	stmt := []string{}
+	for i := 0; i < len(tokens); i++ {
+		tok := tokens[i]
+		switch {
+		case tok == "module" || tok == "interface" || tok == "program":
+			for i+1 < len(tokens) && (tokens[i+1] == "automatic" || tokens[i+1] == "static") {
+				i += 1
+			}
+			if i+1 >= len(tokens) {
+				return
+			}
+			module = NewSvModule(tokens[i+1])
+			design.modules[module.name] = module
+			i += 2
+
+			// Parameters and ports of the header, after any package imports
+			for i < len(tokens) && tokens[i] != ";" {
+				if tokens[i] == "import" {
+					for i < len(tokens) && tokens[i] != ";" {
+						i += 1
+					}
+					i += 1
+				} else if tokens[i] == "(" {
+					end := svClose(tokens, i)
+					names, bits := svDeclaredNames(tokens[i+1 : end-1])
+					for k, name := range names {
+						module.signals[name] = true
+						module.bits[name] = bits[k]
+					}
+					i = end
+				} else {
+					i += 1
+				}
+			}
+			stmt = []string{}
+		case tok == "endmodule" || tok == "endinterface" || tok == "endprogram" || tok == "endpackage":
+			module = nil
+			stmt = []string{}
+		case tok == "package":
+			module = nil
+			i += 2
+			stmt = []string{}
+		case tok == "(" || tok == "[" || tok == "{":
+			end := svClose(tokens, i)
+			stmt = append(stmt, tokens[i:end]...)
+			i = end - 1
+		case tok == ";" || tok == "begin" || tok == "generate" || slices.Contains(svEndKeywords, tok):
+			design.addStatement(module, stmt)
+			stmt = []string{}
+			// A label after begin or end
+			if i+2 < len(tokens) && tokens[i+1] == ":" && tok != ";" && tok != "generate" {
+				if module != nil && tok == "begin" {
+					module.blocks[tokens[i+2]] = true
+				}
+				i += 2
+			}
+		default:
+			stmt = append(stmt, tok)
+		}
+	}
+}
+
+// Reads a design from SystemVerilog files, whose top module is top or else the only module not instantiated by another
+func loadDesign(paths []string, top string) (*SvDesign, error) {
+	design := &SvDesign{
+		modules: map[string]*SvModule{},
+		globals: map[string]bool{},
+	}
+	for _, path := range paths {
+		data, err := os.ReadFile(path)
+		if err != nil {
+			return nil, err
+		}
+		design.addSource(string(data))
+	}
+
+	if top == "" {
+		instantiated := map[string]bool{}
+		for _, module := range design.modules {
+			for _, name := range module.instances {
+				instantiated[name] = true
+			}
+		}
+		tops := []string{}
+		for _, name := range slices.Sorted(maps.Keys(design.modules)) {
+			if !instantiated[name] {
+				tops = append(tops, name)
+			}
+		}
+		if len(tops) != 1 {
+			return nil, fmt.Errorf("cannot tell which of %s is the top module, give it with -dut-top", strings.Join(tops, ", "))
+		}
+		top = tops[0]
+	}
+	design.top = design.modules[top]
+	if design.top == nil {
+		return nil, fmt.Errorf("no module named %s", top)
+	}
+	return design, nil
+}
+
+func editDistance(a string, b string) int {
+	prev := make([]int, len(b)+1)
+	for j := range prev {
+		prev[j] = j
+	}
+	for i := range len(a) {
+		cur := make([]int, len(b)+1)
+		cur[0] = i + 1
+		for j := range len(b) {
+			cost := 1
+			if a[i] == b[j] {
+				cost = 0
+			}
+			cur[j+1] = min(prev[j]+cost, prev[j+1]+1, cur[j]+1)
+		}
+		prev = cur
+	}
+	return prev[len(b)]
+}
+
+// The closest of some names to a misspelt one, if any are close enough
+func closestName(name string, names []string) string {
+	best, bestDistance := "", max(1, len(name)/3)+1
+	for _, candidate := range slices.Sorted(slices.Values(names)) {
+		if distance := editDistance(name, candidate); distance < bestDistance {
+			best, bestDistance = candidate, distance
+		}
+	}
+	return best
+}
+
+// Checks a possibly hierarchical name against the design, returning the part of it which is unknown along with a
+// suggestion for it, or empty strings if it is known. Extra names are known at the top level.
+func (design *SvDesign) check(name string, extra map[string]bool) (string, string) {
+	segments := strings.Split(name, ".")
+	module := design.top
+	for i, seg := range segments {
+		if module.signals[seg] || module.blocks[seg] || (i == 0 && (design.globals[seg] || extra[seg])) {
+			// Members of structs and the contents of generate blocks are not checked
+			return "", ""
+		}
+		if instance, ok := module.instances[seg]; ok {
+			module = design.modules[instance]
+			if module == nil {
+				// Modules which were not given cannot be checked
+				return "", ""
+			}
+			continue
+		}
+
+		candidates := slices.Collect(maps.Keys(module.signals))
+		candidates = append(candidates, slices.Collect(maps.Keys(module.instances))...)
+		if i == 0 {
+			candidates = append(candidates, slices.Collect(maps.Keys(design.globals))...)
+			candidates = append(candidates, slices.Collect(maps.Keys(extra))...)
+		}
+		prefix := strings.Join(segments[:i], ".")
+		if prefix != "" {
+			prefix += "."
+		}
+		suggestion := closestName(seg, candidates)
+		if suggestion != "" {
+			suggestion = prefix + suggestion
+		}
+		return prefix + seg, suggestion
+	}
+	return "", ""
+}
+
+// Whether a signal is declared as a single bit, which it is taken to be if its declaration is not known, such as a
+// member of a struct or a signal of a module which was not given
+func (design *SvDesign) singleBit(name string) bool {
+	module := design.top
+	segments := strings.Split(name, ".")
+	for i, seg := range segments {
+		if module.signals[seg] {
+			return i < len(segments)-1 || module.bits[seg]
+		}
+		instance, ok := module.instances[seg]
+		if !ok || design.modules[instance] == nil {
+			return true
+		}
+		module = design.modules[instance]
+	}
+	return true
+}
+
+func isNameOperator(tok Token, operator string) bool {
+	op, ok := tok.(*OperatorToken)
+	return ok && op.operator == operator
+}
+
+// Calls f with every name in a stream which should be a signal, skipping functions, macros, members after an index,
+// package scopes and keywords
+func streamSignals(stream TokenStream, f func(string)) {
+	tokens := TokenStream{}
+	for _, tok := range stream {
+		if _, ok := tok.(*WhiteSpaceToken); !ok {
+			tokens = append(tokens, tok)
+		}
+	}
+	for i, tok := range tokens {
+		if brack, ok := tok.(*BracketedToken); ok {
+			streamSignals(brack.content, f)
+			continue
+		}
+		name, ok := tok.(*NameToken)
+		if !ok {
+			continue
+		}
+
+		content := name.content
+		for strings.HasPrefix(content, "$past(") {
+			content, _, _ = strings.Cut(strings.TrimSuffix(content[len("$past("):], ")"), ",")
+			content = strings.TrimSpace(content)
+		}
+		if strings.HasPrefix(content, "$") || slices.Contains(svExpressionKeywords, content) {
+			continue
+		}
+		if i > 0 && (isNameOperator(tokens[i-1], "`") || isNameOperator(tokens[i-1], ".") || isNameOperator(tokens[i-1], "'")) {
+			continue
+		}
+		if i > 1 && isNameOperator(tokens[i-1], ":") && isNameOperator(tokens[i-2], ":") {
+			continue
+		}
+		if i+1 < len(tokens) {
+			if brack, ok := tokens[i+1].(*BracketedToken); ok && brack.openBracket == '(' {
+				continue
+			}
+			if i+2 < len(tokens) && isNameOperator(tokens[i+1], ":") && isNameOperator(tokens[i+2], ":") {
+				continue
+			}
+		}
+		f(content)
+	}
+}
+
+// Warns about every name in the properties and wires which is not a signal of the design or a wire, at the cond or in
+// a precondition came from, or otherwise the source of the first property it is in
+func (seq *FlatProofSequence) checkSignals(design *SvDesign) {
+	wires := map[string]bool{}
+	for _, wire := range seq.wires {
+		wires[wire.name] = true
+	}
+
+	warned := map[string]bool{}
+	checkName := func(name string, source string, key string) {
+		unknown, suggestion := design.check(name, wires)
+		if unknown == "" || warned[key+" "+unknown] {
+			return
+		}
+		warned[key+" "+unknown] = true
+		if suggestion != "" {
+			fmt.Fprintln(os.Stderr, fmt.Errorf("warning: %s: unknown signal %s, did you mean %s?", source, unknown, suggestion))
+		} else {
+			fmt.Fprintln(os.Stderr, fmt.Errorf("warning: %s: unknown signal %s", source, unknown))
+		}
+	}
+	checkStream := func(stream TokenStream, source string, key string) {
+		streamSignals(stream, func(name string) {
+			checkName(name, source, key)
+		})
+	}
+	// The source of the outermost cond or in with a name in its condition, if known
+	originOf := func(prop *Property, name string) string {
+		for _, origin := range prop.origins {
+			found := false
+			streamSignals(origin.condition, func(other string) {
+				found = found || other == name
+			})
+			if found && origin.command.source.String() != "" {
+				return origin.command.source.String()
+			}
+		}
+		return ""
+	}
+
+	// Conditions are shared by many properties, so their names are only reported once for each cond or in, or where
+	// that is not known at the first property. Wires have no source of their own either, so are located at the first
+	// property using them.
+	wireSources := map[string]string{}
+	for _, step := range seq.props {
+		for _, prop := range step {
+			for _, pre := range prop.preConditions {
+				streamSignals(pre, func(name string) {
+					if source := originOf(prop, name); source != "" {
+						checkName(name, source, source)
+					} else {
+						checkName(name, prop.source.String(), "")
+					}
+				})
+			}
+			checkStream(prop.postCondition, prop.source.String(), prop.source.String())
+			for _, stream := range append(slices.Clone(prop.preConditions), prop.postCondition) {
+				streamNames(stream, func(name string) {
+					if _, ok := wireSources[name]; !ok && wires[name] {
+						wireSources[name] = prop.source.String()
+					}
+				})
+			}
+		}
+	}
+	for _, wire := range seq.wires {
+		source, ok := wireSources[wire.name]
+		if !ok {
+			source = "wire " + wire.name
+		}
+		checkStream(wire.value, source, "")
+	}
+}
@@ -0,0 +1,135 @@
+package main
+
+import (
+	"maps"
+	"os"
+	"path/filepath"
+	"slices"
+	"testing"
+)
+
+const signalsDesign = `
+package pkg;
+  typedef enum logic [1:0] {IDLE, BUSY = 2'd1} state_e;
+  parameter int Depth = 4;
+endpackage
+
+// A comment naming not_a_signal
+module top import pkg::*; #(parameter int W = 8) (
+  input  logic       clk_i,
+  input  logic       rst_ni,
+  input  logic [W-1:0] data_i,
+  output logic       valid_o
+);
+  logic ready, stall;
+  logic [3:0] count;
+  logic mem [4];
+  state_e state_q;
+  wire done = ready & ~stall;
+  ` + "`ifdef FOO" + `
+  logic foo;
+  ` + "`endif" + `
+
+  sub #(.W(W)) u_sub (.clk_i, .a(ready));
+  sub u_arr [1:0] (.clk_i);
+
+  always_ff @(posedge clk_i) begin : counter
+    logic unused;
+    count <= count + 1;
+  end
+
+  for (genvar i = 0; i < 2; i++) begin : g_lane
+    logic lane;
+  end
+endmodule
+
+module sub #(parameter int W = 1) (input logic clk_i, input logic a);
+  logic [W-1:0] q;
+endmodule
+`
+
+func TestSvDesign(t *testing.T) {
+	design := &SvDesign{modules: map[string]*SvModule{}, globals: map[string]bool{}}
+	design.addSource(signalsDesign)
+	design.top = design.modules["top"]
+
+	if names := slices.Sorted(maps.Keys(design.modules)); !slices.Equal(names, []string{"sub", "top"}) {
+		t.Fatalf("modules %v, want [sub top]", names)
+	}
+	top := design.modules["top"]
+	// Declarations within blocks are taken as the module's own
+	want := []string{"W", "clk_i", "count", "data_i", "done", "foo", "lane", "mem", "ready", "rst_ni", "stall", "state_q", "unused", "valid_o"}
+	if signals := slices.Sorted(maps.Keys(top.signals)); !slices.Equal(signals, want) {
+		t.Errorf("signals of top %v, want %v", signals, want)
+	}
+	bits := []string{}
+	for name, bit := range top.bits {
+		if bit {
+			bits = append(bits, name)
+		}
+	}
+	if slices.Sort(bits); !slices.Equal(bits, []string{"clk_i", "done", "foo", "lane", "ready", "rst_ni", "stall", "unused", "valid_o"}) {
+		t.Errorf("single bits of top %v", bits)
+	}
+	if !maps.Equal(top.instances, map[string]string{"u_sub": "sub", "u_arr": "sub"}) {
+		t.Errorf("instances of top %v", top.instances)
+	}
+	if !top.blocks["counter"] || !top.blocks["g_lane"] {
+		t.Errorf("blocks of top %v, want counter and g_lane", top.blocks)
+	}
+	if globals := slices.Sorted(maps.Keys(design.globals)); !slices.Equal(globals, []string{"BUSY", "Depth", "IDLE"}) {
+		t.Errorf("globals %v, want [BUSY Depth IDLE]", globals)
+	}
+
+	for _, test := range []struct {
+		name       string
+		unknown    string
+		suggestion string
+	}{
+		{"ready", "", ""},
+		{"IDLE", "", ""},
+		{"u_sub.q", "", ""},
+		{"g_lane.lane", "", ""},
+		{"state_q.field", "", ""},
+		{"wire_a", "", ""},
+		{"redy", "redy", "ready"},
+		{"u_sub.qq", "u_sub.qq", "u_sub.q"},
+		{"u_sb.q", "u_sb", "u_sub"},
+		{"not_a_signal", "not_a_signal", ""},
+	} {
+		unknown, suggestion := design.check(test.name, map[string]bool{"wire_a": true})
+		if unknown != test.unknown || suggestion != test.suggestion {
+			t.Errorf("checking %s gave %q, did you mean %q, want %q, %q", test.name, unknown, suggestion, test.unknown, test.suggestion)
+		}
+	}
+
+	if !design.singleBit("u_sub.a") || design.singleBit("count") || design.singleBit("u_sub.q") || design.singleBit("mem") {
+		t.Errorf("widths of signals found wrongly")
+	}
+}
+
+func TestClosestName(t *testing.T) {
+	// Only names within a third of their length are suggested, a swap counting as two edits
+	names := []string{"valid", "ready", "data", "addr"}
+	for name, want := range map[string]string{"vali": "valid", "read": "ready", "dat": "data", "vaild": "", "address_q": ""} {
+		if got := closestName(name, names); got != want {
+			t.Errorf("closest to %s is %q, want %q", name, got, want)
+		}
+	}
+}
+
+func TestLoadDesign(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "design.sv")
+	if err := os.WriteFile(path, []byte(signalsDesign), 0664); err != nil {
+		t.Fatal(err)
+	}
+	if design, err := loadDesign([]string{path}, ""); err != nil || design.top.name != "top" {
+		t.Errorf("found top %v, %v, want top", design, err)
+	}
+	if design, err := loadDesign([]string{path}, "sub"); err != nil || design.top.name != "sub" {
+		t.Errorf("found top %v, %v, want sub", design, err)
+	}
+	if _, err := loadDesign([]string{path}, "missing"); err == nil {
+		t.Errorf("loaded a design with a missing top")
+	}
+}
@@ -0,0 +1,279 @@
+package main
+
+import (
+	"fmt"
+	"slices"
+)
+
+func withoutWhitespace(stream TokenStream) TokenStream {
+	tokens := TokenStream{}
+	for _, tok := range stream {
+		if _, ok := tok.(*WhiteSpaceToken); !ok {
+			tokens = append(tokens, tok)
+		}
+	}
+	return tokens
+}
+
+// Whether two tokens are the same, ignoring whitespace within brackets
+func tokensEqual(a Token, b Token) bool {
+	switch a := a.(type) {
+	case *NameToken:
+		b, ok := b.(*NameToken)
+		return ok && a.content == b.content
+	case *NumToken:
+		b, ok := b.(*NumToken)
+		return ok && a.num == b.num
+	case *OperatorToken:
+		b, ok := b.(*OperatorToken)
+		return ok && a.operator == b.operator
+	case *BracketedToken:
+		b, ok := b.(*BracketedToken)
+		return ok && a.openBracket == b.openBracket && streamsEqual(a.content, b.content)
+	case *WhiteSpaceToken:
+		_, ok := b.(*WhiteSpaceToken)
+		return ok
+	}
+	return false
+}
+
+// Whether two streams are the same, ignoring whitespace
+func streamsEqual(a TokenStream, b TokenStream) bool {
+	return slices.EqualFunc(withoutWhitespace(a), withoutWhitespace(b), tokensEqual)
+}
+
+func isConstant(stream TokenStream, value bool) bool {
+	tokens := withoutWhitespace(stream)
+	if len(tokens) != 1 {
+		return false
+	}
+	num, ok := tokens[0].(*NumToken)
+	if value {
+		return ok && (num.num == "1" || num.num == "1'b1")
+	}
+	return ok && (num.num == "0" || num.num == "1'b0")
+}
+
+func constant(value bool) TokenStream {
+	if value {
+		return TokenStream{&NumToken{num: "1"}}
+	}
+	return TokenStream{&NumToken{num: "0"}}
+}
+
+// Whether a term is unary operators applied to a single operand, which needs no brackets. Operators after the
+// operand, such as a binary minus, or operands separated by whitespace, such as `a or b`, make it more than that.
+func isPrimary(term TokenStream) bool {
+	operand := false
+	spaced := false
+	for _, tok := range trimWhitespace(term) {
+		switch tok.(type) {
+		case *WhiteSpaceToken:
+			spaced = operand
+		case *OperatorToken:
+			if operand {
+				return false
+			}
+		default:
+			if operand && spaced {
+				return false
+			}
+			operand = true
+		}
+	}
+	return operand
+}
+
+// The operator joining every term of a stream, if they are all primaries joined by the same && or || operator
+func junctionOf(stream TokenStream) (string, []TokenStream) {
+	terms := termsOf(stream)
+	if len(terms) < 2 {
+		return "", nil
+	}
+	op := ""
+	for _, tok := range stream {
+		if tok, ok := tok.(*OperatorToken); ok && !isUnaryOperator(tok.operator) {
+			if (op != "" && tok.operator != op) || (tok.operator != "&&" && tok.operator != "||") {
+				return "", nil
+			}
+			op = tok.operator
+		}
+	}
+	for i, term := range terms {
+		if !isPrimary(term) {
+			return "", nil
+		}
+		terms[i] = trimWhitespace(term)
+	}
+	return op, terms
+}
+
+// A term without double negations or brackets around a primary
+func simplifyTerm(term TokenStream) TokenStream {
+	term = trimWhitespace(term)
+	i := 0
+	for i < len(term) && isNameOperator(term[i], "~") {
+		i += 1
+	}
+	if brack, ok := term[len(term)-1].(*BracketedToken); ok && i == len(term)-1 && brack.openBracket == '(' {
+		inner := simplifyExpr(brack.content)
+		if isPrimary(inner) {
+			term = append(slices.Clone(term[:i]), inner...)
+		} else {
+			term = append(slices.Clone(term[:i]), paren(inner))
+		}
+	}
+	for len(term) > 2 && isNameOperator(term[0], "~") && isNameOperator(term[1], "~") {
+		term = term[2:]
+	}
+	return term
+}
+
+// The terms of a conjunction or disjunction without duplicates or identities, and with bracketed terms of the same
+// kind flattened and those absorbed by another term removed. A term which decides the whole result is all that is left.
+func simplifyJunction(terms []TokenStream, op string) []TokenStream {
+	dual := "||"
+	if op == "||" {
+		dual = "&&"
+	}
+
+	flat := []TokenStream{}
+	for _, term := range terms {
+		term = simplifyTerm(term)
+		if brack, ok := term[0].(*BracketedToken); ok && len(term) == 1 {
+			if inner, innerTerms := junctionOf(brack.content); inner == op {
+				flat = append(flat, innerTerms...)
+				continue
+			}
+		}
+		flat = append(flat, term)
+	}
+
+	kept := []TokenStream{}
+	for _, term := range flat {
+		if isConstant(term, op == "||") {
+			return []TokenStream{term}
+		}
+		if isConstant(term, op == "&&") || slices.ContainsFunc(kept, func(other TokenStream) bool { return streamsEqual(term, other) }) {
+			continue
+		}
+		kept = append(kept, term)
+	}
+
+	// Absorption: x && (x || y) is x, as is x || (x && y)
+	absorbed := []TokenStream{}
+	for _, term := range kept {
+		if brack, ok := term[0].(*BracketedToken); ok && len(term) == 1 {
+			if inner, innerTerms := junctionOf(brack.content); inner == dual && slices.ContainsFunc(innerTerms, func(innerTerm TokenStream) bool {
+				return slices.ContainsFunc(kept, func(other TokenStream) bool { return streamsEqual(innerTerm, other) })
+			}) {
+				continue
+			}
+		}
+		absorbed = append(absorbed, term)
+	}
+
+	if len(absorbed) == 0 {
+		return []TokenStream{constant(op == "&&")}
+	}
+	return absorbed
+}
+
+func joinTerms(terms []TokenStream, op string) TokenStream {
+	stream := TokenStream{}
+	for i, term := range terms {
+		if i != 0 {
+			stream = append(stream, &WhiteSpaceToken{}, &OperatorToken{operator: op}, &WhiteSpaceToken{})
+		}
+		stream = append(stream, term...)
+	}
+	return stream
+}
+
+// Simplifies conjunctions and disjunctions of primaries and the brackets within them, leaving any other expression,
+// such as a comparison or sequence, as it is. The original stream is kept when nothing changes.
+func simplifyExpr(stream TokenStream) TokenStream {
+	trimmed := trimWhitespace(stream)
+	if len(trimmed) == 0 {
+		return stream
+	}
+
+	var simplified TokenStream
+	if op, terms := junctionOf(trimmed); op != "" {
+		simplified = joinTerms(simplifyJunction(terms, op), op)
+	} else if isPrimary(trimmed) {
+		simplified = simplifyTerm(trimmed)
+	} else {
+		return stream
+	}
+	// Brackets around the whole of a junction are not needed, as it is bracketed again wherever it needs to be
+	if brack, ok := simplified[0].(*BracketedToken); ok && len(simplified) == 1 && brack.openBracket == '(' {
+		if op, _ := junctionOf(brack.content); op != "" {
+			simplified = brack.content
+		}
+	}
+
+	if streamsEqual(simplified, trimmed) {
+		return stream
+	}
+	return simplified
+}
+
+// Whether one term is the negation of the other
+func negates(a TokenStream, b TokenStream) bool {
+	a, b = withoutWhitespace(a), withoutWhitespace(b)
+	if len(a) > 1 && isNameOperator(a[0], "~") {
+		return streamsEqual(simplifyTerm(a[1:]), simplifyTerm(b))
+	}
+	if len(b) > 1 && isNameOperator(b[0], "~") {
+		return streamsEqual(simplifyTerm(b[1:]), simplifyTerm(a))
+	}
+	return false
+}
+
+// Simplifies the conditions of a property, which are then each a single term of its precondition. Warns if they
+// contradict each other, as then the property is vacuous.
+func (prop *Property) simplify(scope *Scope) {
+	terms := []TokenStream{}
+	for _, pre := range prop.preConditions {
+		pre = simplifyExpr(pre)
+		if op, preTerms := junctionOf(pre); op == "&&" {
+			terms = append(terms, preTerms...)
+		} else if isPrimary(pre) {
+			terms = append(terms, trimWhitespace(pre))
+		} else {
+			terms = append(terms, TokenStream{paren(trimWhitespace(pre))})
+		}
+	}
+	if len(terms) > 0 {
+		terms = simplifyJunction(terms, "&&")
+		if isConstant(terms[0], false) {
+			scope.warnOnce(prop.source, fmt.Errorf("warning: %s: property %s is vacuous, its precondition is always false", prop.source, prop.name))
+		}
+		for i := range terms {
+			for _, other := range terms[i+1:] {
+				if negates(terms[i], other) {
+					scope.warnOnce(prop.source, fmt.Errorf("warning: %s: property %s is vacuous, its preconditions %s and %s contradict each other",
+						prop.source, prop.name, streamToString(terms[i]), streamToString(other)))
+				}
+			}
+		}
+		// A precondition of 1 is left out, unless it delays the postcondition
+		if isConstant(terms[0], true) && prop.step == "|->" {
+			terms = []TokenStream{}
+		}
+	}
+	prop.preConditions = terms
+	prop.postCondition = simplifyExpr(prop.postCondition)
+}
+
+func (seq *FlatProofSequence) simplify(scope *Scope) {
+	for i := range seq.wires {
+		seq.wires[i].value = simplifyExpr(seq.wires[i].value)
+	}
+	for _, step := range seq.props {
+		for _, prop := range step {
+			prop.simplify(scope)
+		}
+	}
+}
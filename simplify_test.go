@@ -0,0 +1,87 @@
+package main
+
+import (
+	"maps"
+	"slices"
+	"testing"
+)
+
+func TestSimplifyExpr(t *testing.T) {
+	for _, test := range []struct {
+		expr string
+		want string
+	}{
+		{"1 && x", "x"},
+		{"x && 1", "x"},
+		{"0 || x", "x"},
+		{"0 && x", "0"},
+		{"1 || x", "1"},
+		{"1'b1 && x", "x"},
+		{"~~x", "x"},
+		{"~~~x", "~x"},
+		{"(x)", "x"},
+		{"~(x)", "~x"},
+		{"x && (x || y)", "x"},
+		{"(x || y) && x", "x"},
+		{"x || (x && y)", "x"},
+		{"(x && y) || x", "x"},
+		{"a && (b && c)", "a && b && c"},
+		{"(a || b) || (c || d)", "a || b || c || d"},
+		{"a && (b || c)", "a && (b || c)"},
+		{"x && y && x", "x && y"},
+		{"x && (y) && ( x )", "x && y"},
+		{"(a && b)", "a && b"},
+		{"~(a && b)", "~(a && b)"},
+		// Not junctions of primaries, so left alone
+		{"a == b && c", "a == b && c"},
+		{"a or b", "a or b"},
+		{"a && b || c", "a && b || c"},
+		{"v[3:0] && v[3:0]", "v[3:0]"},
+		{"v[3:0] && v[2:0]", "v[3:0] && v[2:0]"},
+		{"a ##1 b", "a ##1 b"},
+	} {
+		if got := streamToString(simplifyExpr(testStream(test.expr))); got != test.want {
+			t.Errorf("simplifyExpr(%q) = %q, want %q", test.expr, got, test.want)
+		}
+	}
+}
+
+func TestSimplifyProperty(t *testing.T) {
+	for _, test := range []struct {
+		pres     []string
+		step     string
+		want     []string
+		warnings []string
+	}{
+		{[]string{"a && b", "c"}, "|->", []string{"a", "b", "c"}, []string{}},
+		{[]string{"a", "1"}, "|->", []string{"a"}, []string{}},
+		{[]string{"1"}, "|->", []string{}, []string{}},
+		{[]string{"1"}, "|=>", []string{"1"}, []string{}},
+		{[]string{"a == b", "c"}, "|->", []string{"(a == b)", "c"}, []string{}},
+		{[]string{"x", "~x"}, "|->", []string{"x", "~x"}, []string{
+			"warning: t.proof:3: property P is vacuous, its preconditions x and ~x contradict each other",
+		}},
+		{[]string{"~(a && b)", "a && b"}, "|->", []string{"~(a && b)", "a", "b"}, []string{}},
+		{[]string{"a", "0"}, "|->", []string{"0"}, []string{
+			"warning: t.proof:3: property P is vacuous, its precondition is always false",
+		}},
+	} {
+		doc := NewProofDocument()
+		scope := NewScope(&doc)
+		prop := Property{name: "P", postCondition: testStream("d"), step: test.step, source: Source{file: "t.proof", line: 3}}
+		for _, pre := range test.pres {
+			prop.preConditions = append(prop.preConditions, testStream(pre))
+		}
+		prop.simplify(&scope)
+		got := []string{}
+		for _, pre := range prop.preConditions {
+			got = append(got, streamToString(pre))
+		}
+		if !slices.Equal(got, test.want) {
+			t.Errorf("preconditions %q simplify to %q, want %q", test.pres, got, test.want)
+		}
+		if warnings := slices.Sorted(maps.Keys(scope.env.warned)); !slices.Equal(warnings, test.warnings) {
+			t.Errorf("preconditions %q warned %q, want %q", test.pres, warnings, test.warnings)
+		}
+	}
+}
@@ -0,0 +1,109 @@
+package main
+
+import (
+	"fmt"
+	"path/filepath"
+	"strconv"
+	"strings"
+)
+
+// The properties a slice of step n assumes, which must be the same for every property it asserts, as the assumptions of
+// a slice's SystemVerilog apply to all of them
+func (seq *FlatProofSequence) sliceAssumptions(n int) ([]*Property, error) {
+	if n < 0 || n >= len(seq.props) {
+		return nil, fmt.Errorf("no step %d to slice, there are %d steps", n, len(seq.props))
+	}
+	props := []*Property{}
+	for _, prop := range seq.props[n] {
+		if !prop.assumed && !prop.cover {
+			props = append(props, prop)
+		}
+	}
+	groups, assumptions := seq.assumptionGroups(n, props)
+	if len(groups) > 1 {
+		return nil, fmt.Errorf("%s and %s in step %d assume different properties, as one has a using clause, so cannot be "+
+			"sliced together, use -task instead", groups[0][0].name, groups[1][0].name, n)
+	}
+	if len(groups) == 0 {
+		return seq.propAssumes(n, &Property{}), nil
+	}
+	return assumptions[0], nil
+}
+
+// The names of the files of a step's slice, one per shard if it is sharded
+func (seq *FlatProofSequence) sliceNames(i int) []string {
+	names := []string{}
+	for shard := range seq.shardCount(i) {
+		names = append(names, seq.shardName("slice_"+strconv.Itoa(i), i, shard))
+	}
+	return names
+}
+
+// A task proving a shard of a slice's step, whose earlier steps are already assumptions in the slice's SystemVerilog
+func (seq *FlatProofSequence) toSliceTask(i int, shard int) string {
+	prop_names := ""
+	for _, prop := range seq.props[i] {
+		if !prop.assumed && prop.shard == shard {
+			prop_names += " *." + prop.name
+		}
+	}
+	if prop_names == "" {
+		return "# Step " + strconv.Itoa(i) + " has no properties\n"
+	}
+	return "task -create " + seq.shardName("Slice"+strconv.Itoa(i), i, shard) + " -copy_assumes -copy {" + prop_names[1:] + "}\n"
+}
+
+// A Makefile proving every slice, each only once the slices of the steps it is after are proven
+func (seq *FlatProofSequence) toSliceMakefile() string {
+	all := []string{}
+	for i := range seq.props {
+		for _, name := range seq.sliceNames(i) {
+			all = append(all, name+".done")
+		}
+	}
+
+	mk := "# Generated by psgen, run with make -j to prove independent slices in parallel\n"
+	mk += "# PROVE is run with the SystemVerilog and TCL of each slice, and should fail unless every property is proven\n"
+	mk += "PROVE ?= $(error PROVE must be set to the command proving a slice)\n\n"
+	mk += ".PHONY: all clean\n"
+	mk += "all: " + strings.Join(all, " ") + "\n\n"
+	for i, after := range seq.after {
+		for _, name := range seq.sliceNames(i) {
+			deps := []string{name + ".sv", name + ".tcl"}
+			for _, j := range after {
+				for _, dep := range seq.sliceNames(j) {
+					deps = append(deps, dep+".done")
+				}
+			}
+			mk += name + ".done: " + strings.Join(deps, " ") + "\n"
+			mk += "\t$(PROVE) " + name + ".sv " + name + ".tcl && touch $@\n\n"
+		}
+	}
+	mk += "clean:\n\trm -f " + strings.Join(all, " ") + "\n"
+	return mk
+}
+
+// The SystemVerilog and TCL of every slice, and the Makefile ordering them, by path within dir
+func (seq *FlatProofSequence) toSlices(dir string, clocking bool, stepPrefix bool) map[string]string {
+	outputs := map[string]string{}
+	for i := range seq.props {
+		sva := seq.toSva(i, clocking, stepPrefix, 100)
+		names := seq.sliceNames(i)
+		for shard, name := range names {
+			// Each shard's slice leaves out the other shards of its step
+			removed := ""
+			for other := range names {
+				if other != shard {
+					removed += "`define REMOVE_SHARD_" + strconv.Itoa(i) + "_" + strconv.Itoa(other) + "\n"
+				}
+			}
+			if removed != "" {
+				removed += "\n"
+			}
+			outputs[filepath.Join(dir, name+".sv")] = removed + sva
+			outputs[filepath.Join(dir, name+".tcl")] = seq.toSliceTask(i, shard)
+		}
+	}
+	outputs[filepath.Join(dir, "Makefile")] = seq.toSliceMakefile()
+	return outputs
+}
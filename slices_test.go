@@ -0,0 +1,104 @@
+package main
+
+import (
+	"maps"
+	"slices"
+	"strings"
+	"testing"
+)
+
+const slicesProof = `
+lemma dag_example
+  / base
+  P: have (p)
+  / left after base
+  Q: have (q)
+  / right after base
+  R: have (r)
+  R2: have (r2)
+  / top after left right
+  S: have (s)
+`
+
+func TestSlices(t *testing.T) {
+	seq := testSequence(t, slicesProof, "dag_example")
+	outputs := seq.toSlices("out", false, false)
+	want := []string{"out/Makefile", "out/slice_0.sv", "out/slice_0.tcl", "out/slice_1.sv", "out/slice_1.tcl",
+		"out/slice_2.sv", "out/slice_2.tcl", "out/slice_3.sv", "out/slice_3.tcl"}
+	if files := slices.Sorted(maps.Keys(outputs)); !slices.Equal(files, want) {
+		t.Fatalf("slices wrote %v, want %v", files, want)
+	}
+
+	// Each slice asserts its own step and assumes only the steps it is after
+	for _, test := range []struct {
+		file    string
+		asserts []string
+		assumes []string
+	}{
+		{"out/slice_0.sv", []string{"P"}, []string{}},
+		{"out/slice_1.sv", []string{"Q"}, []string{"P"}},
+		{"out/slice_2.sv", []string{"R", "R2"}, []string{"P"}},
+		{"out/slice_3.sv", []string{"S"}, []string{"P", "Q", "R", "R2"}},
+	} {
+		asserts, assumes := []string{}, []string{}
+		for _, line := range strings.Split(outputs[test.file], "\n") {
+			if name, ok := strings.CutSuffix(strings.Fields(line + " x")[0], ":"); ok {
+				if strings.Contains(line, "assert property") {
+					asserts = append(asserts, name)
+				} else if strings.Contains(line, "assume property") {
+					assumes = append(assumes, name)
+				}
+			}
+		}
+		if !slices.Equal(asserts, test.asserts) || !slices.Equal(assumes, test.assumes) {
+			t.Errorf("%s asserts %v and assumes %v, want %v and %v", test.file, asserts, assumes, test.asserts, test.assumes)
+		}
+	}
+	if outputs["out/slice_2.tcl"] != "task -create Slice2 -copy_assumes -copy {*.R *.R2}\n" {
+		t.Errorf("slice_2.tcl is %q", outputs["out/slice_2.tcl"])
+	}
+
+	makefile := "# Generated by psgen, run with make -j to prove independent slices in parallel\n" +
+		"# PROVE is run with the SystemVerilog and TCL of each slice, and should fail unless every property is proven\n" +
+		"PROVE ?= $(error PROVE must be set to the command proving a slice)\n\n" +
+		".PHONY: all clean\n" +
+		"all: slice_0.done slice_1.done slice_2.done slice_3.done\n\n" +
+		"slice_0.done: slice_0.sv slice_0.tcl\n\t$(PROVE) slice_0.sv slice_0.tcl && touch $@\n\n" +
+		"slice_1.done: slice_1.sv slice_1.tcl slice_0.done\n\t$(PROVE) slice_1.sv slice_1.tcl && touch $@\n\n" +
+		"slice_2.done: slice_2.sv slice_2.tcl slice_0.done\n\t$(PROVE) slice_2.sv slice_2.tcl && touch $@\n\n" +
+		"slice_3.done: slice_3.sv slice_3.tcl slice_1.done slice_2.done\n\t$(PROVE) slice_3.sv slice_3.tcl && touch $@\n\n" +
+		"clean:\n\trm -f slice_0.done slice_1.done slice_2.done slice_3.done\n"
+	if outputs["out/Makefile"] != makefile {
+		t.Errorf("Makefile:\n%s\nwant:\n%s", outputs["out/Makefile"], makefile)
+	}
+}
+
+func TestShardedSlices(t *testing.T) {
+	seq := testSequence(t, slicesProof, "dag_example")
+	seq.shard(2, nil)
+	outputs := seq.toSlices("out", false, false)
+
+	// Each shard of a step is a slice of its own, defining away the other shards
+	for _, test := range []struct {
+		name    string
+		removed string
+		task    string
+	}{
+		{"slice_2_Shard0", "1", "Slice2_Shard0"},
+		{"slice_2_Shard1", "0", "Slice2_Shard1"},
+	} {
+		sv, tcl := outputs["out/"+test.name+".sv"], outputs["out/"+test.name+".tcl"]
+		if !strings.HasPrefix(sv, "`define REMOVE_SHARD_2_"+test.removed+"\n\n") {
+			t.Errorf("%s.sv does not remove shard %s:\n%s", test.name, test.removed, sv)
+		}
+		if !strings.HasPrefix(tcl, "task -create "+test.task+" ") {
+			t.Errorf("%s.tcl is %q", test.name, tcl)
+		}
+	}
+	if _, ok := outputs["out/slice_2.sv"]; ok {
+		t.Errorf("sharded step also has an unsharded slice")
+	}
+	if !strings.Contains(outputs["out/Makefile"], "slice_3.done: slice_3.sv slice_3.tcl slice_1.done slice_2_Shard0.done slice_2_Shard1.done\n") {
+		t.Errorf("slice_3 does not wait for both shards of step 2:\n%s", outputs["out/Makefile"])
+	}
+}
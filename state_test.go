@@ -0,0 +1,98 @@
+package main
+
+import (
+	"fmt"
+	"path/filepath"
+	"slices"
+	"strings"
+	"testing"
+)
+
+const globalStateProof = `state busy (v && ~r)
+state done (fin)
+
+def d
+  D: have (d)
+
+lemma l
+  in busy
+    P: have (p)
+  in busy
+    use d
+  G: graph_induction
+    inv ok (x)
+    node idle ok busy => idle
+  lemma m
+
+lemma m
+  state done (other)
+  in done
+    M: have (m)
+`
+
+func TestGlobalStates(t *testing.T) {
+	dir := testFiles(t, map[string]string{
+		"a.proof": globalStateProof,
+		"b.proof": "state busy (v2)\n",
+	})
+	a := filepath.Join(dir, "a.proof")
+
+	// Global states are visible in lemmas, defs used within them and graph nodes, and are shadowed by local states
+	scope, err := loadScope([]string{a}, []string{}, map[string]string{}, []string{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	scope.env.quiet = true
+	sva := testSva(genSequence(&scope, "l"))
+	for _, want := range []string{
+		"P: assert property (v && ~r |-> p);",
+		"D: assert property (v && ~r |-> d);",
+		"assign g_idle = v && ~r;",
+		"M: assert property (other |-> m);",
+	} {
+		if !strings.Contains(sva, want) {
+			t.Errorf("no %s in:\n%s", want, sva)
+		}
+	}
+	warnings := []string{}
+	for _, warning := range scope.env.warnings {
+		warnings = append(warnings, warning.err.Error())
+	}
+	if want := fmt.Sprintf("warning: state done shadows the global state defined at %s:2", a); !slices.Equal(warnings, []string{want}) {
+		t.Errorf("warned %q, want %q", warnings, want)
+	}
+
+	// A later declaration, here in a later file, replaces the earlier one
+	scope, err = loadScope([]string{a, filepath.Join(dir, "b.proof")}, []string{}, map[string]string{}, []string{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	scope.env.quiet = true
+	if sva := testSva(genSequence(&scope, "l")); !strings.Contains(sva, "P: assert property (v2 |-> p);") {
+		t.Errorf("busy was not redefined:\n%s", sva)
+	}
+}
+
+// The SystemVerilog of a whole sequence
+func testSva(seq FlatProofSequence) string {
+	return seq.toSva(-1, false, false, 100)
+}
+
+func TestGlobalStateDeclarations(t *testing.T) {
+	_, blocks := parseBlocks(strings.Split("state s (a)\nstate s (b)\n", "\n"), -1)
+	doc := blocksToProofDocument(blocks)
+	if !slices.Equal(doc.warnings, []string{"warning: line 2: state s redefined, previously defined at line 1"}) {
+		t.Errorf("redefining a state warned %q", doc.warnings)
+	}
+	if streamToString(doc.states["s"].value) != "b" {
+		t.Errorf("redefined state is %s, want b", streamToString(doc.states["s"].value))
+	}
+
+	defer func() {
+		if r := recover(); fmt.Sprint(r) != "line 1: cond must be within a lemma or def, only states can be declared outside of them" {
+			t.Errorf("a global cond gave %v", r)
+		}
+	}()
+	_, blocks = parseBlocks([]string{"cond (a)", "lemma l", "  P: have (p)"}, -1)
+	blocksToProofDocument(blocks)
+}
@@ -2,6 +2,7 @@ package main
 
 import (
 	"fmt"
+	"maps"
 	"slices"
 	"strconv"
 	"strings"
@@ -364,6 +365,24 @@ func isWhitespaceTerm(stream TokenStream) bool {
 	return true
 }
 
+func trimWhitespace(stream TokenStream) TokenStream {
+	start := 0
+	for start < len(stream) {
+		if _, ok := stream[start].(*WhiteSpaceToken); !ok {
+			break
+		}
+		start += 1
+	}
+	end := len(stream)
+	for end > start {
+		if _, ok := stream[end-1].(*WhiteSpaceToken); !ok {
+			break
+		}
+		end -= 1
+	}
+	return stream[start:end]
+}
+
 func comma(terms []TokenStream) TokenStream {
 	newStream := TokenStream{}
 	for i, term := range terms {
@@ -688,7 +707,15 @@ func concatTokens(stream TokenStream) TokenStream {
 }
 
 func formatStream(stream TokenStream, lineWidth int) string {
-	lines := []Line{{tokens: concatTokens(stream), indent: 0, breakRangeStart: 0, breakRangeEnd: len(stream)}}
+	str := ""
+	for _, line := range formatStreamLines(concatTokens(stream), lineWidth) {
+		str += line + "\n"
+	}
+	return str
+}
+
+func formatStreamLines(stream TokenStream, lineWidth int) []string {
+	lines := []Line{{tokens: stream, indent: 0, breakRangeStart: 0, breakRangeEnd: len(stream)}}
 
 	allFit := false
 	changed := true
@@ -730,20 +757,54 @@ func formatStream(stream TokenStream, lineWidth int) string {
 		lines = newLines
 	}
 
-	str := ""
+	strs := []string{}
 	for _, line := range lines {
-		str += strings.Repeat(" ", line.indent*4) + strings.Trim(streamToString(line.tokens), " ") + "\n"
+		strs = append(strs, strings.Repeat(" ", line.indent*4)+strings.Trim(streamToString(line.tokens), " "))
 	}
-	return str
+	return strs
 }
 
-func (prop *Property) toSva(assume bool, clocking bool, stepPrefix bool, lineWidth int, stepNo int) string {
-	unsplittableStart := ""
+func (prop *Property) svaName(stepPrefix bool, stepNo int) string {
 	if stepPrefix {
-		unsplittableStart += "Step" + strconv.Itoa(stepNo) + "_"
+		return "Step" + strconv.Itoa(stepNo) + "_" + prop.name
+	}
+	return prop.name
+}
+
+// The property as SystemVerilog, without its name or clocking
+func (prop *Property) svaStream() TokenStream {
+	stream := TokenStream{}
+	if prop.wait != 0 {
+		stream = append(stream, &OperatorToken{
+			operator: "##" + strconv.Itoa(prop.wait),
+		})
+		stream = append(stream, &WhiteSpaceToken{})
+	}
+	if prop.cover {
+		// A cover of an implication would also be covered vacuously, so a cover is the sequence of its conditions
+		if prop.step == "|=>" {
+			stream = append(stream, conjoin(prop.preConditions)...)
+			stream = append(stream, &WhiteSpaceToken{}, &OperatorToken{operator: "##1"}, &WhiteSpaceToken{})
+			return append(stream, conjoin([]TokenStream{prop.postCondition})...)
+		}
+		return append(stream, conjoin(append(slices.Clone(prop.preConditions), prop.postCondition))...)
+	}
+	if len(prop.preConditions) > 0 {
+		stream = append(stream, conjoin(prop.preConditions)...)
+		stream = append(stream, &WhiteSpaceToken{})
+		stream = append(stream, &OperatorToken{
+			operator: prop.step,
+		})
+		stream = append(stream, &WhiteSpaceToken{})
 	}
-	unsplittableStart += prop.name + ": "
-	if assume {
+	return append(stream, prop.postCondition...)
+}
+
+func (prop *Property) toSva(assume bool, clocking bool, stepPrefix bool, lineWidth int, stepNo int) string {
+	unsplittableStart := prop.svaName(stepPrefix, stepNo) + ": "
+	if prop.cover {
+		unsplittableStart += "cover"
+	} else if assume {
 		unsplittableStart += "assume"
 	} else {
 		unsplittableStart += "assert"
@@ -754,24 +815,9 @@ func (prop *Property) toSva(assume bool, clocking bool, stepPrefix bool, lineWid
 	if clocking {
 		inner = append(inner, &NameToken{content: "@(posedge clk_i) disable iff (~rst_ni) "})
 	}
+	inner = append(inner, prop.svaStream()...)
 
-	if prop.wait != 0 {
-		inner = append(inner, &OperatorToken{
-			operator: "##" + strconv.Itoa(prop.wait),
-		})
-		inner = append(inner, &WhiteSpaceToken{})
-	}
-	if len(prop.preConditions) > 0 {
-		inner = append(inner, conjoin(prop.preConditions)...)
-		inner = append(inner, &WhiteSpaceToken{})
-		inner = append(inner, &OperatorToken{
-			operator: prop.step,
-		})
-		inner = append(inner, &WhiteSpaceToken{})
-	}
-	inner = append(inner, prop.postCondition...)
-
-	return formatStream(TokenStream{
+	return prop.provenanceComment() + formatStream(TokenStream{
 		&NameToken{content: unsplittableStart},
 		paren(inner),
 		&OperatorToken{operator: ";"},
@@ -794,13 +840,46 @@ func (seq *FlatProofSequence) toSva(slice int, clocking bool, stepPrefix bool, l
 		sva += wire.toSva(lineWidth) + "\n"
 	}
 
+	// Earlier steps only give the properties the slice assumes, which sliceAssumptions has checked are the same for all
+	assumed := []*Property{}
+	if slice != -1 {
+		assumed, _ = seq.sliceAssumptions(slice)
+	}
 	for i, step := range seq.props {
-		if slice != -1 && i > slice {
-			break
+		if slice != -1 && i != slice && !slices.ContainsFunc(step, func(prop *Property) bool { return slices.Contains(assumed, prop) }) {
+			continue
 		}
 		sva += "`ifndef REMOVE_SLICE_" + strconv.Itoa(i) + "\n"
-		for _, prop := range step {
-			sva += prop.toSva(slice != -1 && i != slice, clocking, stepPrefix, lineWidth, i) + "\n"
+		if seq.shardCount(i) == 1 {
+			for _, prop := range step {
+				assume := prop.assumed || (slice != -1 && i != slice)
+				if prop.cover && assume || slice != -1 && i != slice && !slices.Contains(assumed, prop) {
+					continue
+				}
+				sva += prop.toSva(assume, clocking, stepPrefix, lineWidth, i) + "\n"
+			}
+		} else {
+			asserted := []*Property{}
+			for _, prop := range step {
+				if slice != -1 && i != slice && !slices.Contains(assumed, prop) {
+					continue
+				}
+				if prop.assumed && !prop.cover {
+					sva += prop.toSva(true, clocking, stepPrefix, lineWidth, i) + "\n"
+				} else if !prop.assumed {
+					asserted = append(asserted, prop)
+				}
+			}
+			for k, shard := range seq.shardsOf(i, asserted) {
+				sva += "`ifndef REMOVE_SHARD_" + strconv.Itoa(i) + "_" + strconv.Itoa(k) + "\n"
+				for _, prop := range shard {
+					if prop.cover && slice != -1 && i != slice {
+						continue
+					}
+					sva += prop.toSva(slice != -1 && i != slice, clocking, stepPrefix, lineWidth, i) + "\n"
+				}
+				sva += "`endif\n"
+			}
 		}
 		sva += "`endif\n\n"
 	}
@@ -809,24 +888,57 @@ func (seq *FlatProofSequence) toSva(slice int, clocking bool, stepPrefix bool, l
 }
 
 func (seq *FlatProofSequence) toTasks() string {
+	return seq.toTasksOf(nil)
+}
+
+// Tasks which only prove the given properties, assuming everything from the steps they are after, or all properties if nil
+func (seq *FlatProofSequence) toTasksOf(only map[string]bool) string {
 	cmds := ""
 
 	for i := range seq.props {
-		not_own_props := ""
-		for j := i - 1; j >= 0; j-- {
-			for _, prop := range seq.props[j] {
-				not_own_props += " Step" + strconv.Itoa(i) + "::*." + prop.name
+		props := []*Property{}
+		for _, prop := range seq.props[i] {
+			if !prop.assumed && (only == nil || only[prop.name]) {
+				props = append(props, prop)
 			}
 		}
 
-		prop_names := ""
-		for j := i; j >= 0; j-- {
-			for _, prop := range seq.props[j] {
+		for shard, props := range seq.shardsOf(i, props) {
+			cmds += seq.toShardTasks(i, seq.shardName("Step"+strconv.Itoa(i), i, shard), props)
+		}
+	}
+
+	return cmds
+}
+
+// Tasks proving some of the properties of step n, under the given name
+func (seq *FlatProofSequence) toShardTasks(n int, name string, props []*Property) string {
+	cmds := ""
+
+	// Properties with using clauses may assume less than the rest of their step, so need tasks of their own
+	groups, assumptions := seq.assumptionGroups(n, props)
+	for k, group := range groups {
+		task := name
+		if len(groups) > 1 {
+			task += "_" + strconv.Itoa(k)
+		}
+
+		own_props := ""
+		for _, prop := range group {
+			own_props += " *." + prop.name
+		}
+
+		not_own_props := ""
+		prop_names := own_props
+		for _, prop := range assumptions[k] {
+			// Properties kept only as assumptions are already assumed
+			if !prop.assumed {
+				not_own_props += " " + task + "::*." + prop.name
 				prop_names += " *." + prop.name
 			}
 		}
 
-		cmds += "task -create Step" + strconv.Itoa(i) + " -copy_assumes -copy {" + prop_names[1:] + "}\n"
+		cmds += "task -create " + task + " -copy_assumes -copy {" + prop_names[1:] + "}\n"
 		if not_own_props != "" {
 			cmds += "assume -from_assert {" + not_own_props[1:] + "}\n"
 		}
@@ -835,19 +947,196 @@ func (seq *FlatProofSequence) toTasks() string {
 	return cmds
 }
 
-func (seq *FlatProofSequence) toProofStructure() string {
-	patterns := ""
+// Properties of a step which assume the same properties, and are proved together
+type proofUnit struct {
+	props       []*Property
+	assumptions []*Property
+	needs       map[*Property]bool // The assumptions which must be proved, rather than kept only as assumptions
+}
 
-	for _, step := range seq.props {
+func (unit *proofUnit) needsAll(props map[*Property]bool) bool {
+	for prop := range props {
+		if !unit.needs[prop] {
+			return false
+		}
+	}
+	return true
+}
+
+func (unit *proofUnit) needsAny(other *proofUnit) bool {
+	return slices.ContainsFunc(other.props, func(prop *Property) bool { return unit.needs[prop] })
+}
+
+// Splits units into those which neither need nor are needed by one another, even through other units
+func unitComponents(units []*proofUnit) [][]*proofUnit {
+	component := make([]int, len(units))
+	for i := range units {
+		component[i] = i
+	}
+	var find func(i int) int
+	find = func(i int) int {
+		if component[i] != i {
+			component[i] = find(component[i])
+		}
+		return component[i]
+	}
+	for i, unit := range units {
+		for j, other := range units {
+			if unit.needsAny(other) {
+				component[find(i)] = find(j)
+			}
+		}
+	}
+	components := [][]*proofUnit{}
+	index := map[int]int{}
+	for i, unit := range units {
+		root := find(i)
+		if _, ok := index[root]; !ok {
+			index[root] = len(components)
+			components = append(components, []*proofUnit{})
+		}
+		components[index[root]] = append(components[index[root]], unit)
+	}
+	return components
+}
+
+// An assume-guarantee node for each chain of properties, where each element of the chain is proved assuming those
+// before it and exactly the properties it assumes. Where chains branch, the rest of a chain is one element whose node
+// is split further, so that every property is proved once. Properties which cannot be placed in a chain without
+// assuming more than they should, such as some with a using clause, get a node of their own which proves their
+// assumptions again.
+func (seq *FlatProofSequence) toProofStructure() string {
+	patternOf := func(props []*Property) string {
 		prop_names := ""
+		for _, prop := range props {
+			if !prop.assumed {
+				prop_names += " *." + prop.name
+			}
+		}
+		return " {" + strings.Trim(prop_names, " ") + "}"
+	}
+	unitsPattern := func(units []*proofUnit) string {
+		props := []*Property{}
+		for _, unit := range units {
+			props = append(props, unit.props...)
+		}
+		return patternOf(props)
+	}
+
+	units := []*proofUnit{}
+	for i, step := range seq.props {
+		props := []*Property{}
 		for _, prop := range step {
-			prop_names += " *." + prop.name
+			if !prop.assumed && !prop.cover {
+				props = append(props, prop)
+			}
+		}
+		groups, assumptions := seq.assumptionGroups(i, props)
+		for k, group := range groups {
+			unit := &proofUnit{props: group, assumptions: assumptions[k], needs: map[*Property]bool{}}
+			for _, prop := range assumptions[k] {
+				if !prop.assumed {
+					unit.needs[prop] = true
+				}
+			}
+			units = append(units, unit)
 		}
-		patterns += " {" + strings.Trim(prop_names, " ") + "}"
 	}
 
-	return "proof_structure -init root -copy_asserts -copy_assumes\n" +
-		"proof_structure -create assume_guarantee" +
-		" -from root" +
-		" -property [list" + patterns + "]\n"
+	cmds := "proof_structure -init root -copy_asserts -copy_assumes\n"
+	separate := ""
+	ops := 0
+	var chain func(from string, units []*proofUnit, assumed map[*Property]bool)
+	chain = func(from string, units []*proofUnit, assumed map[*Property]bool) {
+		assumed = maps.Clone(assumed)
+		patterns := ""
+		for len(units) > 0 {
+			ready, rest := []*proofUnit{}, []*proofUnit{}
+			for _, unit := range units {
+				if len(unit.needs) == len(assumed) {
+					ready = append(ready, unit)
+				} else {
+					rest = append(rest, unit)
+				}
+			}
+			if len(ready) == 0 {
+				panic("no properties can be proved next in the proof structure")
+			}
+			readyProps := map[*Property]bool{}
+			for _, unit := range ready {
+				for _, prop := range unit.props {
+					readyProps[prop] = true
+				}
+			}
+
+			if !slices.ContainsFunc(rest, func(unit *proofUnit) bool { return !unit.needsAll(readyProps) }) {
+				patterns += unitsPattern(ready)
+				maps.Copy(assumed, readyProps)
+				units = rest
+				continue
+			}
+
+			if components := unitComponents(units); len(components) > 1 {
+				if patterns == "" {
+					for _, component := range components {
+						chain(from, component, assumed)
+					}
+					return
+				}
+				// The rest of the chain is one node, with a chain for each component
+				ops += 1
+				names := []string{}
+				for k := range strings.Count(patterns, "{") + 1 {
+					names = append(names, "ag"+strconv.Itoa(ops)+"_"+strconv.Itoa(k))
+				}
+				cmds += "proof_structure -create assume_guarantee" +
+					" -from " + from +
+					" -imp_name [list " + strings.Join(names, " ") + "]" +
+					" -property [list" + patterns + unitsPattern(units) + "]\n"
+				for _, component := range components {
+					chain(names[len(names)-1], component, assumed)
+				}
+				return
+			}
+
+			// Units which do not assume every ready unit are proved on their own, along with those which need them
+			misfits := map[*proofUnit]bool{}
+			for _, unit := range rest {
+				if !unit.needsAll(readyProps) {
+					misfits[unit] = true
+				}
+			}
+			for changed := true; changed; {
+				changed = false
+				for _, unit := range rest {
+					if !misfits[unit] && slices.ContainsFunc(rest, func(other *proofUnit) bool { return misfits[other] && unit.needsAny(other) }) {
+						misfits[unit] = true
+						changed = true
+					}
+				}
+			}
+			fitting := []*proofUnit{}
+			for _, unit := range units {
+				if misfits[unit] {
+					patterns := ""
+					if len(unit.needs) > 0 {
+						patterns += patternOf(unit.assumptions)
+					}
+					separate += "proof_structure -create assume_guarantee" +
+						" -from root" +
+						" -property [list" + patterns + patternOf(unit.props) + "]\n"
+				} else {
+					fitting = append(fitting, unit)
+				}
+			}
+			units = fitting
+		}
+		if patterns != "" {
+			cmds += "proof_structure -create assume_guarantee" +
+				" -from " + from +
+				" -property [list" + patterns + "]\n"
+		}
+	}
+	chain("root", units, map[*Property]bool{})
+	return cmds + separate
 }
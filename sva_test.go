@@ -0,0 +1,110 @@
+package main
+
+import (
+	"os"
+	"path/filepath"
+	"slices"
+	"strings"
+	"testing"
+)
+
+// Generates the sequence of a root lemma in a proof written to a temporary file
+func testSequence(t *testing.T, proof string, root string) FlatProofSequence {
+	t.Helper()
+	path := filepath.Join(t.TempDir(), "test.proof")
+	if err := os.WriteFile(path, []byte(proof), 0664); err != nil {
+		t.Fatal(err)
+	}
+	scope, err := loadScope([]string{path}, []string{}, map[string]string{}, []string{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	return genSequence(&scope, root)
+}
+
+const diamondProof = `
+lemma dag_example
+  / base
+  P: have (p)
+  / left after base
+  Q: have (q)
+  / right after base
+  R: have (r)
+  / top after left right
+  S: have (s)
+`
+
+// Checks that every property of a sequence is proved exactly once by its proof_structure
+func testProvedOnce(t *testing.T, seq FlatProofSequence, structure string) {
+	t.Helper()
+	_, proofs := structureAssumptions(structure)
+	for _, step := range seq.props {
+		for _, prop := range step {
+			if !prop.assumed && !prop.cover && proofs[prop.name] != 1 {
+				t.Errorf("%s is proved %d times:\n%s", prop.name, proofs[prop.name], structure)
+			}
+		}
+	}
+}
+
+func TestProofStructureDiamond(t *testing.T) {
+	seq := testSequence(t, diamondProof, "dag_example")
+	want := "proof_structure -init root -copy_asserts -copy_assumes\n" +
+		"proof_structure -create assume_guarantee -from root -property [list {*.P} {*.Q *.R} {*.S}]\n"
+	got := seq.toProofStructure()
+	if got != want {
+		t.Errorf("proof_structure:\n%s\nwant:\n%s", got, want)
+	}
+	testProvedOnce(t, seq, got)
+
+	tasks := seq.toTasks()
+	for _, line := range []string{
+		"task -create Step2 -copy_assumes -copy {*.R *.P}\n",
+		"assume -from_assert {Step2::*.P}\n",
+	} {
+		if !strings.Contains(tasks, line) {
+			t.Errorf("tasks missing %q:\n%s", line, tasks)
+		}
+	}
+}
+
+func TestProofStructureLinear(t *testing.T) {
+	seq := testSequence(t, "lemma l\n  P: have (p)\n  Q: have (q)\n  /\n  R: have (r)\n  /\n  S: have (s)\n", "l")
+	want := "proof_structure -init root -copy_asserts -copy_assumes\n" +
+		"proof_structure -create assume_guarantee -from root -property [list {*.P *.Q} {*.R} {*.S}]\n"
+	got := seq.toProofStructure()
+	if got != want {
+		t.Errorf("proof_structure:\n%s\nwant:\n%s", got, want)
+	}
+	testProvedOnce(t, seq, got)
+}
+
+func TestProofStructureBranches(t *testing.T) {
+	seq := testSequence(t, `
+lemma branches
+  / base
+  P: have (p)
+  / left after base
+  Q: have (q)
+  / left2 after left
+  Q2: have (q2)
+  / right after base
+  R: have (r)
+  / right2 after right
+  R2: have (r2)
+`, "branches")
+	want := "proof_structure -init root -copy_asserts -copy_assumes\n" +
+		"proof_structure -create assume_guarantee -from root -imp_name [list ag1_0 ag1_1] -property [list {*.P} {*.Q *.Q2 *.R *.R2}]\n" +
+		"proof_structure -create assume_guarantee -from ag1_1 -property [list {*.Q} {*.Q2}]\n" +
+		"proof_structure -create assume_guarantee -from ag1_1 -property [list {*.R} {*.R2}]\n"
+	got := seq.toProofStructure()
+	if got != want {
+		t.Errorf("proof_structure:\n%s\nwant:\n%s", got, want)
+	}
+	testProvedOnce(t, seq, got)
+
+	assumes, _ := structureAssumptions(got)
+	if !slices.Equal(assumes["Q2"], []string{"P", "Q"}) || !slices.Equal(assumes["R"], []string{"P"}) {
+		t.Errorf("Q2 assumes %v and R assumes %v, want [P Q] and [P]", assumes["Q2"], assumes["R"])
+	}
+}
@@ -0,0 +1,228 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+	"os"
+	"strings"
+)
+
+func svIncludeFlag(flags *flag.FlagSet, svIncludes *[]string) {
+	flags.Func("sv-include", "SystemVerilog headers whose `define macros are expanded within verbatim SystemVerilog", func(s string) error {
+		*svIncludes = append(*svIncludes, s)
+		return nil
+	})
+}
+
+// A `define from a SystemVerilog header
+type SvMacro struct {
+	name   string
+	params []string
+	body   TokenStream
+	source Source
+}
+
+type SvMacros map[string]*SvMacro
+
+// Removes // and /* */ comments from SystemVerilog, keeping line breaks
+func stripSvCommentText(text string) string {
+	str := ""
+	for i := 0; i < len(text); i++ {
+		if strings.HasPrefix(text[i:], "//") {
+			for i < len(text) && text[i] != '\n' {
+				i += 1
+			}
+			if i < len(text) {
+				str += "\n"
+			}
+		} else if strings.HasPrefix(text[i:], "/*") {
+			end := strings.Index(text[i+2:], "*/")
+			if end == -1 {
+				end = len(text) - i - 2
+			}
+			str += strings.Repeat("\n", strings.Count(text[i:i+2+end], "\n"))
+			i += end + 3
+		} else {
+			str += text[i : i+1]
+		}
+	}
+	return str
+}
+
+// Parses the `defines of a header, which are all taken regardless of any `ifdef around them. `undef removes one.
+func parseSvMacros(path string, text string, macros SvMacros) (err error) {
+	lines := strings.Split(stripSvCommentText(text), "\n")
+	l := 0
+	defer func() {
+		if r := recover(); r != nil {
+			err = fmt.Errorf("%s:%d: %v", path, l+1, r)
+		}
+	}()
+
+	for ; l < len(lines); l++ {
+		start := l
+		line := strings.TrimSpace(lines[l])
+		for strings.HasSuffix(line, "\\") && l+1 < len(lines) {
+			l += 1
+			line = strings.TrimSuffix(line, "\\") + "\n" + strings.TrimSpace(lines[l])
+		}
+
+		if name, ok := strings.CutPrefix(line, "`undef"); ok {
+			delete(macros, strings.TrimSpace(name))
+			continue
+		}
+		rest, ok := strings.CutPrefix(line, "`define")
+		if !ok {
+			continue
+		}
+		rest = strings.TrimLeft(rest, " \t")
+		i := 0
+		for i < len(rest) && isIdentStep(rest[i]) && rest[i] != '.' {
+			i += 1
+		}
+		macro := &SvMacro{
+			name:   rest[:i],
+			params: []string{},
+			source: Source{file: path, line: start + 1},
+		}
+		if macro.name == "" {
+			panic(fmt.Errorf("expected a macro name"))
+		}
+		rest = rest[i:]
+
+		// Parameters only follow the name directly, without a space
+		if strings.HasPrefix(rest, "(") {
+			end := strings.Index(rest, ")")
+			if end == -1 {
+				panic(fmt.Errorf("unclosed parameters of macro %s", macro.name))
+			}
+			for _, param := range strings.Split(rest[1:end], ",") {
+				// Default values are not supported, every argument must be given
+				param, _, _ = strings.Cut(param, "=")
+				macro.params = append(macro.params, strings.TrimSpace(param))
+			}
+			rest = rest[end+1:]
+		}
+
+		left, body := tokenize(strings.TrimSpace(rest))
+		if left != "" {
+			panic(fmt.Errorf("failed to parse the body of macro %s", macro.name))
+		}
+		macro.body = body
+		macros[macro.name] = macro
+	}
+	return nil
+}
+
+// The macros defined by each header in turn, later definitions replacing earlier ones
+func loadSvMacros(paths []string) (SvMacros, error) {
+	macros := SvMacros{}
+	for _, path := range paths {
+		data, err := os.ReadFile(path)
+		if err != nil {
+			return nil, err
+		}
+		if err := parseSvMacros(path, string(data), macros); err != nil {
+			return nil, err
+		}
+	}
+	return macros, nil
+}
+
+// Splits the arguments of a macro call at top level commas
+func macroArgs(brack *BracketedToken) []TokenStream {
+	args := []TokenStream{{}}
+	for _, tok := range brack.content {
+		if op, ok := tok.(*OperatorToken); ok && op.operator == "," {
+			args = append(args, TokenStream{})
+		} else {
+			args[len(args)-1] = append(args[len(args)-1], tok)
+		}
+	}
+	for i := range args {
+		args[i] = trimWhitespace(args[i])
+	}
+	return args
+}
+
+// Expands every call of a known macro, repeatedly so that macros may use other macros. Macros used with a member
+// access, such as `CR.instr_valid_id, expand to a single name so that $past applies to the whole of it.
+func (macros SvMacros) expand(stream TokenStream, depth int) TokenStream {
+	if depth > 100 {
+		panic(fmt.Errorf("macros expand recursively"))
+	}
+
+	expanded := TokenStream{}
+	for i := 0; i < len(stream); i++ {
+		if brack, ok := stream[i].(*BracketedToken); ok {
+			expanded = append(expanded, &BracketedToken{
+				openBracket:  brack.openBracket,
+				closeBracket: brack.closeBracket,
+				content:      macros.expand(brack.content, depth),
+			})
+			continue
+		}
+
+		op, ok := stream[i].(*OperatorToken)
+		if !ok || op.operator != "`" || i+1 >= len(stream) {
+			expanded = append(expanded, stream[i])
+			continue
+		}
+		name, ok := stream[i+1].(*NameToken)
+		if !ok {
+			expanded = append(expanded, stream[i])
+			continue
+		}
+		macroName, member, _ := strings.Cut(name.content, ".")
+		macro, ok := macros[macroName]
+		if !ok {
+			expanded = append(expanded, stream[i])
+			continue
+		}
+		i += 1
+
+		body := macro.body
+		if len(macro.params) > 0 {
+			var call *BracketedToken
+			if i+1 < len(stream) {
+				call, _ = stream[i+1].(*BracketedToken)
+			}
+			if member != "" || call == nil || call.openBracket != '(' {
+				panic(fmt.Errorf("macro %s defined at %s expects arguments", macro.name, macro.source))
+			}
+			i += 1
+			args := macroArgs(call)
+			if len(args) != len(macro.params) {
+				panic(fmt.Errorf("macro %s defined at %s expects %d arguments, found %d", macro.name, macro.source, len(macro.params), len(args)))
+			}
+			for p, param := range macro.params {
+				body = subsStream(body, param, macros.expand(args[p], depth+1))
+			}
+			body = concatTokens(body)
+		}
+		body = macros.expand(trimWhitespace(body), depth+1)
+
+		if member != "" {
+			expanded = append(expanded, &NameToken{content: streamToString(body) + "." + member})
+		} else {
+			expanded = append(expanded, body...)
+		}
+	}
+	return expanded
+}
+
+// Expands macros within every verbatim argument of some blocks, before any conditions are negated or moved into the
+// past
+func expandBlockMacros(blocks []Block, macros SvMacros) {
+	for i := range blocks {
+		func() {
+			defer blocks[i].first.locate()
+			for _, arg := range blocks[i].first.inlineArgs {
+				if verbatim, ok := arg.(*VerbatimCommandArg); ok {
+					verbatim.stream = macros.expand(verbatim.stream, 0)
+				}
+			}
+		}()
+		expandBlockMacros(blocks[i].body, macros)
+	}
+}
@@ -0,0 +1,94 @@
+package main
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+const svMacroHeader = "`define BUSY a | b\n" +
+	"`define SEL(x, y) (x & y)\n" +
+	"`define CR u_core.u_regs\n" +
+	"`define NAME(p) p``_q\n" +
+	"// `define COMMENTED 1\n" +
+	"`define LONG c + \\\n  d\n" +
+	"`define GONE 1\n" +
+	"`undef GONE\n"
+
+func TestSvMacroExpand(t *testing.T) {
+	macros := SvMacros{}
+	if err := parseSvMacros("m.svh", svMacroHeader, macros); err != nil {
+		t.Fatal(err)
+	}
+	if len(macros) != 5 || macros["SEL"].source.String() != "m.svh:2" {
+		t.Errorf("parsed macros %v", macros)
+	}
+
+	for expr, want := range map[string]string{
+		"`BUSY":                  "a | b",
+		"`CR.valid":              "u_core.u_regs.valid",
+		"`SEL(p, `BUSY)":         "(p & a | b)",
+		"`SEL((p), `SEL(q, r))":  "((p) & (q & r))",
+		"`NAME(valid)":           "valid_q",
+		"`LONG":                  "c + d",
+		"`GONE && `COMMENTED":    "`GONE && `COMMENTED",
+		"$past(`CR.valid, 2)":    "$past(u_core.u_regs.valid, 2)",
+		"~`BUSY":                 "~a | b",
+		"~(`BUSY) && ~`SEL(r,s)": "~(a | b) && ~(r & s)",
+	} {
+		_, stream := tokenize(expr)
+		if got := streamToString(macros.expand(stream, 0)); got != want {
+			t.Errorf("%s expanded to %s, want %s", expr, got, want)
+		}
+	}
+
+	for expr, err := range map[string]string{
+		"`SEL":             "macro SEL defined at m.svh:2 expects arguments",
+		"`SEL(a)":          "macro SEL defined at m.svh:2 expects 2 arguments, found 1",
+		"`SEL.member(a,b)": "macro SEL defined at m.svh:2 expects arguments",
+	} {
+		func() {
+			defer func() {
+				if r := recover(); r == nil || r.(error).Error() != err {
+					t.Errorf("expanding %s gave %v, want %s", expr, r, err)
+				}
+			}()
+			_, stream := tokenize(expr)
+			macros.expand(stream, 0)
+		}()
+	}
+
+	if err := parseSvMacros("bad.svh", "\n`define (x) x\n", SvMacros{}); err == nil || err.Error() != "bad.svh:2: expected a macro name" {
+		t.Errorf("parsing a define without a name gave %v", err)
+	}
+}
+
+// Like SystemVerilog, expansion is textual, so a ~ before a macro only applies to the first operand of its body. Once
+// expanded, psgen negates the whole of a macro used as a condition.
+func TestSvMacroNegated(t *testing.T) {
+	dir := t.TempDir()
+	header := filepath.Join(dir, "m.svh")
+	proof := filepath.Join(dir, "m.proof")
+	if err := os.WriteFile(header, []byte(svMacroHeader), 0664); err != nil {
+		t.Fatal(err)
+	}
+	source := "lemma l\n  cond (~`BUSY)\n  P: have (p)\n    split_bool B:(`BUSY)\n"
+	if err := os.WriteFile(proof, []byte(source), 0664); err != nil {
+		t.Fatal(err)
+	}
+	scope, err := loadScope([]string{proof}, []string{}, map[string]string{}, []string{header})
+	if err != nil {
+		t.Fatal(err)
+	}
+	seq := genSequence(&scope, "l")
+	sva := seq.toSva(-1, false, false, 100)
+	for _, want := range []string{
+		"P_NotB: assert property ((~a | b) && ~a && ~b |-> p);",
+		"P_B: assert property ((~a | b) && (a | b) |-> p);",
+	} {
+		if !strings.Contains(sva, want) {
+			t.Errorf("no %s in:\n%s", want, sva)
+		}
+	}
+}
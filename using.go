@@ -0,0 +1,147 @@
+package main
+
+import (
+	"fmt"
+	"slices"
+	"strings"
+)
+
+// A `using` clause, restricting the properties generated within it to assume only the named earlier properties and each other
+type UsingClause struct {
+	names  []string
+	source Source
+	parent *UsingClause // An enclosing clause, whose properties include this one's
+}
+
+func useOnly(prop Provable, names []string, source Source) {
+	if names == nil {
+		return
+	}
+	clause := &UsingClause{names: names, source: source}
+	prop.walkProps(func(prop *Property) {
+		if prop.using == nil {
+			prop.using = clause
+			return
+		}
+		// The innermost clause applies, but this clause's properties still include the nested ones
+		outer := prop.using
+		for outer.parent != nil && outer.parent != clause {
+			outer = outer.parent
+		}
+		if outer.parent == nil {
+			outer.parent = clause
+		}
+	})
+}
+
+// Whether a property was generated within a clause
+func (prop *Property) within(clause *UsingClause) bool {
+	for c := prop.using; c != nil; c = c.parent {
+		if c == clause {
+			return true
+		}
+	}
+	return false
+}
+
+// The label paths a name in a property's using clause could refer to, innermost first
+func (prop *Property) usingCandidates(name string) [][]string {
+	outer := slices.Clone(prop.usingScopes)
+	slices.Reverse(outer)
+	candidates := [][]string{}
+	for k := len(outer); k >= 0; k-- {
+		candidates = append(candidates, append(slices.Clone(outer[:k]), strings.Split(name, ".")...))
+	}
+	return candidates
+}
+
+// The properties a name in a property's using clause refers to, a property or group of properties with that label,
+// or with that path of labels separated by dots
+func (seq *FlatProofSequence) resolveUsing(prop *Property, name string) []*Property {
+	for _, candidate := range prop.usingCandidates(name) {
+		found := []*Property{}
+		for _, step := range seq.props {
+			for _, other := range step {
+				if other != prop && len(other.labels) >= len(candidate) && slices.Equal(other.labels[:len(candidate)], candidate) {
+					found = append(found, other)
+				}
+			}
+		}
+		if len(found) > 0 {
+			return found
+		}
+	}
+	return nil
+}
+
+// The properties a property in step n assumes, those in the steps it is after restricted by any using clause
+func (seq *FlatProofSequence) propAssumes(n int, prop *Property) []*Property {
+	named := []*Property{}
+	if prop.using != nil {
+		for _, name := range prop.using.names {
+			named = append(named, seq.resolveUsing(prop, name)...)
+		}
+	}
+
+	assumed := seq.assumes(n)
+	slices.Reverse(assumed)
+	props := []*Property{}
+	for _, j := range assumed {
+		for _, other := range seq.props[j] {
+			if other.cover {
+				continue
+			}
+			if prop.using == nil || other.within(prop.using) || slices.Contains(named, other) {
+				props = append(props, other)
+			}
+		}
+	}
+	return props
+}
+
+// Warns about names in using clauses which match nothing, or properties which cannot be assumed as they are not proved first
+func (seq *FlatProofSequence) checkUsing(scope *Scope) {
+	warn := func(clause *UsingClause, msg string) {
+		if src := clause.source.String(); src != "" {
+			msg = src + ": " + msg
+		}
+		scope.warnOnce(clause.source, fmt.Errorf("warning: %s", msg))
+	}
+
+	for n, step := range seq.props {
+		for _, prop := range step {
+			if prop.using == nil {
+				continue
+			}
+			assumed := seq.assumes(n)
+			for _, name := range prop.using.names {
+				found := seq.resolveUsing(prop, name)
+				if len(found) == 0 {
+					warn(prop.using, "using "+name+" matches no property")
+				}
+				for _, other := range found {
+					if !slices.ContainsFunc(assumed, func(j int) bool { return slices.Contains(seq.props[j], other) }) {
+						warn(prop.using, "using "+name+" in "+prop.name+", but "+other.name+" is not proved before it so is not assumed")
+					}
+				}
+			}
+		}
+	}
+}
+
+// Groups the given properties of step n by the properties they assume
+func (seq *FlatProofSequence) assumptionGroups(n int, props []*Property) ([][]*Property, [][]*Property) {
+	groups := [][]*Property{}
+	assumptions := [][]*Property{}
+	for _, prop := range props {
+		assumed := seq.propAssumes(n, prop)
+		i := slices.IndexFunc(assumptions, func(other []*Property) bool { return slices.Equal(other, assumed) })
+		if i == -1 {
+			groups = append(groups, []*Property{})
+			assumptions = append(assumptions, assumed)
+			i = len(groups) - 1
+		}
+		groups[i] = append(groups[i], prop)
+	}
+	return groups, assumptions
+}
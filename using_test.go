@@ -0,0 +1,186 @@
+package main
+
+import (
+	"maps"
+	"regexp"
+	"slices"
+	"strings"
+	"testing"
+)
+
+// What each property asserted by proof_structure TCL assumes, from the earlier elements of the node it is first proved
+// in and the node that node is part of, and how many times each property is proved. Elements which are split by
+// another node are proved there.
+func structureAssumptions(tcl string) (map[string][]string, map[string]int) {
+	assumes := map[string][]string{}
+	proofs := map[string]int{}
+	split := map[string]bool{}
+	ops := regexp.MustCompile(`^proof_structure -create assume_guarantee -from (\S+)(?: -imp_name \[list ([^]]*)\])? -property \[list (.*)\]$`)
+	for _, line := range strings.Split(tcl, "\n") {
+		if op := ops.FindStringSubmatch(line); op != nil {
+			split[op[1]] = true
+		}
+	}
+	nodes := map[string][]string{"root": {}}
+	for _, line := range strings.Split(tcl, "\n") {
+		op := ops.FindStringSubmatch(line)
+		if op == nil {
+			continue
+		}
+		names := strings.Fields(op[2])
+		earlier := slices.Clone(nodes[op[1]])
+		for k, element := range regexp.MustCompile(`\{([^}]*)\}`).FindAllStringSubmatch(op[3], -1) {
+			props := strings.Fields(strings.ReplaceAll(element[1], "*.", ""))
+			if k < len(names) {
+				nodes[names[k]] = slices.Clone(earlier)
+			}
+			if k >= len(names) || !split[names[k]] {
+				for _, prop := range props {
+					proofs[prop] += 1
+					// Later nodes repeat a property as an assumption after its own node
+					if _, ok := assumes[prop]; !ok {
+						assumes[prop] = slices.Sorted(slices.Values(earlier))
+					}
+				}
+			}
+			earlier = append(earlier, props...)
+		}
+	}
+	return assumes, proofs
+}
+
+// What each property asserted by task TCL assumes, from the assume -from_assert of its task
+func taskAssumptions(tcl string) map[string][]string {
+	assumes := map[string][]string{}
+	copied := []string{}
+	own := func(assumed []string) {
+		for _, prop := range copied {
+			if !slices.Contains(assumed, prop) {
+				assumes[prop] = slices.Sorted(slices.Values(assumed))
+			}
+		}
+	}
+	for _, line := range strings.Split(tcl, "\n") {
+		if strings.HasPrefix(line, "task -create ") {
+			own([]string{})
+			list := line[strings.Index(line, "{")+1 : strings.Index(line, "}")]
+			copied = strings.Fields(strings.ReplaceAll(list, "*.", ""))
+		} else if list, ok := strings.CutPrefix(line, "assume -from_assert {"); ok {
+			assumed := []string{}
+			for _, prop := range strings.Fields(strings.TrimSuffix(list, "}")) {
+				assumed = append(assumed, prop[strings.Index(prop, "*.")+2:])
+			}
+			own(assumed)
+			copied = nil
+		}
+	}
+	own([]string{})
+	return assumes
+}
+
+func TestUsingBackendsAgree(t *testing.T) {
+	for _, proof := range []string{`
+lemma top
+  A: have (a)
+  B: block
+    X: have (x)
+    Y: have (y)
+  Z: have (z)
+  /
+  C: have (c)
+  D: have (d)
+  E: have (e)
+  /
+  F: have (f) using A B.X Z
+  G: have (g)
+`, `
+lemma other
+  O: have (o)
+  /
+  P: have (p)
+
+lemma top
+  / base
+  A: have (a)
+  A_B: have (ab)
+  / left after base
+  L: lemma other
+  / right after base
+  R: have (r) using A
+  / top after left right
+  S: block using L
+    T: have (t)
+    /
+    U: have (u)
+  V: have (v)
+`} {
+		seq := testSequence(t, proof, "top")
+		structure, _ := structureAssumptions(seq.toProofStructure())
+		tasks := taskAssumptions(seq.toTasks())
+		if !maps.EqualFunc(structure, tasks, slices.Equal) {
+			t.Errorf("proof_structure assumes %v, but tasks assume %v", structure, tasks)
+		}
+	}
+}
+
+func TestUsingLabels(t *testing.T) {
+	seq := testSequence(t, `
+lemma top
+  A: have (a)
+  A_B: have (ab)
+  B: block
+    X: have (x)
+    Y: have (y)
+  /
+  F: have (f) using A B.X
+  G: block using B
+    H: have (h) using B.Y
+`, "top")
+	want := map[string][]string{
+		"F":   {"A", "B_X"},
+		"G_H": {"B_Y"},
+	}
+	for i, step := range seq.props {
+		for _, prop := range step {
+			if names, ok := want[prop.name]; ok {
+				got := []string{}
+				for _, other := range seq.propAssumes(i, prop) {
+					got = append(got, other.name)
+				}
+				slices.Sort(got)
+				if !slices.Equal(got, names) {
+					t.Errorf("%s assumes %v, want %v", prop.name, got, names)
+				}
+			}
+		}
+	}
+}
+
+func TestSliceUsing(t *testing.T) {
+	seq := testSequence(t, `
+lemma top
+  A: have (a)
+  B: have (b)
+  /
+  C: have (c) using A
+  /
+  D: have (d) using C
+  E: have (e)
+`, "top")
+	sva := seq.toSva(1, false, false, 100)
+	for _, line := range []string{"A: assume property (a);", "C: assert property (c);"} {
+		if !strings.Contains(sva, line) {
+			t.Errorf("slice 1 missing %q:\n%s", line, sva)
+		}
+	}
+	if strings.Contains(sva, "B:") {
+		t.Errorf("slice 1 assumes B, which C does not use:\n%s", sva)
+	}
+
+	if _, err := seq.sliceAssumptions(2); err == nil || !strings.Contains(err.Error(), "D and E in step 2 assume different properties") {
+		t.Errorf("slicing step 2 gave %v, want an error as D and E assume different properties", err)
+	}
+	if _, err := seq.sliceAssumptions(3); err == nil {
+		t.Errorf("slicing step 3 of 3 did not fail")
+	}
+}
@@ -0,0 +1,225 @@
+package main
+
+import (
+	"fmt"
+	"os"
+	"slices"
+	"strconv"
+	"strings"
+)
+
+// The values of the signals of a VCD, sampled once per clock cycle
+type Trace struct {
+	slots   map[string]int // Each hierarchical name, without the top scope, to the slot holding its value
+	widths  []int
+	cycles  [][]uint64
+	unknown [][]bool // Whether any bit of each value in each cycle is x or z
+	times   []int64  // The time each cycle was sampled at
+}
+
+// The value of a VCD value change, with x and z bits taken as 0 and only the lowest 64 bits kept, and whether it has
+// any x or z bits
+func vcdValue(bits string) (uint64, bool) {
+	value := uint64(0)
+	unknown := false
+	for i := range len(bits) {
+		value <<= 1
+		switch bits[i] {
+		case '1':
+			value |= 1
+		case 'x', 'X', 'z', 'Z':
+			unknown = true
+		}
+	}
+	return value, unknown
+}
+
+// Whether a signal's value in a cycle has any x or z bits
+func (trace *Trace) isUnknown(t int, slot int) bool {
+	return t < len(trace.unknown) && trace.unknown[t][slot]
+}
+
+// Parses a VCD, sampling every signal just before each rising edge of the clock, which is the value properties see. The
+// clock is found below any scope, as other signals are, and if there is none every time step is a cycle.
+func parseVcd(path string, text string, clock string) (*Trace, error) {
+	trace := &Trace{
+		slots:   map[string]int{},
+		widths:  []int{},
+		cycles:  [][]uint64{},
+		unknown: [][]bool{},
+		times:   []int64{},
+	}
+	words := strings.Fields(text)
+	ids := map[string]int{}
+	scopes := []string{}
+	values := []uint64{}
+	unknown := []bool{}
+
+	i := 0
+	skip := func() {
+		for i < len(words) && words[i] != "$end" {
+			i += 1
+		}
+	}
+	for ; i < len(words); i++ {
+		switch words[i] {
+		case "$scope":
+			if i+2 >= len(words) {
+				return nil, fmt.Errorf("%s: unterminated $scope", path)
+			}
+			scopes = append(scopes, words[i+2])
+			skip()
+		case "$upscope":
+			if len(scopes) > 0 {
+				scopes = scopes[:len(scopes)-1]
+			}
+			skip()
+		case "$var":
+			if i+4 >= len(words) {
+				return nil, fmt.Errorf("%s: unterminated $var", path)
+			}
+			width, err := strconv.Atoi(words[i+2])
+			if err != nil {
+				return nil, fmt.Errorf("%s: bad width of $var %s", path, words[i+4])
+			}
+			id := words[i+3]
+			name, _, _ := strings.Cut(words[i+4], "[")
+			if len(scopes) > 1 {
+				name = strings.Join(scopes[1:], ".") + "." + name
+			}
+			slot, ok := ids[id]
+			if !ok {
+				slot = len(trace.widths)
+				ids[id] = slot
+				trace.widths = append(trace.widths, width)
+				values = append(values, 0)
+				unknown = append(unknown, true)
+			}
+			trace.slots[name] = slot
+			skip()
+		case "$enddefinitions":
+			skip()
+			i += 1
+			return trace, trace.readChanges(path, words[i:], ids, values, unknown, clock)
+		default:
+			skip()
+		}
+	}
+	return nil, fmt.Errorf("%s: no $enddefinitions", path)
+}
+
+type vcdChange struct {
+	slot    int
+	value   uint64
+	unknown bool
+}
+
+// Reads the value changes of a VCD. The changes of each time step are applied together, so that the values sampled at
+// a rising edge of the clock are those from before any other change at the same time.
+func (trace *Trace) readChanges(path string, words []string, ids map[string]int, values []uint64, unknown []bool, clock string) error {
+	clockSlot, clocked := trace.slot(clock)
+	if !clocked {
+		fmt.Fprintln(os.Stderr, fmt.Errorf("warning: %s: no clock %s, taking every time step as a cycle", path, clock))
+	}
+	time := int64(0)
+	changes := []vcdChange{}
+	sample := func() {
+		trace.cycles = append(trace.cycles, slices.Clone(values))
+		trace.unknown = append(trace.unknown, slices.Clone(unknown))
+		trace.times = append(trace.times, time)
+	}
+	flush := func() {
+		for _, change := range changes {
+			if clocked && change.slot == clockSlot && values[clockSlot]&1 == 0 && change.value&1 == 1 {
+				sample()
+				break
+			}
+		}
+		for _, change := range changes {
+			values[change.slot] = change.value
+			unknown[change.slot] = change.unknown
+		}
+		if !clocked {
+			sample()
+		}
+		changes = []vcdChange{}
+	}
+	change := func(id string, value uint64, unknown bool) error {
+		slot, ok := ids[id]
+		if !ok {
+			return fmt.Errorf("%s: value change of undeclared signal %s", path, id)
+		}
+		changes = append(changes, vcdChange{slot, value, unknown})
+		return nil
+	}
+
+	started := false
+	for i := 0; i < len(words); i++ {
+		word := words[i]
+		switch {
+		case strings.HasPrefix(word, "#"):
+			if started {
+				flush()
+			}
+			t, err := strconv.ParseInt(word[1:], 10, 64)
+			if err != nil {
+				return fmt.Errorf("%s: bad time %s", path, word)
+			}
+			time = t
+			started = true
+		case word == "$comment":
+			for i < len(words) && words[i] != "$end" {
+				i += 1
+			}
+		case strings.HasPrefix(word, "$"):
+			// $dumpvars, $dumpon and their $end only group value changes
+		case strings.ContainsRune("bBrR", rune(word[0])):
+			if i+1 >= len(words) {
+				return fmt.Errorf("%s: value change without a signal", path)
+			}
+			value, unknown := uint64(0), false
+			if word[0] == 'b' || word[0] == 'B' {
+				value, unknown = vcdValue(word[1:])
+			}
+			if err := change(words[i+1], value, unknown); err != nil {
+				return err
+			}
+			i += 1
+		default:
+			value, unknown := vcdValue(word[:1])
+			if err := change(word[1:], value, unknown); err != nil {
+				return err
+			}
+		}
+	}
+	if started {
+		flush()
+	}
+	return nil
+}
+
+func loadTrace(path string, clock string) (*Trace, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	return parseVcd(path, string(data), clock)
+}
+
+// The slot of a signal, given by its hierarchical name below any scope of the trace, preferring the outermost
+func (trace *Trace) slot(name string) (int, bool) {
+	if slot, ok := trace.slots[name]; ok {
+		return slot, true
+	}
+	best, found := "", false
+	for full := range trace.slots {
+		if strings.HasSuffix(full, "."+name) && (!found || len(full) < len(best) || (len(full) == len(best) && full < best)) {
+			best, found = full, true
+		}
+	}
+	if found {
+		// Found once, as every property looks up the same signals
+		trace.slots[name] = trace.slots[best]
+	}
+	return trace.slots[best], found
+}
@@ -0,0 +1,101 @@
+package main
+
+import (
+	"slices"
+	"testing"
+)
+
+const testVcd = `
+$timescale 1ns $end
+$scope module tb $end
+$scope module dut $end
+$var wire 1 ! clk_i $end
+$var wire 1 " valid $end
+$var wire 4 # data [3:0] $end
+$upscope $end
+$upscope $end
+$enddefinitions $end
+#0
+$dumpvars
+0!
+0"
+b0 #
+$end
+#5
+1!
+#10
+0!
+1"
+b11 #
+#15
+1!
+0"
+#20
+0!
+#25
+1!
+bx1 #
+`
+
+func TestParseVcd(t *testing.T) {
+	// The clock is found below the tb and dut scopes, as other signals are
+	trace, err := parseVcd("test.vcd", testVcd, "clk_i")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !slices.Equal(trace.times, []int64{5, 15, 25}) {
+		t.Fatalf("sampled at %v, want each rising edge of the clock", trace.times)
+	}
+
+	// Changes at the same time as a rising edge are sampled in the next cycle
+	for _, test := range []struct {
+		name   string
+		values []uint64
+	}{
+		{"valid", []uint64{0, 1, 0}},
+		{"dut.valid", []uint64{0, 1, 0}},
+		{"data", []uint64{0, 3, 3}},
+	} {
+		slot, ok := trace.slot(test.name)
+		if !ok {
+			t.Errorf("no signal %s", test.name)
+			continue
+		}
+		values := []uint64{}
+		for _, cycle := range trace.cycles {
+			values = append(values, cycle[slot])
+		}
+		if !slices.Equal(values, test.values) {
+			t.Errorf("%s is %v, want %v", test.name, values, test.values)
+		}
+	}
+	if slot, _ := trace.slot("data"); trace.widths[slot] != 4 {
+		t.Errorf("data has width %d, want 4", trace.widths[slot])
+	}
+
+	ev := NewEvaluator(trace, []Wiring{})
+	if failures := ev.failures(parseEvalProperty("valid |=> data == 4'd3 && !valid")); len(failures) != 0 {
+		t.Errorf("valid |=> data == 4'd3 && !valid fails in %v, want none", failures)
+	}
+
+	// Without the clock, every time step is a cycle
+	trace, err = parseVcd("test.vcd", testVcd, "clock")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !slices.Equal(trace.times, []int64{0, 5, 10, 15, 20, 25}) {
+		t.Errorf("sampled at %v, want every time step", trace.times)
+	}
+}
+
+func TestParseVcdErrors(t *testing.T) {
+	for _, text := range []string{
+		"$scope module tb $end $var wire 1 ! a $end",
+		"$scope module tb $end $var wire 1 ! a $end $upscope $end $enddefinitions $end #0 1?",
+		"$scope module tb $end $var wire 1 ! a $end $upscope $end $enddefinitions $end #x 1!",
+	} {
+		if _, err := parseVcd("test.vcd", text, "clk_i"); err == nil {
+			t.Errorf("parsing %q did not fail", text)
+		}
+	}
+}
@@ -0,0 +1,129 @@
+package main
+
+import (
+	"fmt"
+	"maps"
+	"os"
+	"path/filepath"
+	"slices"
+	"time"
+)
+
+const watchInterval = 500 * time.Millisecond
+
+// The files which should trigger regeneration when they change, including those imported when last generated
+func watchedFiles() []string {
+	files := slices.Clone(paths)
+	for _, file := range sourceFiles {
+		if !slices.ContainsFunc(files, func(path string) bool { return sameFile(path, file) }) {
+			files = append(files, file)
+		}
+	}
+	return files
+}
+
+func sameFile(a string, b string) bool {
+	absA, errA := filepath.Abs(a)
+	absB, errB := filepath.Abs(b)
+	return errA == nil && errB == nil && absA == absB
+}
+
+type fileStamp struct {
+	modTime int64
+	size    int64
+}
+
+func stampFiles(files []string) map[string]fileStamp {
+	stamps := map[string]fileStamp{}
+	for _, file := range files {
+		// Missing files get the zero stamp, so that they are noticed when they reappear
+		if info, err := os.Stat(file); err == nil {
+			stamps[file] = fileStamp{modTime: info.ModTime().UnixNano(), size: info.Size()}
+		} else {
+			stamps[file] = fileStamp{}
+		}
+	}
+	return stamps
+}
+
+func tryGenerate() (outputs map[string]string, props map[string]string, err error) {
+	defer func() {
+		if r := recover(); r != nil {
+			err = fmt.Errorf("%v", r)
+		}
+	}()
+	return generate()
+}
+
+// The names of properties which were added, removed or changed between two generations
+func diffProperties(before map[string]string, after map[string]string) ([]string, []string, []string) {
+	added, removed, changed := []string{}, []string{}, []string{}
+	for _, name := range slices.Sorted(maps.Keys(after)) {
+		if old, ok := before[name]; !ok {
+			added = append(added, name)
+		} else if old != after[name] {
+			changed = append(changed, name)
+		}
+	}
+	for _, name := range slices.Sorted(maps.Keys(before)) {
+		if _, ok := after[name]; !ok {
+			removed = append(removed, name)
+		}
+	}
+	return added, removed, changed
+}
+
+// Regenerates every output, reporting what changed since the last successful generation
+func regenerate(prevProps map[string]string) map[string]string {
+	outputs, props, err := tryGenerate()
+	if err != nil {
+		fmt.Println(fmt.Errorf("error: %v", err))
+		return prevProps
+	}
+
+	for _, path := range slices.Sorted(maps.Keys(outputs)) {
+		written, err := writeIfChanged(path, outputs[path])
+		if err != nil {
+			fmt.Println(fmt.Errorf("error: %v", err))
+		} else if written {
+			fmt.Println("wrote " + path)
+		}
+	}
+
+	if prevProps == nil {
+		fmt.Printf("generated %d properties\n", len(props))
+		return props
+	}
+	added, removed, changed := diffProperties(prevProps, props)
+	for _, name := range added {
+		fmt.Println("+ " + name)
+	}
+	for _, name := range removed {
+		fmt.Println("- " + name)
+	}
+	for _, name := range changed {
+		fmt.Println("~ " + name)
+	}
+	fmt.Printf("%d properties added, %d removed, %d changed\n", len(added), len(removed), len(changed))
+	return props
+}
+
+// Polls the source files, regenerating whenever any of them change
+func watch() {
+	props := regenerate(nil)
+	stamps := stampFiles(watchedFiles())
+	for {
+		time.Sleep(watchInterval)
+		now := stampFiles(watchedFiles())
+		if maps.Equal(now, stamps) {
+			continue
+		}
+		for _, file := range slices.Sorted(maps.Keys(now)) {
+			if now[file] != stamps[file] {
+				fmt.Println("changed " + file)
+			}
+		}
+		stamps = now
+		props = regenerate(props)
+	}
+}
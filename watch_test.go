@@ -0,0 +1,101 @@
+package main
+
+import (
+	"io"
+	"os"
+	"path/filepath"
+	"slices"
+	"strings"
+	"testing"
+)
+
+// Runs f, returning what it printed to stdout
+func testStdout(t *testing.T, f func()) string {
+	t.Helper()
+	reader, writer, err := os.Pipe()
+	if err != nil {
+		t.Fatal(err)
+	}
+	stdout := os.Stdout
+	os.Stdout = writer
+	printed := make(chan string)
+	go func() {
+		data, _ := io.ReadAll(reader)
+		printed <- string(data)
+	}()
+	defer func() { os.Stdout = stdout }()
+	f()
+	writer.Close()
+	return <-printed
+}
+
+func TestDiffProperties(t *testing.T) {
+	added, removed, changed := diffProperties(
+		map[string]string{"A": "a", "B": "b", "C": "c"},
+		map[string]string{"A": "a", "C": "c2", "D": "d", "E": "e"})
+	if !slices.Equal(added, []string{"D", "E"}) || !slices.Equal(removed, []string{"B"}) || !slices.Equal(changed, []string{"C"}) {
+		t.Errorf("added %v, removed %v, changed %v", added, removed, changed)
+	}
+}
+
+func TestRegenerate(t *testing.T) {
+	dir := testFiles(t, map[string]string{
+		"top.proof": "import \"lib.proof\" as lib\nlemma l\n  A: have (a)\n  B: have (b)\n  lemma lib.w\n",
+		"lib.proof": "lemma w\n  W: have (w)\n",
+	})
+	top := filepath.Join(dir, "top.proof")
+	out := filepath.Join(dir, "out", "l.sv")
+
+	// Generation reads the flags of the main command
+	savedPaths, savedIncludes, savedDefines, savedSvIncludes := paths, includes, defines, svIncludes
+	savedRoot, savedSvOut, savedSlice, savedShards := rootLemma, svOut, slice, shards
+	t.Cleanup(func() {
+		paths, includes, defines, svIncludes = savedPaths, savedIncludes, savedDefines, savedSvIncludes
+		rootLemma, svOut, slice, shards = savedRoot, savedSvOut, savedSlice, savedShards
+	})
+	paths, includes, defines, svIncludes = []string{top}, []string{}, map[string]string{}, []string{}
+	rootLemma, svOut, slice, shards = "l", out, -1, 1
+
+	var props map[string]string
+	printed := testStdout(t, func() { props = regenerate(nil) })
+	if printed != "wrote "+out+"\ngenerated 3 properties\n" {
+		t.Errorf("first generation printed:\n%s", printed)
+	}
+
+	// Imported files are watched too, and a missing file is noticed once it appears
+	files := watchedFiles()
+	if !slices.Equal(files, []string{top, filepath.Join(dir, "lib.proof")}) {
+		t.Errorf("watching %v", files)
+	}
+	missing := filepath.Join(dir, "missing.proof")
+	stamps := stampFiles(append(files, missing))
+	if stamps[missing] != (fileStamp{}) || stamps[top] == (fileStamp{}) {
+		t.Errorf("stamps %v", stamps)
+	}
+
+	// Only what changed is listed, and unchanged outputs are not rewritten
+	if err := os.WriteFile(top, []byte("import \"lib.proof\" as lib\nlemma l\n  A: have (a2)\n  C: have (c)\n  lemma lib.w\n"), 0664); err != nil {
+		t.Fatal(err)
+	}
+	printed = testStdout(t, func() { props = regenerate(props) })
+	if printed != "wrote "+out+"\n+ C\n- B\n~ A\n1 properties added, 1 removed, 1 changed\n" {
+		t.Errorf("regeneration printed:\n%s", printed)
+	}
+	printed = testStdout(t, func() { props = regenerate(props) })
+	if printed != "0 properties added, 0 removed, 0 changed\n" {
+		t.Errorf("regenerating without changes printed:\n%s", printed)
+	}
+
+	// Errors are reported, keeping the last good properties to compare against
+	if err := os.WriteFile(top, []byte("lemma l\n  lemma missing\n"), 0664); err != nil {
+		t.Fatal(err)
+	}
+	before := props
+	printed = testStdout(t, func() { props = regenerate(props) })
+	if !strings.HasPrefix(printed, "error: ") || len(props) != len(before) {
+		t.Errorf("failed generation printed %s and kept %v", printed, props)
+	}
+	if sv, err := os.ReadFile(out); err != nil || !strings.Contains(string(sv), "A: assert property (a2);") {
+		t.Errorf("failed generation changed the output:\n%s", sv)
+	}
+}